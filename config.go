@@ -1,20 +1,31 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"regexp"
 	"sync/atomic"
+	"time"
 
 	"github.com/jacosy/go-web-server/internal/auth"
 	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
 	"github.com/jacosy/go-web-server/internal/utils"
 )
 
+// usernamePattern matches the usernames that @mentions can refer to.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,50}$`)
+
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	conn           *sql.DB
 	db             *database.Queries
+	relay          *outbox.Relay
 	env            string
 	secretKey      string
 }
@@ -27,14 +38,27 @@ func (c *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 }
 
 func (c *apiConfig) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	reports := map[string]int64{}
+	counts, err := c.db.CountReportsByStatus(r.Context())
+	if err != nil {
+		log.Printf("Failed to count reports: %v", err)
+	}
+	for _, count := range counts {
+		reports[count.Status] = count.Count
+	}
+
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	fmt.Fprintf(w, `<html>
   <body>
     <h1>Welcome, Chirpy Admin</h1>
     <p>Chirpy has been visited %d times!</p>
+    <h2>Moderation queue</h2>
+    <p>Open reports: %d</p>
+    <p>Claimed reports: %d</p>
+    <p>Resolved reports: %d</p>
   </body>
-</html>`, c.fileserverHits.Load())
+</html>`, c.fileserverHits.Load(), reports["open"], reports["claimed"], reports["resolved"])
 }
 
 func (c *apiConfig) ResetMetricsHandler(w http.ResponseWriter, r *http.Request) {
@@ -61,35 +85,74 @@ func (c *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if createUserRequest.Password == "" || createUserRequest.Email == "" {
+	if createUserRequest.Password == "" || createUserRequest.Email == "" || createUserRequest.Username == "" {
 		http.Error(w, "Invalid request body: username, email, and password are required", http.StatusBadRequest)
 		return
 	}
 
+	if !usernamePattern.MatchString(createUserRequest.Username) {
+		http.Error(w, "Username may only contain letters, digits and underscores, up to 50 characters", http.StatusBadRequest)
+		return
+	}
+
 	hashedPwd, err := auth.HashPassword(createUserRequest.Password)
 	if err != nil {
 		http.Error(w, "Failed to hash password", http.StatusInternalServerError)
 		return
 	}
 
-	user, err := c.db.CreateUser(r.Context(), database.CreateUserParams{
+	user, err := c.createUser(r.Context(), database.CreateUserParams{
 		Username:       createUserRequest.Username,
 		Email:          createUserRequest.Email,
 		HashedPassword: hashedPwd,
 	})
 	if err != nil {
+		if utils.IsUniqueViolation(err) {
+			http.Error(w, "Username is already taken", http.StatusConflict)
+			return
+		}
+
 		http.Error(w, "Failed to create user", http.StatusInternalServerError)
 		return
 	}
 
 	utils.ResponseWithJSON(w, http.StatusCreated, UserResponse{
 		ID:        user.ID,
+		Username:  user.Username,
 		Email:     user.Email,
 		CreatedAt: user.CreatedAt.Time,
 		UpdatedAt: user.UpdatedAt.Time,
 	})
 }
 
+// createUser stores a new user together with its user.created event.
+func (c *apiConfig) createUser(ctx context.Context, params database.CreateUserParams) (database.CreateUserRow, error) {
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.CreateUserRow{}, err
+	}
+	defer tx.Rollback()
+
+	qtx := c.db.WithTx(tx)
+	user, err := qtx.CreateUser(ctx, params)
+	if err != nil {
+		return database.CreateUserRow{}, err
+	}
+	if err := outbox.Write(ctx, qtx, events.Event{
+		Type:       events.UserCreated,
+		ActorID:    user.ID,
+		OccurredAt: user.CreatedAt.Time,
+	}); err != nil {
+		return database.CreateUserRow{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return database.CreateUserRow{}, err
+	}
+
+	c.relay.Wake()
+	return user, nil
+}
+
 func (c *apiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var loginRequest LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&loginRequest); err != nil {
@@ -113,15 +176,19 @@ func (c *apiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jwtToken, err := auth.MakeJWT(user.ID, c.secretKey)
+	jwtToken, err := auth.MakeJWT(user.ID, c.secretKey, time.Hour)
 	if err != nil {
 		http.Error(w, "Failed to create JWT token", http.StatusInternalServerError)
 		return
 	}
 
+	// A login without a working refresh token would leave the client unable
+	// to renew its session, so it fails as a whole.
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		log.Print("Failed to create refresh token")
+		log.Printf("Failed to create refresh token: %v", err)
+		http.Error(w, "Failed to create refresh token", http.StatusInternalServerError)
+		return
 	}
 
 	if err := c.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
@@ -129,10 +196,15 @@ func (c *apiConfig) LoginUser(w http.ResponseWriter, r *http.Request) {
 		Token:  refreshToken,
 	}); err != nil {
 		log.Printf("Failed to store refresh token: %v", err)
+		http.Error(w, "Failed to create refresh token", http.StatusInternalServerError)
+		return
 	}
 
 	utils.ResponseWithJSON(w, http.StatusOK, UserResponse{
 		ID:           user.ID,
+		Username:     user.Username,
+		DisplayName:  user.DisplayName,
+		Bio:          user.Bio,
 		Email:        user.Email,
 		CreatedAt:    user.CreatedAt.Time,
 		UpdatedAt:    user.UpdatedAt.Time,
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/auth"
+)
+
+// authenticate returns the user ID carried by the request's bearer token.
+func authenticate(r *http.Request, secretKey string) (uuid.UUID, error) {
+	jwtToken, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return auth.ValidateJWT(jwtToken, secretKey)
+}
+
+// viewer returns the caller's user ID, or uuid.Nil for anonymous requests.
+// A token that is present but invalid is still an error.
+func viewer(r *http.Request, secretKey string) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+
+	return authenticate(r, secretKey)
+}
@@ -0,0 +1,301 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+// Block serves the caller's block and mute lists. Blocks hide two users from
+// each other and stop them from following or mentioning one another; mutes
+// only hide the muted user's chirps from the muter. Both are enforced by
+// visibility.Filter.
+type Block struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+	fanout    *timeline.Fanout
+	relay     *outbox.Relay
+}
+
+func NewBlockHandler(conn *sql.DB, db *database.Queries, secretKey string, fanout *timeline.Fanout, relay *outbox.Relay) *Block {
+	return &Block{conn: conn, db: db, secretKey: secretKey, fanout: fanout, relay: relay}
+}
+
+// BlockUser blocks the user with the given ID and removes any follows between
+// the two users.
+func (b *Block) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := b.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := withTx(r.Context(), b.conn, b.db, func(qtx *database.Queries) error {
+		if err := qtx.BlockUser(r.Context(), database.BlockUserParams{
+			BlockerID: userID,
+			BlockedID: targetID,
+		}); err != nil {
+			return err
+		}
+
+		unfollowed, err := qtx.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: userID,
+			FolloweeID: targetID,
+		})
+		if err != nil {
+			return err
+		}
+		unfollowedBy, err := qtx.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: targetID,
+			FolloweeID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		if unfollowed > 0 {
+			if err := b.fanout.Unfollowed(r.Context(), qtx, userID, targetID); err != nil {
+				return err
+			}
+		}
+		if unfollowedBy > 0 {
+			if err := b.fanout.Unfollowed(r.Context(), qtx, targetID, userID); err != nil {
+				return err
+			}
+		}
+		return outbox.Write(r.Context(), qtx, relationshipChanged(userID, targetID))
+	})
+	if err != nil {
+		log.Println("Error blocking user:", err)
+		http.Error(w, "Failed to block user", http.StatusInternalServerError)
+		return
+	}
+
+	b.relay.Wake()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Block) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, b.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = withTx(r.Context(), b.conn, b.db, func(qtx *database.Queries) error {
+		unblocked, err := qtx.UnblockUser(r.Context(), database.UnblockUserParams{
+			BlockerID: userID,
+			BlockedID: targetID,
+		})
+		if err != nil || unblocked == 0 {
+			return err
+		}
+		return outbox.Write(r.Context(), qtx, relationshipChanged(userID, targetID))
+	})
+	if err != nil {
+		log.Println("Error unblocking user:", err)
+		http.Error(w, "Failed to unblock user", http.StatusInternalServerError)
+		return
+	}
+
+	b.relay.Wake()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Block) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := b.relationTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := withTx(r.Context(), b.conn, b.db, func(qtx *database.Queries) error {
+		if err := qtx.MuteUser(r.Context(), database.MuteUserParams{
+			MuterID: userID,
+			MutedID: targetID,
+		}); err != nil {
+			return err
+		}
+		return outbox.Write(r.Context(), qtx, relationshipChanged(userID, targetID))
+	})
+	if err != nil {
+		log.Println("Error muting user:", err)
+		http.Error(w, "Failed to mute user", http.StatusInternalServerError)
+		return
+	}
+
+	b.relay.Wake()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Block) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, b.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	err = withTx(r.Context(), b.conn, b.db, func(qtx *database.Queries) error {
+		unmuted, err := qtx.UnmuteUser(r.Context(), database.UnmuteUserParams{
+			MuterID: userID,
+			MutedID: targetID,
+		})
+		if err != nil || unmuted == 0 {
+			return err
+		}
+		return outbox.Write(r.Context(), qtx, relationshipChanged(userID, targetID))
+	})
+	if err != nil {
+		log.Println("Error unmuting user:", err)
+		http.Error(w, "Failed to unmute user", http.StatusInternalServerError)
+		return
+	}
+
+	b.relay.Wake()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetBlocks lists the users the caller has blocked, most recent first.
+func (b *Block) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, b.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := b.db.GetBlockedUsers(r.Context(), database.GetBlockedUsersParams{
+		UserID:          userID,
+		BeforeBlockedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving blocks:", err)
+		http.Error(w, "Failed to retrieve blocked users", http.StatusInternalServerError)
+		return
+	}
+
+	resp := RelationListResponseModel{Users: []RelationUserResponseModel{}}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, RelationUserResponseModel{
+			ID:        row.ID,
+			Username:  row.Username,
+			CreatedAt: row.BlockedAt,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.BlockedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// GetMutes lists the users the caller has muted, most recent first.
+func (b *Block) GetMutes(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, b.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := b.db.GetMutedUsers(r.Context(), database.GetMutedUsersParams{
+		UserID:        userID,
+		BeforeMutedAt: p.beforeAt(),
+		BeforeID:      p.beforeID(),
+		Limit:         p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving mutes:", err)
+		http.Error(w, "Failed to retrieve muted users", http.StatusInternalServerError)
+		return
+	}
+
+	resp := RelationListResponseModel{Users: []RelationUserResponseModel{}}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, RelationUserResponseModel{
+			ID:        row.ID,
+			Username:  row.Username,
+			CreatedAt: row.MutedAt,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.MutedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// relationTarget authenticates the caller and resolves the {id} path value to
+// another existing user, writing the error response itself when it cannot.
+func (b *Block) relationTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := authenticate(r, b.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if targetID == userID {
+		http.Error(w, "You cannot block or mute yourself", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if _, err := b.db.GetUserByID(r.Context(), targetID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return uuid.Nil, uuid.Nil, false
+		}
+
+		log.Println("Error retrieving user:", err)
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, targetID, true
+}
+
+// relationshipChanged is the event that tells the live connections of both
+// users to reload what they may see.
+func relationshipChanged(userID, targetID uuid.UUID) events.Event {
+	return events.Event{Type: events.RelationshipChanged, ActorID: userID, UserID: targetID}
+}
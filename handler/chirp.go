@@ -1,32 +1,45 @@
 package handler
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jacosy/go-web-server/internal/auth"
 	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/moderation"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
 )
 
 type Chirp struct {
+	conn      *sql.DB
 	db        *database.Queries
 	secretKey string
+	fanout    *timeline.Fanout
+	relay     *outbox.Relay
+	moderator *moderation.Pipeline
 }
 
-func NewChirpHandler(db *database.Queries, secretKey string) *Chirp {
-	return &Chirp{db: db, secretKey: secretKey}
+func NewChirpHandler(conn *sql.DB, db *database.Queries, secretKey string, fanout *timeline.Fanout, relay *outbox.Relay, moderator *moderation.Pipeline) *Chirp {
+	return &Chirp{conn: conn, db: db, secretKey: secretKey, fanout: fanout, relay: relay, moderator: moderator}
 }
 
-var profaneWords = map[string]struct{}{
-	"kerfuffle": {},
-	"sharbert":  {},
-	"fornax":    {},
-}
+// Reference kinds of a chirp that reposts another one.
+const (
+	referenceRechirp = "rechirp"
+	referenceQuote   = "quote"
+)
 
 func (c *Chirp) CreateChirp(w http.ResponseWriter, r *http.Request) {
 	jwtToken, err := auth.GetBearerToken(r.Header)
@@ -48,23 +61,33 @@ func (c *Chirp) CreateChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(req.Body) > 140 {
-		http.Error(w, "Chirp body exceeds 140 characters", http.StatusBadRequest)
+	var chirp database.Chirp
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		var err error
+		chirp, err = createChirp(r.Context(), qtx, c.moderator, userID, req)
+		if err != nil {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, chirpCreatedEvent(chirp)); err != nil {
+			return err
+		}
+		return c.fanout.ChirpCreated(r.Context(), qtx, chirp)
+	})
+	if err != nil {
+		respondWithCreateError(w, err)
 		return
 	}
 
-	cleanedBody := getCleanedBody(req.Body)
-	chirp, dbErr := c.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		UserID: userID,
-		Body:   cleanedBody,
-	})
-	if dbErr != nil {
-		log.Println("Error creating chirp:", dbErr)
-		http.Error(w, "Failed to create chirp", http.StatusInternalServerError)
+	c.relay.Wake()
+
+	responses, err := chirpResponses(r.Context(), c.db, userID, []database.Chirp{chirp})
+	if err != nil {
+		log.Println("Error building chirp response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	data, err := json.Marshal(convertChirpToResponseModel(chirp))
+	data, err := json.Marshal(responses[0])
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -75,19 +98,140 @@ func (c *Chirp) CreateChirp(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
+// requestError is a chirp request that failed validation. Its message is
+// meant for the client.
+type requestError struct {
+	status int
+	msg    string
+}
+
+func (e *requestError) Error() string {
+	return e.msg
+}
+
+func badRequest(format string, args ...any) error {
+	return &requestError{status: http.StatusBadRequest, msg: fmt.Sprintf(format, args...)}
+}
+
+// createChirp validates and moderates req and stores it as a new chirp of
+// userID together with its media, poll, entities and moderation flags. Callers
+// bind db to a transaction so that a failure part way leaves nothing behind.
+// Validation failures are returned as *requestError.
+func createChirp(ctx context.Context, db *database.Queries, moderator *moderation.Pipeline, userID uuid.UUID, req *ChirptRequestModel) (database.Chirp, error) {
+	// RejectSuspended covers requests, but not drafts published later.
+	if err := checkNotSuspended(ctx, db, userID); err != nil {
+		return database.Chirp{}, err
+	}
+
+	if len(req.Body) > 140 {
+		return database.Chirp{}, badRequest("Chirp body exceeds 140 characters")
+	}
+
+	if err := validateMediaIDs(req.MediaIDs); err != nil {
+		return database.Chirp{}, badRequest("%s", err)
+	}
+
+	audience, err := visibility.Parse(req.Visibility)
+	if err != nil {
+		return database.Chirp{}, badRequest("%s", err)
+	}
+
+	var pollOptions []string
+	if req.Poll != nil {
+		var err error
+		if pollOptions, err = validatePoll(req.Poll, time.Now()); err != nil {
+			return database.Chirp{}, badRequest("%s", err)
+		}
+	}
+
+	body, flags, err := moderateBody(moderator, req.Body)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	params := database.CreateChirpParams{
+		UserID:     userID,
+		Body:       body,
+		Visibility: audience,
+	}
+
+	if req.QuotedChirpID != nil {
+		if strings.TrimSpace(req.Body) == "" {
+			return database.Chirp{}, badRequest("A quote chirp must have a body")
+		}
+
+		quoted, err := resolveReference(ctx, db, userID, *req.QuotedChirpID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return database.Chirp{}, &requestError{status: http.StatusNotFound, msg: "Quoted chirp not found"}
+			}
+			return database.Chirp{}, err
+		}
+
+		params.ReferenceID = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+		params.ReferenceKind = sql.NullString{String: referenceQuote, Valid: true}
+	}
+
+	chirp, err := db.CreateChirp(ctx, params)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := attachMedia(ctx, db, chirp.ID, userID, req.MediaIDs); err != nil {
+		if errors.Is(err, errInvalidMedia) {
+			return database.Chirp{}, badRequest("Media IDs must be your own uploads that are not attached to another chirp")
+		}
+		return database.Chirp{}, err
+	}
+
+	if req.Poll != nil {
+		if err := createPoll(ctx, db, chirp.ID, pollOptions, req.Poll.ClosesAt); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if err := saveFlags(ctx, db, chirp, flags); err != nil {
+		return database.Chirp{}, err
+	}
+	if err := saveEntities(ctx, db, chirp); err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// respondWithCreateError reports an error returned by createChirp.
+func respondWithCreateError(w http.ResponseWriter, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		http.Error(w, reqErr.msg, reqErr.status)
+		return
+	}
+
+	log.Println("Error creating chirp:", err)
+	http.Error(w, "Failed to create chirp", http.StatusInternalServerError)
+}
+
 func (c *Chirp) GetChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := viewer(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	chirps, err := c.db.GetAllChirps(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
 		return
 	}
 
-	var chirpResponses []ChirpResponseModel
-	for _, chirp := range chirps {
-		chirpResponses = append(chirpResponses, convertChirpToResponseModel(chirp))
+	responses, err := chirpResponses(r.Context(), c.db, viewerID, chirps)
+	if err != nil {
+		log.Println("Error building chirp responses:", err)
+		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
 	}
 
-	data, err := json.Marshal(chirpResponses)
+	data, err := json.Marshal(responses)
 	if err != nil {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
@@ -111,6 +255,12 @@ func (c *Chirp) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewerID, err := viewer(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
 	chirp, err := c.db.GetChirpByID(r.Context(), chirpID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -123,7 +273,18 @@ func (c *Chirp) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := json.Marshal(convertChirpToResponseModel(chirp))
+	responses, err := chirpResponses(r.Context(), c.db, viewerID, []database.Chirp{chirp})
+	if err != nil {
+		log.Println("Error building chirp response:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return
+	}
+	if len(responses) == 0 {
+		http.Error(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := json.Marshal(responses[0])
 	if err != nil {
 		log.Println("failed to JSON.Marshal a chirp instance:", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -135,22 +296,568 @@ func (c *Chirp) GetChirpByID(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-func getCleanedBody(body string) string {
-	words := strings.Split(body, " ")
-	for i, str := range words {
-		if _, exists := profaneWords[strings.ToLower(str)]; exists {
-			words[i] = "****"
+func (c *Chirp) DeleteChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := c.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return
+	}
+
+	if chirp.UserID != userID {
+		http.Error(w, "You can only delete your own chirps", http.StatusForbidden)
+		return
+	}
+
+	// The attachment rows are deleted with the chirp, so their files are
+	// looked up first and purged once the deletion commits.
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		media, err := qtx.GetMediaAttachmentsByChirpIDs(r.Context(), []uuid.UUID{chirpID})
+		if err != nil {
+			return err
+		}
+		if err := qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:     chirpID,
+			UserID: userID,
+		}); err != nil {
+			return err
+		}
+		if err := purgeMediaBlobs(r.Context(), qtx, media); err != nil {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, events.Event{Type: events.ChirpDeleted, ActorID: userID, ChirpID: chirpID}); err != nil {
+			return err
+		}
+		return c.fanout.ChirpDeleted(r.Context(), qtx, chirpID)
+	})
+	if err != nil {
+		log.Println("Error deleting chirp:", err)
+		http.Error(w, "Failed to delete chirp", http.StatusInternalServerError)
+		return
+	}
+
+	c.relay.Wake()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateChirp replaces the body of one of the caller's chirps.
+func (c *Chirp) UpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	req := &ChirptRequestModel{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Body) > 140 {
+		http.Error(w, "Chirp body exceeds 140 characters", http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := c.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
 		}
+
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return
+	}
+
+	if chirp.UserID != userID {
+		http.Error(w, "You can only edit your own chirps", http.StatusForbidden)
+		return
+	}
+
+	switch chirp.ReferenceKind.String {
+	case referenceRechirp:
+		http.Error(w, "Rechirps cannot be edited", http.StatusBadRequest)
+		return
+	case referenceQuote:
+		if strings.TrimSpace(req.Body) == "" {
+			http.Error(w, "A quote chirp must have a body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	body, flags, err := moderateBody(c.moderator, req.Body)
+	if err != nil {
+		respondWithCreateError(w, err)
+		return
+	}
+
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		var err error
+		chirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			ID:     chirpID,
+			UserID: userID,
+			Body:   body,
+		})
+		if err != nil {
+			return err
+		}
+		if err := saveFlags(r.Context(), qtx, chirp, flags); err != nil {
+			return err
+		}
+		return saveEntities(r.Context(), qtx, chirp)
+	})
+	if err != nil {
+		log.Println("Error updating chirp:", err)
+		http.Error(w, "Failed to update chirp", http.StatusInternalServerError)
+		return
+	}
+
+	responses, err := chirpResponses(r.Context(), c.db, userID, []database.Chirp{chirp})
+	if err != nil {
+		log.Println("Error building chirp response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, responses[0])
+}
+
+// GetChirpsByHashtag lists the newest chirps tagged with the {tag} path value.
+func (c *Chirp) GetChirpsByHashtag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		http.Error(w, "Hashtag is required", http.StatusBadRequest)
+		return
+	}
+
+	viewerID, err := viewer(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := c.db.GetChirpsByHashtag(r.Context(), database.GetChirpsByHashtagParams{
+		Tag:             tag,
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving hashtag chirps:", err)
+		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	c.respondWithChirpPage(w, r, viewerID, p, chirps)
+}
+
+// GetMentions lists the newest chirps that mention the user with the {id}
+// path value.
+func (c *Chirp) GetMentions(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	viewerID, err := viewer(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chirps, err := c.db.GetChirpsMentioningUser(r.Context(), database.GetChirpsMentioningUserParams{
+		UserID:          userID,
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving mentions:", err)
+		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	c.respondWithChirpPage(w, r, viewerID, p, chirps)
+}
+
+// respondWithChirpPage writes one page of a newest-first chirp listing.
+func (c *Chirp) respondWithChirpPage(w http.ResponseWriter, r *http.Request, viewerID uuid.UUID, p page, chirps []database.Chirp) {
+	var err error
+	resp := ChirpListResponseModel{}
+	resp.Chirps, err = chirpResponses(r.Context(), c.db, viewerID, chirps)
+	if err != nil {
+		log.Println("Error building chirp responses:", err)
+		http.Error(w, "Failed to retrieve chirps", http.StatusInternalServerError)
+		return
+	}
+
+	if len(chirps) > 0 {
+		last := chirps[len(chirps)-1]
+		resp.NextCursor = p.nextCursor(len(chirps), pageCursor{At: last.CreatedAt.Time, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// Rechirp reposts the chirp with the given ID as a new chirp of the caller.
+func (c *Chirp) Rechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	original, err := resolveReference(r.Context(), c.db, userID, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return
+	}
+
+	// A rechirp is public, so it would carry a followers-only or unlisted
+	// chirp to an audience its author did not choose.
+	if original.Visibility != visibility.Public {
+		http.Error(w, "Only public chirps can be rechirped", http.StatusForbidden)
+		return
+	}
+
+	var chirp database.Chirp
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		var err error
+		chirp, err = qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+			UserID:        userID,
+			ReferenceID:   uuid.NullUUID{UUID: original.ID, Valid: true},
+			ReferenceKind: sql.NullString{String: referenceRechirp, Valid: true},
+			Visibility:    visibility.Public,
+		})
+		if err != nil {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, chirpCreatedEvent(chirp)); err != nil {
+			return err
+		}
+		return c.fanout.ChirpCreated(r.Context(), qtx, chirp)
+	})
+	if err != nil {
+		if utils.IsUniqueViolation(err) {
+			http.Error(w, "You have already rechirped this chirp", http.StatusConflict)
+			return
+		}
+
+		log.Println("Error creating rechirp:", err)
+		http.Error(w, "Failed to rechirp", http.StatusInternalServerError)
+		return
+	}
+
+	c.relay.Wake()
+
+	responses, err := chirpResponses(r.Context(), c.db, userID, []database.Chirp{chirp})
+	if err != nil {
+		log.Println("Error building chirp response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, responses[0])
+}
+
+// UndoRechirp deletes the caller's rechirp of the chirp with the given ID.
+func (c *Chirp) UndoRechirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	rechirp, err := c.db.GetRechirp(r.Context(), database.GetRechirpParams{
+		UserID:      userID,
+		ReferenceID: uuid.NullUUID{UUID: chirpID, Valid: true},
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Rechirp not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving rechirp:", err)
+		http.Error(w, "Failed to undo rechirp", http.StatusInternalServerError)
+		return
+	}
+
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		if err := qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{
+			ID:     rechirp.ID,
+			UserID: userID,
+		}); err != nil {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, events.Event{Type: events.ChirpDeleted, ActorID: userID, ChirpID: rechirp.ID}); err != nil {
+			return err
+		}
+		return c.fanout.ChirpDeleted(r.Context(), qtx, rechirp.ID)
+	})
+	if err != nil {
+		log.Println("Error deleting rechirp:", err)
+		http.Error(w, "Failed to undo rechirp", http.StatusInternalServerError)
+		return
+	}
+
+	c.relay.Wake()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveReference returns the chirp that a new rechirp or quote of chirpID
+// should point at. Plain rechirps are looked through to the chirp they repost.
+// Hidden chirps, and chirps userID may not see because of a block or mute,
+// cannot be reposted and are reported as missing.
+func resolveReference(ctx context.Context, db *database.Queries, userID, chirpID uuid.UUID) (database.Chirp, error) {
+	chirp, err := db.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if chirp.ReferenceKind.String == referenceRechirp {
+		if !chirp.ReferenceID.Valid {
+			return database.Chirp{}, sql.ErrNoRows
+		}
+		if chirp, err = db.GetChirpByID(ctx, chirp.ReferenceID.UUID); err != nil {
+			return database.Chirp{}, err
+		}
+	}
+
+	if chirp.HiddenAt.Valid {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	visible, err := visibility.CanSee(ctx, db, userID, chirp)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if !visible {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func chirpCreatedEvent(chirp database.Chirp) events.Event {
+	return events.Event{
+		Type:       events.ChirpCreated,
+		ActorID:    chirp.UserID,
+		ChirpID:    chirp.ID,
+		OccurredAt: chirp.CreatedAt.Time,
 	}
-	return strings.Join(words, " ")
 }
 
 func convertChirpToResponseModel(chirp database.Chirp) ChirpResponseModel {
 	return ChirpResponseModel{
-		ID:        chirp.ID,
-		UserID:    chirp.UserID,
-		Body:      chirp.Body,
-		CreatedAt: chirp.CreatedAt.Time,
-		UpdatedAt: chirp.UpdatedAt.Time,
+		ID:                   chirp.ID,
+		UserID:               chirp.UserID,
+		Body:                 chirp.Body,
+		CreatedAt:            chirp.CreatedAt.Time,
+		UpdatedAt:            chirp.UpdatedAt.Time,
+		LikeCount:            chirp.LikeCount,
+		RechirpCount:         chirp.RechirpCount,
+		QuoteCount:           chirp.QuoteCount,
+		ReferenceKind:        chirp.ReferenceKind.String,
+		ReferenceUnavailable: chirp.ReferenceKind.Valid && !chirp.ReferenceID.Valid,
+		Visibility:           chirp.Visibility,
+		Hidden:               chirp.HiddenAt.Valid,
+	}
+}
+
+// chirpResponses converts chirps to response models, embedding the chirps they
+// reference and filling in the state that depends on who is looking at them.
+// viewerID is uuid.Nil for anonymous callers. Chirps the viewer may not see
+// are left out, so the result can be shorter than chirps.
+func chirpResponses(ctx context.Context, db *database.Queries, viewerID uuid.UUID, chirps []database.Chirp) ([]ChirpResponseModel, error) {
+	v, err := visibility.Load(ctx, db, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.LoadFollows(ctx, db, chirps); err != nil {
+		return nil, err
+	}
+
+	var refIDs []uuid.UUID
+	for _, chirp := range chirps {
+		if v.CanSee(chirp) && chirp.ReferenceID.Valid {
+			refIDs = append(refIDs, chirp.ReferenceID.UUID)
+		}
+	}
+
+	refs := make(map[uuid.UUID]*ChirpResponseModel, len(refIDs))
+	// Referenced chirps the viewer may not see. Plain rechirps of them are
+	// dropped rather than shown as unavailable.
+	hiddenRefs := make(map[uuid.UUID]struct{})
+	if len(refIDs) > 0 {
+		referenced, err := db.GetChirpsByIDs(ctx, refIDs)
+		if err != nil {
+			return nil, err
+		}
+		if err := v.LoadFollows(ctx, db, referenced); err != nil {
+			return nil, err
+		}
+		for _, ref := range referenced {
+			if !v.CanSee(ref) {
+				hiddenRefs[ref.ID] = struct{}{}
+				continue
+			}
+			model := convertChirpToResponseModel(ref)
+			refs[ref.ID] = &model
+		}
+	}
+
+	visible := make([]database.Chirp, 0, len(chirps))
+	for _, chirp := range chirps {
+		if !v.CanSee(chirp) {
+			continue
+		}
+		if chirp.ReferenceKind.String == referenceRechirp && chirp.UserID != viewerID {
+			if _, ok := hiddenRefs[chirp.ReferenceID.UUID]; ok {
+				continue
+			}
+		}
+		visible = append(visible, chirp)
+	}
+
+	responses := make([]ChirpResponseModel, 0, len(visible))
+	for _, chirp := range visible {
+		responses = append(responses, convertChirpToResponseModel(chirp))
+	}
+
+	// Every model the viewer can see, embedded ones included.
+	all := make([]*ChirpResponseModel, 0, len(responses)+len(refs))
+	for i := range responses {
+		all = append(all, &responses[i])
+	}
+	for _, ref := range refs {
+		all = append(all, ref)
+	}
+
+	if len(all) > 0 {
+		if err := fillEntities(ctx, db, all); err != nil {
+			return nil, err
+		}
+		if err := fillMedia(ctx, db, all); err != nil {
+			return nil, err
+		}
+		if err := fillPolls(ctx, db, viewerID, all); err != nil {
+			return nil, err
+		}
+	}
+
+	if viewerID != uuid.Nil && len(all) > 0 {
+		if err := fillViewerState(ctx, db, viewerID, all); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, chirp := range visible {
+		if !chirp.ReferenceID.Valid {
+			continue
+		}
+		if ref, ok := refs[chirp.ReferenceID.UUID]; ok {
+			responses[i].ReferencedChirp = ref
+		} else {
+			// Deleted between the two reads, or hidden from the viewer.
+			responses[i].ReferenceUnavailable = true
+		}
+	}
+
+	return responses, nil
+}
+
+// fillViewerState sets the fields of models that describe how viewerID has
+// interacted with each chirp.
+func fillViewerState(ctx context.Context, db *database.Queries, viewerID uuid.UUID, models []*ChirpResponseModel) error {
+	ids := make([]uuid.UUID, 0, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+
+	likedIDs, err := db.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	rechirpedIDs, err := db.GetRechirpedChirpIDs(ctx, database.GetRechirpedChirpIDsParams{
+		UserID:   viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[uuid.UUID]struct{}, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = struct{}{}
+	}
+	rechirped := make(map[uuid.UUID]struct{}, len(rechirpedIDs))
+	for _, id := range rechirpedIDs {
+		rechirped[id.UUID] = struct{}{}
+	}
+
+	for _, m := range models {
+		_, m.LikedByMe = liked[m.ID]
+		_, m.RechirpedByMe = rechirped[m.ID]
 	}
+	return nil
 }
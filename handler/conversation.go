@@ -0,0 +1,471 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+const (
+	// maxConversationMembers includes the caller.
+	maxConversationMembers = 10
+	maxMessageLength       = 1000
+)
+
+// Conversation serves direct messages. Conversations and their messages live
+// in their own tables and are only ever returned to their members; no chirp
+// endpoint reads them.
+type Conversation struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+	bus       *events.Bus
+}
+
+func NewConversationHandler(conn *sql.DB, db *database.Queries, secretKey string, bus *events.Bus) *Conversation {
+	return &Conversation{conn: conn, db: db, secretKey: secretKey, bus: bus}
+}
+
+// CreateConversation starts a conversation between the caller and the
+// requested members. Users with a block between them and the caller cannot
+// be added.
+func (c *Conversation) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req ConversationRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var others []uuid.UUID
+	for _, id := range req.MemberIDs {
+		if id != userID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		http.Error(w, "A conversation needs at least one other member", http.StatusBadRequest)
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		http.Error(w, fmt.Sprintf("A conversation can have at most %d members", maxConversationMembers), http.StatusBadRequest)
+		return
+	}
+
+	blocked, err := c.db.GetBlockedAmong(r.Context(), database.GetBlockedAmongParams{
+		UserID:  userID,
+		UserIds: others,
+	})
+	if err != nil {
+		log.Println("Error checking blocks:", err)
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+	if len(blocked) > 0 {
+		http.Error(w, "You cannot message one or more of these users", http.StatusForbidden)
+		return
+	}
+
+	var directKey sql.NullString
+	if len(others) == 1 {
+		directKey = sql.NullString{String: directConversationKey(userID, others[0]), Valid: true}
+	}
+
+	var conversation database.Conversation
+	err = withTx(r.Context(), c.conn, c.db, func(qtx *database.Queries) error {
+		var err error
+		conversation, err = qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+			CreatedBy: userID,
+			DirectKey: directKey,
+		})
+		if err != nil {
+			return err
+		}
+
+		return qtx.AddConversationMembers(r.Context(), database.AddConversationMembersParams{
+			ConversationID: conversation.ID,
+			UserIds:        append(others, userID),
+		})
+	})
+	if err != nil {
+		if utils.IsForeignKeyViolation(err) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error creating conversation:", err)
+		http.Error(w, "Failed to create conversation", http.StatusInternalServerError)
+		return
+	}
+
+	resp, err := c.conversationResponse(r.Context(), userID, conversation.ID)
+	if err != nil {
+		log.Println("Error building conversation response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, resp)
+}
+
+// GetConversations lists the caller's conversations, most recently active
+// first, with their unread counts.
+func (c *Conversation) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := c.db.ListConversations(r.Context(), database.ListConversationsParams{
+		UserID:          userID,
+		BeforeUpdatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving conversations:", err)
+		http.Error(w, "Failed to retrieve conversations", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ConversationListResponseModel{}
+	resp.Conversations, err = conversationResponses(r.Context(), c.db, rows)
+	if err != nil {
+		log.Println("Error building conversation responses:", err)
+		http.Error(w, "Failed to retrieve conversations", http.StatusInternalServerError)
+		return
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.UpdatedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// GetMessages lists the messages of one of the caller's conversations,
+// newest first.
+func (c *Conversation) GetMessages(w http.ResponseWriter, r *http.Request) {
+	_, conversationID, _, ok := c.lookupConversation(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := c.db.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID:  conversationID,
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving messages:", err)
+		http.Error(w, "Failed to retrieve messages", http.StatusInternalServerError)
+		return
+	}
+
+	resp := MessageListResponseModel{Messages: []MessageResponseModel{}}
+	for _, message := range messages {
+		resp.Messages = append(resp.Messages, convertMessageToResponseModel(message))
+	}
+	if len(messages) > 0 {
+		last := messages[len(messages)-1]
+		resp.NextCursor = p.nextCursor(len(messages), pageCursor{At: last.CreatedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// SendMessage posts a message to one of the caller's conversations. It is
+// refused while a block exists between the caller and another member.
+func (c *Conversation) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID, conversationID, members, ok := c.lookupConversation(w, r)
+	if !ok {
+		return
+	}
+
+	var req MessageRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	message, err := postMessage(r.Context(), c.db, c.bus, userID, conversationID, members, req.Body)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.msg, reqErr.status)
+			return
+		}
+
+		log.Println("Error creating message:", err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, convertMessageToResponseModel(message))
+}
+
+// postMessage validates body and stores it as a message of userID in the
+// conversation with the given members, then announces it on bus. Failures the
+// caller can fix are returned as *requestError.
+func postMessage(ctx context.Context, db *database.Queries, bus *events.Bus, userID, conversationID uuid.UUID, members []database.GetConversationMembersRow, body string) (database.Message, error) {
+	// RejectSuspended covers requests, but not messages sent over a socket.
+	if err := checkNotSuspended(ctx, db, userID); err != nil {
+		return database.Message{}, err
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return database.Message{}, badRequest("Message body is required")
+	}
+	if utf8.RuneCountInString(body) > maxMessageLength {
+		return database.Message{}, badRequest("Message body exceeds %d characters", maxMessageLength)
+	}
+
+	var others []uuid.UUID
+	for _, m := range members {
+		if m.ID != userID {
+			others = append(others, m.ID)
+		}
+	}
+	if len(others) > 0 {
+		blocked, err := db.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+			UserID:  userID,
+			UserIds: others,
+		})
+		if err != nil {
+			return database.Message{}, err
+		}
+		if len(blocked) > 0 {
+			return database.Message{}, &requestError{
+				status: http.StatusForbidden,
+				msg:    "You cannot message one or more members of this conversation",
+			}
+		}
+	}
+
+	message, err := db.CreateMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           body,
+	})
+	if err != nil {
+		return database.Message{}, err
+	}
+
+	bus.Publish(events.Event{
+		Type:           events.MessageCreated,
+		ActorID:        userID,
+		ConversationID: conversationID,
+		MessageID:      message.ID,
+	})
+	return message, nil
+}
+
+// MarkRead marks every message of one of the caller's conversations as read.
+func (c *Conversation) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return
+	}
+
+	n, err := c.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		log.Println("Error marking conversation read:", err)
+		http.Error(w, "Failed to mark conversation read", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lookupConversation authenticates the caller and resolves the {id} path value
+// to a conversation they are a member of, returning its members. Conversations
+// of other users are reported as missing.
+func (c *Conversation) lookupConversation(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, []database.GetConversationMembersRow, bool) {
+	userID, err := authenticate(r, c.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, nil, false
+	}
+
+	conversationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, nil, false
+	}
+
+	members, err := conversationMembers(r.Context(), c.db, userID, conversationID)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.msg, reqErr.status)
+			return uuid.Nil, uuid.Nil, nil, false
+		}
+
+		log.Println("Error retrieving conversation members:", err)
+		http.Error(w, "Failed to retrieve conversation", http.StatusInternalServerError)
+		return uuid.Nil, uuid.Nil, nil, false
+	}
+
+	return userID, conversationID, members, true
+}
+
+// conversationMembers returns the members of a conversation of userID.
+// Conversations of other users are reported as a missing *requestError.
+func conversationMembers(ctx context.Context, db *database.Queries, userID, conversationID uuid.UUID) ([]database.GetConversationMembersRow, error) {
+	members, err := db.GetConversationMembers(ctx, []uuid.UUID{conversationID})
+	if err != nil {
+		return nil, err
+	}
+
+	isMember := slices.ContainsFunc(members, func(m database.GetConversationMembersRow) bool {
+		return m.ID == userID
+	})
+	if !isMember {
+		return nil, &requestError{status: http.StatusNotFound, msg: "Conversation not found"}
+	}
+	return members, nil
+}
+
+// conversationResponse builds the response model of one conversation of
+// userID.
+func (c *Conversation) conversationResponse(ctx context.Context, userID, conversationID uuid.UUID) (ConversationResponseModel, error) {
+	rows, err := c.db.ListConversations(ctx, database.ListConversationsParams{
+		UserID:         userID,
+		ConversationID: uuid.NullUUID{UUID: conversationID, Valid: true},
+		Limit:          1,
+	})
+	if err != nil {
+		return ConversationResponseModel{}, err
+	}
+	if len(rows) == 0 {
+		return ConversationResponseModel{}, sql.ErrNoRows
+	}
+
+	responses, err := conversationResponses(ctx, c.db, rows)
+	if err != nil {
+		return ConversationResponseModel{}, err
+	}
+	return responses[0], nil
+}
+
+// directConversationKey identifies the one-to-one conversation between two
+// users regardless of which of them started it.
+func directConversationKey(a, b uuid.UUID) string {
+	if strings.Compare(a.String(), b.String()) > 0 {
+		a, b = b, a
+	}
+	return a.String() + ":" + b.String()
+}
+
+// conversationResponses converts rows of ListConversations to response models
+// with their members and latest message.
+func conversationResponses(ctx context.Context, db *database.Queries, rows []database.ListConversationsRow) ([]ConversationResponseModel, error) {
+	responses := make([]ConversationResponseModel, 0, len(rows))
+	if len(rows) == 0 {
+		return responses, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	members, err := db.GetConversationMembers(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byConversation := make(map[uuid.UUID][]ConversationMemberResponseModel, len(rows))
+	for _, m := range members {
+		byConversation[m.ConversationID] = append(byConversation[m.ConversationID], ConversationMemberResponseModel{
+			ID:       m.ID,
+			Username: m.Username,
+		})
+	}
+
+	latest, err := db.GetLatestMessages(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	lastMessages := make(map[uuid.UUID]MessageResponseModel, len(latest))
+	for _, message := range latest {
+		lastMessages[message.ConversationID] = convertMessageToResponseModel(message)
+	}
+
+	for _, row := range rows {
+		resp := ConversationResponseModel{
+			ID:          row.ID,
+			Group:       !row.DirectKey.Valid,
+			Members:     byConversation[row.ID],
+			UnreadCount: row.UnreadCount,
+			CreatedAt:   row.CreatedAt,
+			UpdatedAt:   row.UpdatedAt,
+		}
+		if resp.Members == nil {
+			resp.Members = []ConversationMemberResponseModel{}
+		}
+		if message, ok := lastMessages[row.ID]; ok {
+			resp.LastMessage = &message
+		}
+		if row.LastReadAt.Valid {
+			resp.LastReadAt = &row.LastReadAt.Time
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+func convertMessageToResponseModel(message database.Message) MessageResponseModel {
+	return MessageResponseModel{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		SenderID:       message.SenderID,
+		Body:           message.Body,
+		CreatedAt:      message.CreatedAt,
+	}
+}
@@ -0,0 +1,429 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	"github.com/jacosy/go-web-server/internal/moderation"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// Draft serves a user's unpublished chirps and publishes the scheduled ones.
+type Draft struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+	fanout    *timeline.Fanout
+	relay     *outbox.Relay
+	moderator *moderation.Pipeline
+}
+
+func NewDraftHandler(conn *sql.DB, db *database.Queries, secretKey string, fanout *timeline.Fanout, relay *outbox.Relay, moderator *moderation.Pipeline) *Draft {
+	return &Draft{conn: conn, db: db, secretKey: secretKey, fanout: fanout, relay: relay, moderator: moderator}
+}
+
+// jobPublishDraft is the kind of the job that publishes a scheduled draft.
+const jobPublishDraft = "drafts.publish"
+
+// draftPublishAttempts bounds the retries of a scheduled draft that fails for
+// reasons other than its contents, such as the database being unavailable.
+const draftPublishAttempts = 10
+
+// errDraftNotDue is returned within runPublish for a job whose draft was
+// rescheduled after the job was enqueued.
+var errDraftNotDue = errors.New("draft is not due")
+
+type publishDraftPayload struct {
+	DraftID   uuid.UUID `json:"draft_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	PublishAt time.Time `json:"publish_at"`
+}
+
+// RegisterJobs registers the handler of the jobs that publish scheduled
+// drafts on queue.
+func (d *Draft) RegisterJobs(queue *jobs.Queue) {
+	queue.Register(jobPublishDraft, d.runPublish)
+}
+
+func (d *Draft) CreateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, d.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req DraftRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateDraft(&req); err != nil {
+		respondWithCreateError(w, err)
+		return
+	}
+
+	var draft database.Draft
+	err = withTx(r.Context(), d.conn, d.db, func(qtx *database.Queries) error {
+		var err error
+		draft, err = qtx.CreateDraft(r.Context(), database.CreateDraftParams{
+			UserID:        userID,
+			Body:          req.Body,
+			QuotedChirpID: nullUUID(req.QuotedChirpID),
+			MediaIds:      mediaIDs(req.MediaIDs),
+			PublishAt:     nullTime(req.PublishAt),
+			Visibility:    req.Visibility,
+		})
+		if err != nil {
+			return err
+		}
+		return schedulePublish(r.Context(), qtx, draft)
+	})
+	if err != nil {
+		log.Println("Error creating draft:", err)
+		http.Error(w, "Failed to create draft", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, convertDraftToResponseModel(draft))
+}
+
+func (d *Draft) GetDrafts(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, d.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	drafts, err := d.db.ListDrafts(r.Context(), userID)
+	if err != nil {
+		log.Println("Error retrieving drafts:", err)
+		http.Error(w, "Failed to retrieve drafts", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]DraftResponseModel, 0, len(drafts))
+	for _, draft := range drafts {
+		responses = append(responses, convertDraftToResponseModel(draft))
+	}
+	utils.ResponseWithJSON(w, http.StatusOK, responses)
+}
+
+func (d *Draft) GetDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, ok := d.draftRequest(w, r)
+	if !ok {
+		return
+	}
+
+	draft, err := d.db.GetDraft(r.Context(), database.GetDraftParams{ID: draftID, UserID: userID})
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertDraftToResponseModel(draft))
+}
+
+// UpdateDraft replaces the contents and schedule of one of the caller's
+// drafts.
+func (d *Draft) UpdateDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, ok := d.draftRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req DraftRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateDraft(&req); err != nil {
+		respondWithCreateError(w, err)
+		return
+	}
+
+	var draft database.Draft
+	err := withTx(r.Context(), d.conn, d.db, func(qtx *database.Queries) error {
+		var err error
+		draft, err = qtx.UpdateDraft(r.Context(), database.UpdateDraftParams{
+			Body:          req.Body,
+			QuotedChirpID: nullUUID(req.QuotedChirpID),
+			MediaIds:      mediaIDs(req.MediaIDs),
+			PublishAt:     nullTime(req.PublishAt),
+			Visibility:    req.Visibility,
+			ID:            draftID,
+			UserID:        userID,
+		})
+		if err != nil {
+			return err
+		}
+		return schedulePublish(r.Context(), qtx, draft)
+	})
+	if err != nil {
+		respondWithDraftError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertDraftToResponseModel(draft))
+}
+
+func (d *Draft) DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, ok := d.draftRequest(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := d.db.DeleteDraft(r.Context(), database.DeleteDraftParams{ID: draftID, UserID: userID})
+	if err != nil {
+		log.Println("Error deleting draft:", err)
+		http.Error(w, "Failed to delete draft", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PublishDraft publishes one of the caller's drafts right away, whether or
+// not it is scheduled.
+func (d *Draft) PublishDraft(w http.ResponseWriter, r *http.Request) {
+	userID, draftID, ok := d.draftRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var chirp database.Chirp
+	err := withTx(r.Context(), d.conn, d.db, func(qtx *database.Queries) error {
+		// Waits for a job that is publishing the same draft, after which
+		// the draft is gone.
+		draft, err := qtx.LockDraft(r.Context(), database.LockDraftParams{ID: draftID, UserID: userID})
+		if err != nil {
+			return err
+		}
+		chirp, err = publishDraft(r.Context(), qtx, d.moderator, draft)
+		if err != nil {
+			return err
+		}
+		return d.fanout.ChirpCreated(r.Context(), qtx, chirp)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		respondWithCreateError(w, err)
+		return
+	}
+
+	d.relay.Wake()
+
+	responses, err := chirpResponses(r.Context(), d.db, userID, []database.Chirp{chirp})
+	if err != nil {
+		log.Println("Error building chirp response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, responses[0])
+}
+
+// schedulePublish enqueues the job that publishes draft at its publish_at,
+// if it has one. Pass the Queries of the transaction that saves the draft.
+func schedulePublish(ctx context.Context, db *database.Queries, draft database.Draft) error {
+	if !draft.PublishAt.Valid {
+		return nil
+	}
+
+	_, err := jobs.Enqueue(ctx, db, jobPublishDraft, publishDraftPayload{
+		DraftID:   draft.ID,
+		UserID:    draft.UserID,
+		PublishAt: draft.PublishAt.Time,
+	}, jobs.Options{RunAt: draft.PublishAt.Time, MaxAttempts: draftPublishAttempts})
+	return err
+}
+
+// runPublish publishes the draft of a job. Jobs of drafts that have been
+// published, deleted or rescheduled since do nothing; rescheduling enqueues
+// a job of its own. The draft stays locked until its chirp is committed and
+// the draft deleted in the same transaction, so a draft is never published
+// twice. Drafts that fail validation, or keep failing otherwise, are
+// unscheduled and keep the reason.
+func (d *Draft) runPublish(ctx context.Context, job jobs.Job) error {
+	var payload publishDraftPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var draft database.Draft
+	var chirp database.Chirp
+	err := withTx(ctx, d.conn, d.db, func(qtx *database.Queries) error {
+		var err error
+		draft, err = qtx.LockDraft(ctx, database.LockDraftParams{ID: payload.DraftID, UserID: payload.UserID})
+		if err != nil {
+			return err
+		}
+		if !draft.PublishAt.Valid || !draft.PublishAt.Time.Equal(payload.PublishAt) {
+			return errDraftNotDue
+		}
+		chirp, err = publishDraft(ctx, qtx, d.moderator, draft)
+		if err != nil {
+			return err
+		}
+		return d.fanout.ChirpCreated(ctx, qtx, chirp)
+	})
+
+	var reqErr *requestError
+	switch {
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, errDraftNotDue):
+		return nil
+	case errors.As(err, &reqErr):
+		return d.markFailed(ctx, draft, reqErr.msg)
+	case err != nil:
+		if job.Attempt >= draftPublishAttempts {
+			if err := d.markFailed(ctx, draft, "Failed to publish"); err != nil {
+				log.Printf("Error unscheduling draft %s: %v", draft.ID, err)
+			}
+		}
+		return err
+	}
+
+	d.relay.Wake()
+	return nil
+}
+
+// markFailed unschedules draft with the reason it could not be published,
+// unless it was edited in the meantime.
+func (d *Draft) markFailed(ctx context.Context, draft database.Draft, reason string) error {
+	return d.db.MarkDraftFailed(ctx, database.MarkDraftFailedParams{
+		LastError: reason,
+		ID:        draft.ID,
+		UpdatedAt: draft.UpdatedAt,
+	})
+}
+
+func (d *Draft) draftRequest(w http.ResponseWriter, r *http.Request) (userID, draftID uuid.UUID, ok bool) {
+	userID, err := authenticate(r, d.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	draftID, err = uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid draft ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, draftID, true
+}
+
+// publishDraft turns draft into a chirp through the same validation and
+// cleaning as CreateChirp, writes its event to the outbox and deletes the
+// draft.
+func publishDraft(ctx context.Context, db *database.Queries, moderator *moderation.Pipeline, draft database.Draft) (database.Chirp, error) {
+	req := &ChirptRequestModel{
+		Body:       draft.Body,
+		MediaIDs:   draft.MediaIds,
+		Visibility: draft.Visibility,
+	}
+	if draft.QuotedChirpID.Valid {
+		req.QuotedChirpID = &draft.QuotedChirpID.UUID
+	}
+
+	chirp, err := createChirp(ctx, db, moderator, draft.UserID, req)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+
+	if err := outbox.Write(ctx, db, chirpCreatedEvent(chirp)); err != nil {
+		return database.Chirp{}, err
+	}
+	if _, err := db.DeleteDraft(ctx, database.DeleteDraftParams{ID: draft.ID, UserID: draft.UserID}); err != nil {
+		return database.Chirp{}, err
+	}
+	return chirp, nil
+}
+
+// validateDraft checks what can be checked before publishing. Everything
+// else, such as whether quoted chirps and media still exist, is checked when
+// the draft is published.
+func validateDraft(req *DraftRequestModel) error {
+	if len(req.Body) > 140 {
+		return badRequest("Chirp body exceeds 140 characters")
+	}
+	if err := validateMediaIDs(req.MediaIDs); err != nil {
+		return badRequest("%s", err)
+	}
+	audience, err := visibility.Parse(req.Visibility)
+	if err != nil {
+		return badRequest("%s", err)
+	}
+	req.Visibility = audience
+	if req.PublishAt != nil && !req.PublishAt.After(time.Now()) {
+		return badRequest("publish_at must be in the future")
+	}
+	return nil
+}
+
+func respondWithDraftError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Error retrieving draft:", err)
+	http.Error(w, "Failed to retrieve draft", http.StatusInternalServerError)
+}
+
+func convertDraftToResponseModel(draft database.Draft) DraftResponseModel {
+	resp := DraftResponseModel{
+		ID:         draft.ID,
+		Body:       draft.Body,
+		MediaIDs:   mediaIDs(draft.MediaIds),
+		Visibility: draft.Visibility,
+		LastError:  draft.LastError,
+		CreatedAt:  draft.CreatedAt,
+		UpdatedAt:  draft.UpdatedAt,
+	}
+	if draft.QuotedChirpID.Valid {
+		resp.QuotedChirpID = &draft.QuotedChirpID.UUID
+	}
+	if draft.PublishAt.Valid {
+		resp.PublishAt = &draft.PublishAt.Time
+	}
+	return resp
+}
+
+// mediaIDs returns ids, or an empty slice if it is nil.
+func mediaIDs(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
+
+func nullUUID(id *uuid.UUID) uuid.NullUUID {
+	if id == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: *id, Valid: true}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
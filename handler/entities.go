@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/entities"
+)
+
+// saveEntities replaces the stored hashtags and mentions of chirp with the ones
+// found in its body. Mentions of unknown usernames, and of users with a block
+// between them and the author, are dropped.
+func saveEntities(ctx context.Context, db *database.Queries, chirp database.Chirp) error {
+	if err := db.DeleteChirpHashtags(ctx, chirp.ID); err != nil {
+		return err
+	}
+	if err := db.DeleteChirpMentions(ctx, chirp.ID); err != nil {
+		return err
+	}
+
+	var hashtags database.InsertChirpHashtagsParams
+	var mentions []entities.Entity
+	for _, e := range entities.Parse(chirp.Body) {
+		switch e.Type {
+		case entities.TypeHashtag:
+			hashtags.Tags = append(hashtags.Tags, e.Text)
+			hashtags.StartOffsets = append(hashtags.StartOffsets, int32(e.Start))
+			hashtags.EndOffsets = append(hashtags.EndOffsets, int32(e.End))
+		case entities.TypeMention:
+			mentions = append(mentions, e)
+		}
+	}
+
+	if len(hashtags.Tags) > 0 {
+		hashtags.ChirpID = chirp.ID
+		if err := db.InsertChirpHashtags(ctx, hashtags); err != nil {
+			return err
+		}
+	}
+
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	usernames := make([]string, 0, len(mentions))
+	for _, m := range mentions {
+		usernames = append(usernames, strings.ToLower(m.Text))
+	}
+	users, err := db.GetUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uuid.UUID, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+	// Users with a block between them and the author are not linked, so the
+	// mention never reaches them.
+	blockedIDs, err := db.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+		UserID:  chirp.UserID,
+		UserIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+	blocked := make(map[uuid.UUID]struct{}, len(blockedIDs))
+	for _, id := range blockedIDs {
+		blocked[id] = struct{}{}
+	}
+
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		if _, ok := blocked[u.ID]; !ok {
+			userIDs[strings.ToLower(u.Username)] = u.ID
+		}
+	}
+
+	params := database.InsertChirpMentionsParams{ChirpID: chirp.ID}
+	for _, m := range mentions {
+		userID, ok := userIDs[strings.ToLower(m.Text)]
+		if !ok {
+			continue
+		}
+		params.UserIds = append(params.UserIds, userID)
+		params.Usernames = append(params.Usernames, m.Text)
+		params.StartOffsets = append(params.StartOffsets, int32(m.Start))
+		params.EndOffsets = append(params.EndOffsets, int32(m.End))
+	}
+	if len(params.UserIds) == 0 {
+		return nil
+	}
+	return db.InsertChirpMentions(ctx, params)
+}
+
+// fillEntities loads the stored hashtags and mentions of models.
+func fillEntities(ctx context.Context, db *database.Queries, models []*ChirpResponseModel) error {
+	ids := make([]uuid.UUID, 0, len(models))
+	byID := make(map[uuid.UUID][]*ChirpResponseModel, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+		byID[m.ID] = append(byID[m.ID], m)
+		m.Entities = []EntityResponseModel{}
+	}
+
+	hashtags, err := db.GetHashtagsByChirpIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	mentions, err := db.GetMentionsByChirpIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	found := make(map[uuid.UUID][]EntityResponseModel, len(models))
+	for _, h := range hashtags {
+		found[h.ChirpID] = append(found[h.ChirpID], EntityResponseModel{
+			Type:  entities.TypeHashtag,
+			Text:  h.Tag,
+			Start: h.StartOffset,
+			End:   h.EndOffset,
+		})
+	}
+	for _, m := range mentions {
+		userID := m.UserID
+		found[m.ChirpID] = append(found[m.ChirpID], EntityResponseModel{
+			Type:   entities.TypeMention,
+			Text:   m.Username,
+			Start:  m.StartOffset,
+			End:    m.EndOffset,
+			UserID: &userID,
+		})
+	}
+
+	for chirpID, list := range found {
+		slices.SortFunc(list, func(a, b EntityResponseModel) int {
+			return int(a.Start - b.Start)
+		})
+		for _, m := range byID[chirpID] {
+			m.Entities = list
+		}
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/activitypub"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+// maxActivitySize bounds the activities accepted by inboxes.
+const maxActivitySize = 1 << 20
+
+// Federation serves the ActivityPub endpoints other servers talk to, and the
+// API local users follow remote accounts with.
+type Federation struct {
+	db        *database.Queries
+	secretKey string
+	service   *activitypub.Service
+}
+
+func NewFederationHandler(db *database.Queries, secretKey string, service *activitypub.Service) *Federation {
+	return &Federation{db: db, secretKey: secretKey, service: service}
+}
+
+// WebFinger resolves acct: URIs of local users to their actors.
+func (h *Federation) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	if resource == "" {
+		http.Error(w, "Query parameter resource is required", http.StatusBadRequest)
+		return
+	}
+
+	jrd, err := h.service.WebFinger(r.Context(), resource)
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, "application/jrd+json", jrd)
+}
+
+func (h *Federation) GetActor(w http.ResponseWriter, r *http.Request) {
+	actor, err := h.service.Actor(r.Context(), r.PathValue("username"))
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, activitypub.ContentType, actor)
+}
+
+// GetOutbox serves the outbox collection, or with "page" one of its pages
+// ending before the chirp in "before".
+func (h *Federation) GetOutbox(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	query := r.URL.Query()
+	if query.Get("page") == "" {
+		outbox, err := h.service.Outbox(r.Context(), username)
+		if err != nil {
+			respondWithFederationError(w, err)
+			return
+		}
+		respondWithDocument(w, activitypub.ContentType, outbox)
+		return
+	}
+
+	var before uuid.UUID
+	if v := query.Get("before"); v != "" {
+		var err error
+		if before, err = uuid.Parse(v); err != nil {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.service.OutboxPage(r.Context(), username, before)
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, activitypub.ContentType, page)
+}
+
+func (h *Federation) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	collection, err := h.service.Followers(r.Context(), r.PathValue("username"))
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, activitypub.ContentType, collection)
+}
+
+func (h *Federation) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	collection, err := h.service.Following(r.Context(), r.PathValue("username"))
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, activitypub.ContentType, collection)
+}
+
+// GetObject serves a chirp as a Note, or a rechirp as an Announce.
+func (h *Federation) GetObject(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Chirp not found", http.StatusNotFound)
+		return
+	}
+
+	object, err := h.service.Object(r.Context(), chirpID)
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	respondWithDocument(w, activitypub.ContentType, object)
+}
+
+// PostInbox accepts an activity for a personal or the shared inbox. The
+// request must carry an HTTP Signature of the activity's actor.
+func (h *Federation) PostInbox(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxActivitySize))
+	if err != nil {
+		http.Error(w, "Activity too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := h.service.Inbox(r.Context(), r, body); err != nil {
+		if errors.Is(err, activitypub.ErrSignature) {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		respondWithFederationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// FollowRemote follows a remote account for the caller. The follow is
+// pending until the remote server accepts it.
+func (h *Federation) FollowRemote(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req RemoteFollowRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	actor, err := h.service.Follow(r.Context(), userID, req.Account)
+	if err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusAccepted, RemoteActorResponseModel{
+		ID:          actor.ID,
+		URI:         actor.URI,
+		Account:     actor.Username + "@" + actor.Domain,
+		DisplayName: actor.DisplayName,
+		URL:         actor.URL,
+		FollowedAt:  time.Now().UTC(),
+	})
+}
+
+// GetRemoteFollowing lists the remote actors the caller follows.
+func (h *Federation) GetRemoteFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	following, err := h.db.GetRemoteFollowing(r.Context(), userID)
+	if err != nil {
+		log.Println("Error retrieving remote follows:", err)
+		http.Error(w, "Failed to retrieve follows", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]RemoteActorResponseModel, 0, len(following))
+	for _, f := range following {
+		responses = append(responses, RemoteActorResponseModel{
+			ID:          f.ID,
+			URI:         f.URI,
+			Account:     f.Username + "@" + f.Domain,
+			DisplayName: f.DisplayName,
+			URL:         f.URL,
+			Accepted:    f.AcceptedAt.Valid,
+			FollowedAt:  f.CreatedAt,
+		})
+	}
+	utils.ResponseWithJSON(w, http.StatusOK, responses)
+}
+
+// UnfollowRemote withdraws the caller's follow of a remote actor.
+func (h *Federation) UnfollowRemote(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	actorID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid actor ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.Unfollow(r.Context(), userID, actorID); err != nil {
+		respondWithFederationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRemoteNotes lists the posts of the remote actors the caller follows,
+// newest first.
+func (h *Federation) GetRemoteNotes(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	notes, err := h.db.GetRemoteNotesForUser(r.Context(), database.GetRemoteNotesForUserParams{
+		UserID:            userID,
+		BeforePublishedAt: p.beforeAt(),
+		BeforeID:          p.beforeID(),
+		Limit:             p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving remote notes:", err)
+		http.Error(w, "Failed to retrieve notes", http.StatusInternalServerError)
+		return
+	}
+
+	resp := RemoteNoteListResponseModel{Notes: []RemoteNoteResponseModel{}}
+	for _, note := range notes {
+		resp.Notes = append(resp.Notes, RemoteNoteResponseModel{
+			ID:          note.ID,
+			URI:         note.URI,
+			Body:        note.Body,
+			URL:         note.URL,
+			InReplyTo:   note.InReplyTo,
+			ActorURI:    note.ActorURI,
+			Account:     note.Username + "@" + note.Domain,
+			DisplayName: note.DisplayName,
+			PublishedAt: note.PublishedAt,
+			UpdatedAt:   note.UpdatedAt,
+		})
+	}
+	if len(notes) > 0 {
+		last := notes[len(notes)-1]
+		resp.NextCursor = p.nextCursor(len(notes), pageCursor{At: last.PublishedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+func respondWithDocument(w http.ResponseWriter, contentType string, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		log.Println("Error encoding document:", err)
+		http.Error(w, "Failed to encode document", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+func respondWithFederationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, activitypub.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, activitypub.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, activitypub.ErrRemote):
+		log.Println("Error talking to a remote server:", err)
+		http.Error(w, "Remote server failed", http.StatusBadGateway)
+	default:
+		log.Println("Error handling federation request:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/feed"
+)
+
+const (
+	// maxFeedEntries is how many of the newest chirps a feed holds.
+	maxFeedEntries = 50
+	// maxFeedTitleLength bounds entry titles, which are taken from the body.
+	maxFeedTitleLength = 80
+	// feedMaxAge is how long readers and proxies may reuse a feed without
+	// asking again.
+	feedMaxAge = "max-age=300"
+)
+
+// Feed serves public chirps as Atom and RSS feeds. Feeds are anonymous, so
+// they only hold public chirps that are not hidden.
+type Feed struct {
+	db *database.Queries
+	// baseURL is the public URL of the server, used for links and feed IDs.
+	// When empty it is taken from each request.
+	baseURL string
+}
+
+func NewFeedHandler(db *database.Queries, baseURL string) *Feed {
+	return &Feed{db: db, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// GetUserFeed serves the chirps of a user, without their plain rechirps, as
+// /users/{username}/feed.atom or feed.rss.
+func (f *Feed) GetUserFeed(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimPrefix(r.PathValue("username"), "@")
+	if username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := f.db.GetPublicProfileByUsername(r.Context(), username)
+	if err != nil {
+		respondWithProfileError(w, err)
+		return
+	}
+
+	chirps, err := f.db.GetUserFeedChirps(r.Context(), database.GetUserFeedChirpsParams{
+		UserID: profile.ID,
+		Limit:  maxFeedEntries,
+	})
+	if err != nil {
+		log.Println("Error retrieving feed chirps:", err)
+		http.Error(w, "Failed to retrieve feed", http.StatusInternalServerError)
+		return
+	}
+
+	base := f.base(r)
+	name := profile.Username
+	if profile.DisplayName != "" {
+		name = profile.DisplayName
+	}
+	doc := feed.Feed{
+		// The user ID outlives a change of username.
+		ID:       "urn:uuid:" + profile.ID.String(),
+		Title:    name + " (@" + profile.Username + ") on Chirpy",
+		Subtitle: profile.Bio,
+		Link:     base + "/api/users/" + profile.ID.String(),
+		Self:     base + "/users/" + profile.Username + "/" + feedName(r),
+		// An empty feed last changed when the user signed up.
+		Updated: profile.CreatedAt.Time,
+		Entries: feedEntries(base, chirps),
+	}
+	f.serve(w, r, doc)
+}
+
+// GetHashtagFeed serves the chirps tagged with a hashtag as
+// /hashtags/{tag}/feed.atom or feed.rss.
+func (f *Feed) GetHashtagFeed(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		http.Error(w, "Hashtag is required", http.StatusBadRequest)
+		return
+	}
+
+	rows, err := f.db.GetHashtagFeedChirps(r.Context(), database.GetHashtagFeedChirpsParams{
+		Tag:   tag,
+		Limit: maxFeedEntries,
+	})
+	if err != nil {
+		log.Println("Error retrieving feed chirps:", err)
+		http.Error(w, "Failed to retrieve feed", http.StatusInternalServerError)
+		return
+	}
+	chirps := make([]database.GetUserFeedChirpsRow, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, database.GetUserFeedChirpsRow(row))
+	}
+
+	base := f.base(r)
+	doc := feed.Feed{
+		// Hashtags have no ID of their own, and their feed URL is stable.
+		ID:      base + "/hashtags/" + tag,
+		Title:   "#" + tag + " on Chirpy",
+		Link:    base + "/api/hashtags/" + tag + "/chirps",
+		Self:    base + "/hashtags/" + tag + "/" + feedName(r),
+		Updated: time.Unix(0, 0),
+		Entries: feedEntries(base, chirps),
+	}
+	f.serve(w, r, doc)
+}
+
+// serve renders doc in the format the request path asks for. The response
+// carries an ETag of its content and the time the newest entry changed as
+// Last-Modified, so readers polling with If-None-Match or If-Modified-Since
+// get 304 Not Modified until the feed changes. Deletions do not move
+// Last-Modified; only the ETag catches those.
+func (f *Feed) serve(w http.ResponseWriter, r *http.Request, doc feed.Feed) {
+	for _, e := range doc.Entries {
+		if e.Updated.After(doc.Updated) {
+			doc.Updated = e.Updated
+		}
+	}
+
+	var body []byte
+	var err error
+	if strings.HasSuffix(r.URL.Path, ".rss") {
+		body, err = doc.RSS()
+		w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	} else {
+		body, err = doc.Atom()
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	}
+	if err != nil {
+		log.Println("Error rendering feed:", err)
+		http.Error(w, "Failed to render feed", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, "+feedMaxAge)
+	// ServeContent answers conditional and HEAD requests. A zero or epoch
+	// modtime leaves out Last-Modified.
+	http.ServeContent(w, r, "", doc.Updated, bytes.NewReader(body))
+}
+
+// base returns the public URL of the server without a trailing slash.
+func (f *Feed) base(r *http.Request) string {
+	if f.baseURL != "" {
+		return f.baseURL
+	}
+	return requestBaseURL(r)
+}
+
+// requestBaseURL derives the public URL of the server from r, trusting
+// X-Forwarded-Proto from a TLS-terminating proxy.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedName returns the last segment of the request path, feed.atom or
+// feed.rss.
+func feedName(r *http.Request) string {
+	return r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+}
+
+func feedEntries(base string, chirps []database.GetUserFeedChirpsRow) []feed.Entry {
+	entries := make([]feed.Entry, 0, len(chirps))
+	for _, chirp := range chirps {
+		content := chirp.Body
+		if chirp.ReferenceID.Valid {
+			content += "\n\nQuoting " + base + "/api/chirps/" + chirp.ReferenceID.UUID.String()
+		}
+
+		author := "@" + chirp.Username
+		if chirp.DisplayName != "" {
+			author = chirp.DisplayName + " (" + author + ")"
+		}
+
+		updated := chirp.CreatedAt.Time
+		if chirp.UpdatedAt.Valid && chirp.UpdatedAt.Time.After(updated) {
+			updated = chirp.UpdatedAt.Time
+		}
+
+		entries = append(entries, feed.Entry{
+			// Chirp IDs never change, unlike the URLs chirps are served at.
+			ID:        "urn:uuid:" + chirp.ID.String(),
+			Title:     feedTitle(chirp.Body),
+			Link:      base + "/api/chirps/" + chirp.ID.String(),
+			Author:    author,
+			Content:   content,
+			Published: chirp.CreatedAt.Time,
+			Updated:   updated,
+		})
+	}
+	return entries
+}
+
+// feedTitle returns the first line of body, shortened to maxFeedTitleLength.
+func feedTitle(body string) string {
+	title, _, _ := strings.Cut(body, "\n")
+	shortened := truncate(title, maxFeedTitleLength-1)
+	if shortened != title {
+		return shortened + "…"
+	}
+	return title
+}
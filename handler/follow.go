@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+type Follow struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+	fanout    *timeline.Fanout
+	relay     *outbox.Relay
+}
+
+func NewFollowHandler(conn *sql.DB, db *database.Queries, secretKey string, fanout *timeline.Fanout, relay *outbox.Relay) *Follow {
+	return &Follow{conn: conn, db: db, secretKey: secretKey, fanout: fanout, relay: relay}
+}
+
+func (f *Follow) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, f.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	targetID, ok := f.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	if targetID == userID {
+		http.Error(w, "You cannot follow yourself", http.StatusBadRequest)
+		return
+	}
+
+	blocked, err := isBlocked(r.Context(), f.db, userID, targetID)
+	if err != nil {
+		log.Println("Error checking blocks:", err)
+		http.Error(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, "You cannot follow this user", http.StatusForbidden)
+		return
+	}
+
+	var created int64
+	err = withTx(r.Context(), f.conn, f.db, func(qtx *database.Queries) error {
+		var err error
+		created, err = qtx.CreateFollow(r.Context(), database.CreateFollowParams{
+			FollowerID: userID,
+			FolloweeID: targetID,
+		})
+		if err != nil || created == 0 {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, events.Event{Type: events.UserFollowed, ActorID: userID, UserID: targetID}); err != nil {
+			return err
+		}
+		return f.fanout.Followed(r.Context(), qtx, userID, targetID)
+	})
+	if err != nil {
+		log.Println("Error creating follow:", err)
+		http.Error(w, "Failed to follow user", http.StatusInternalServerError)
+		return
+	}
+
+	if created > 0 {
+		f.relay.Wake()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *Follow) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, f.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var deleted int64
+	err = withTx(r.Context(), f.conn, f.db, func(qtx *database.Queries) error {
+		var err error
+		deleted, err = qtx.DeleteFollow(r.Context(), database.DeleteFollowParams{
+			FollowerID: userID,
+			FolloweeID: targetID,
+		})
+		if err != nil || deleted == 0 {
+			return err
+		}
+		if err := outbox.Write(r.Context(), qtx, relationshipChanged(userID, targetID)); err != nil {
+			return err
+		}
+		return f.fanout.Unfollowed(r.Context(), qtx, userID, targetID)
+	})
+	if err != nil {
+		log.Println("Error deleting follow:", err)
+		http.Error(w, "Failed to unfollow user", http.StatusInternalServerError)
+		return
+	}
+
+	if deleted > 0 {
+		f.relay.Wake()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *Follow) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := f.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := f.db.GetFollowers(r.Context(), database.GetFollowersParams{
+		UserID:           userID,
+		BeforeFollowedAt: p.beforeAt(),
+		BeforeID:         p.beforeID(),
+		Limit:            p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving followers:", err)
+		http.Error(w, "Failed to retrieve followers", http.StatusInternalServerError)
+		return
+	}
+
+	count, err := f.db.CountFollowers(r.Context(), userID)
+	if err != nil {
+		log.Println("Error counting followers:", err)
+		http.Error(w, "Failed to retrieve followers", http.StatusInternalServerError)
+		return
+	}
+
+	resp := FollowListResponseModel{Users: []FollowUserResponseModel{}, Count: count}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, FollowUserResponseModel{
+			ID:         row.ID,
+			Username:   row.Username,
+			FollowedAt: row.FollowedAt,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.FollowedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+func (f *Follow) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	userID, ok := f.lookupUser(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := f.db.GetFollowing(r.Context(), database.GetFollowingParams{
+		UserID:           userID,
+		BeforeFollowedAt: p.beforeAt(),
+		BeforeID:         p.beforeID(),
+		Limit:            p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving following:", err)
+		http.Error(w, "Failed to retrieve following", http.StatusInternalServerError)
+		return
+	}
+
+	count, err := f.db.CountFollowing(r.Context(), userID)
+	if err != nil {
+		log.Println("Error counting following:", err)
+		http.Error(w, "Failed to retrieve following", http.StatusInternalServerError)
+		return
+	}
+
+	resp := FollowListResponseModel{Users: []FollowUserResponseModel{}, Count: count}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, FollowUserResponseModel{
+			ID:         row.ID,
+			Username:   row.Username,
+			FollowedAt: row.FollowedAt,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.FollowedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// lookupUser resolves the {id} path value to an existing user, writing the
+// error response itself when it cannot.
+func (f *Follow) lookupUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+
+	if _, err := f.db.GetUserByID(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return uuid.Nil, false
+		}
+
+		log.Println("Error retrieving user:", err)
+		http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+
+	return id, true
+}
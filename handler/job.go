@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+// Job lets moderators inspect the background job queue and retry or discard
+// dead jobs.
+type Job struct {
+	db        *database.Queries
+	secretKey string
+}
+
+func NewJobHandler(db *database.Queries, secretKey string) *Job {
+	return &Job{db: db, secretKey: secretKey}
+}
+
+// GetJobs lists jobs, newest first, optionally filtered by status and kind.
+func (j *Job) GetJobs(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r, j.db, j.secretKey); !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusDead:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	kind := r.URL.Query().Get("kind")
+
+	list, err := j.db.GetJobs(r.Context(), database.GetJobsParams{
+		Status:          sql.NullString{String: status, Valid: status != ""},
+		Kind:            sql.NullString{String: kind, Valid: kind != ""},
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error listing jobs:", err)
+		http.Error(w, "Failed to retrieve jobs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := JobListResponseModel{Jobs: []JobResponseModel{}}
+	for _, job := range list {
+		resp.Jobs = append(resp.Jobs, convertJobToResponseModel(job))
+	}
+	if len(list) > 0 {
+		last := list[len(list)-1]
+		resp.NextCursor = p.nextCursor(len(list), pageCursor{At: last.CreatedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// GetJobCounts counts jobs by kind and status.
+func (j *Job) GetJobCounts(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r, j.db, j.secretKey); !ok {
+		return
+	}
+
+	counts, err := j.db.GetJobCounts(r.Context())
+	if err != nil {
+		log.Println("Error counting jobs:", err)
+		http.Error(w, "Failed to count jobs", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]JobCountResponseModel, 0, len(counts))
+	for _, c := range counts {
+		resp = append(resp, JobCountResponseModel{Kind: c.Kind, Status: c.Status, Count: c.Count})
+	}
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+func (j *Job) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := j.jobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := j.db.GetJob(r.Context(), jobID)
+	if err != nil {
+		respondWithJobError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertJobToResponseModel(job))
+}
+
+// RetryJob runs a dead or pending job again right away, with its attempts
+// reset.
+func (j *Job) RetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := j.jobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := j.db.RequeueJob(r.Context(), jobID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			j.respondWithUnavailableJob(w, r, jobID)
+			return
+		}
+		if utils.IsUniqueViolation(err) {
+			http.Error(w, "A job with the same unique key is queued", http.StatusConflict)
+			return
+		}
+
+		log.Println("Error retrying job:", err)
+		http.Error(w, "Failed to retry job", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertJobToResponseModel(job))
+}
+
+// DiscardJob deletes a dead or pending job.
+func (j *Job) DiscardJob(w http.ResponseWriter, r *http.Request) {
+	jobID, ok := j.jobRequest(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := j.db.DiscardJob(r.Context(), jobID)
+	if err != nil {
+		log.Println("Error discarding job:", err)
+		http.Error(w, "Failed to discard job", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		j.respondWithUnavailableJob(w, r, jobID)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (j *Job) jobRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	if _, ok := requireModerator(w, r, j.db, j.secretKey); !ok {
+		return uuid.Nil, false
+	}
+
+	jobID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid job ID", http.StatusBadRequest)
+		return uuid.Nil, false
+	}
+	return jobID, true
+}
+
+// respondWithUnavailableJob explains why a job could not be retried or
+// discarded.
+func (j *Job) respondWithUnavailableJob(w http.ResponseWriter, r *http.Request, jobID uuid.UUID) {
+	job, err := j.db.GetJob(r.Context(), jobID)
+	if err != nil {
+		respondWithJobError(w, err)
+		return
+	}
+	http.Error(w, "Job is "+job.Status, http.StatusConflict)
+}
+
+func respondWithJobError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Error retrieving job:", err)
+	http.Error(w, "Failed to retrieve job", http.StatusInternalServerError)
+}
+
+func convertJobToResponseModel(job database.Job) JobResponseModel {
+	resp := JobResponseModel{
+		ID:          job.ID,
+		Kind:        job.Kind,
+		Payload:     json.RawMessage(job.Payload),
+		Status:      job.Status,
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt,
+		LastError:   job.LastError.String,
+		UniqueKey:   job.UniqueKey.String,
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+	}
+	if job.LockedUntil.Valid {
+		resp.LockedUntil = &job.LockedUntil.Time
+	}
+	if job.FinishedAt.Valid {
+		resp.FinishedAt = &job.FinishedAt.Time
+	}
+	return resp
+}
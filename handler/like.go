@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+type Like struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+	relay     *outbox.Relay
+}
+
+func NewLikeHandler(conn *sql.DB, db *database.Queries, secretKey string, relay *outbox.Relay) *Like {
+	return &Like{conn: conn, db: db, secretKey: secretKey, relay: relay}
+}
+
+func (l *Like) LikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, l.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirp, ok := l.lookupChirp(w, r, userID)
+	if !ok {
+		return
+	}
+
+	err = withTx(r.Context(), l.conn, l.db, func(qtx *database.Queries) error {
+		liked, err := qtx.LikeChirp(r.Context(), database.LikeChirpParams{
+			UserID:  userID,
+			ChirpID: chirp.ID,
+		})
+		if err != nil || liked == 0 {
+			return err
+		}
+		return outbox.Write(r.Context(), qtx, events.Event{Type: events.ChirpLiked, ActorID: userID, UserID: chirp.UserID, ChirpID: chirp.ID})
+	})
+	if err != nil {
+		log.Println("Error liking chirp:", err)
+		http.Error(w, "Failed to like chirp", http.StatusInternalServerError)
+		return
+	}
+	l.relay.Wake()
+
+	l.respondWithLikeState(w, r, chirp.ID, true)
+}
+
+func (l *Like) UnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, l.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirp, ok := l.lookupChirp(w, r, userID)
+	if !ok {
+		return
+	}
+
+	if _, err := l.db.UnlikeChirp(r.Context(), database.UnlikeChirpParams{
+		UserID:  userID,
+		ChirpID: chirp.ID,
+	}); err != nil {
+		log.Println("Error unliking chirp:", err)
+		http.Error(w, "Failed to unlike chirp", http.StatusInternalServerError)
+		return
+	}
+
+	l.respondWithLikeState(w, r, chirp.ID, false)
+}
+
+func (l *Like) GetLikers(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := viewer(r, l.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirp, ok := l.lookupChirp(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := l.db.GetChirpLikers(r.Context(), database.GetChirpLikersParams{
+		ChirpID:       chirp.ID,
+		BeforeLikedAt: p.beforeAt(),
+		BeforeID:      p.beforeID(),
+		Limit:         p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving likers:", err)
+		http.Error(w, "Failed to retrieve likers", http.StatusInternalServerError)
+		return
+	}
+
+	resp := LikerListResponseModel{Users: []LikerResponseModel{}, Count: chirp.LikeCount}
+	for _, row := range rows {
+		resp.Users = append(resp.Users, LikerResponseModel{
+			ID:       row.ID,
+			Username: row.Username,
+			LikedAt:  row.LikedAt,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.LikedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// respondWithLikeState writes the chirp's like count as it is after the
+// caller's like or unlike.
+func (l *Like) respondWithLikeState(w http.ResponseWriter, r *http.Request, chirpID uuid.UUID, liked bool) {
+	chirp, err := l.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, LikeResponseModel{
+		ChirpID:   chirp.ID,
+		LikeCount: chirp.LikeCount,
+		LikedByMe: liked,
+	})
+}
+
+// lookupChirp resolves the {id} path value to a chirp that viewerID may see,
+// writing the error response itself when it cannot.
+func (l *Like) lookupChirp(w http.ResponseWriter, r *http.Request, viewerID uuid.UUID) (database.Chirp, bool) {
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return database.Chirp{}, false
+	}
+
+	chirp, err := l.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return database.Chirp{}, false
+		}
+
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return database.Chirp{}, false
+	}
+
+	visible, err := visibility.CanSee(r.Context(), l.db, viewerID, chirp)
+	if err != nil {
+		log.Println("Error checking chirp visibility:", err)
+		http.Error(w, "Failed to retrieve chirp", http.StatusInternalServerError)
+		return database.Chirp{}, false
+	}
+	if !visible {
+		http.Error(w, "Chirp not found", http.StatusNotFound)
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
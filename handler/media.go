@@ -0,0 +1,491 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/imaging"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	"github.com/jacosy/go-web-server/internal/storage"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+const (
+	maxMediaBytes    = 10 << 20
+	maxMediaPerChirp = 4
+	maxAltTextLength = 1000
+
+	// Uploads are scaled down to fit mediaMaxSide; thumbnails to
+	// thumbnailMaxSide.
+	mediaMaxSide     = 2048
+	thumbnailMaxSide = 400
+)
+
+var errInvalidMedia = errors.New("unknown or already attached media")
+
+type Media struct {
+	conn       *sql.DB
+	db         *database.Queries
+	secretKey  string
+	blobs      storage.Storage
+	quotaBytes int64
+}
+
+// NewMediaHandler returns a handler for image uploads. Each user may store up
+// to quotaBytes, counting both the full-size images and their thumbnails.
+func NewMediaHandler(conn *sql.DB, db *database.Queries, secretKey string, blobs storage.Storage, quotaBytes int64) *Media {
+	return &Media{conn: conn, db: db, secretKey: secretKey, blobs: blobs, quotaBytes: quotaBytes}
+}
+
+// UploadMedia stores the image in the "file" field of a multipart form,
+// together with the optional "alt_text" field, and returns its ID for use in
+// ChirptRequestModel.MediaIDs. The image is re-encoded, which drops its EXIF
+// metadata.
+func (m *Media) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, m.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Expected an image in the file form field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	altText := strings.TrimSpace(r.FormValue("alt_text"))
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		http.Error(w, fmt.Sprintf("Alt text exceeds %d characters", maxAltTextLength), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxMediaBytes {
+		http.Error(w, fmt.Sprintf("Image exceeds %d bytes", maxMediaBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	img, contentType, err := imaging.Decode(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedType) {
+			http.Error(w, "Media must be a JPEG, PNG or GIF image", http.StatusUnsupportedMediaType)
+			return
+		}
+		http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	full := imaging.Fit(img, mediaMaxSide)
+	var fullBuf, thumbBuf bytes.Buffer
+	storedType, err := imaging.Encode(&fullBuf, full, contentType)
+	if err == nil {
+		_, err = imaging.Encode(&thumbBuf, imaging.Fit(img, thumbnailMaxSide), contentType)
+	}
+	if err != nil {
+		log.Println("Error encoding media:", err)
+		http.Error(w, "Failed to process image", http.StatusInternalServerError)
+		return
+	}
+
+	size := int64(fullBuf.Len() + thumbBuf.Len())
+	if size > m.quotaBytes {
+		http.Error(w, "Media storage quota exceeded", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ext := ".png"
+	if storedType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	base := fmt.Sprintf("media/%s/%s", userID, uuid.NewString())
+	key, thumbKey := base+ext, base+"_thumb"+ext
+
+	if err := m.blobs.Put(r.Context(), key, &fullBuf, storedType); err != nil {
+		log.Println("Error storing media:", err)
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+	if err := m.blobs.Put(r.Context(), thumbKey, &thumbBuf, storedType); err != nil {
+		log.Println("Error storing thumbnail:", err)
+		purgeBlobsOrLog(r.Context(), m.db, key)
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+
+	bounds := full.Bounds()
+	var media database.MediaAttachment
+	err = withTx(r.Context(), m.conn, m.db, func(qtx *database.Queries) error {
+		if err := qtx.LockMediaQuota(r.Context(), userID); err != nil {
+			return err
+		}
+		var err error
+		media, err = qtx.CreateMediaAttachment(r.Context(), database.CreateMediaAttachmentParams{
+			UserID:       userID,
+			StorageKey:   key,
+			ThumbnailKey: thumbKey,
+			ContentType:  storedType,
+			Width:        int32(bounds.Dx()),
+			Height:       int32(bounds.Dy()),
+			SizeBytes:    size,
+			AltText:      altText,
+			QuotaBytes:   m.quotaBytes,
+		})
+		return err
+	})
+	if err != nil {
+		purgeBlobsOrLog(r.Context(), m.db, key, thumbKey)
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Media storage quota exceeded", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		log.Println("Error creating media:", err)
+		http.Error(w, "Failed to store image", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, convertMediaToResponseModel(media))
+}
+
+// GetMedia serves the full-size image of the {id} path value.
+func (m *Media) GetMedia(w http.ResponseWriter, r *http.Request) {
+	m.serve(w, r, func(media database.MediaAttachment) string { return media.StorageKey })
+}
+
+// GetThumbnail serves the thumbnail of the {id} path value.
+func (m *Media) GetThumbnail(w http.ResponseWriter, r *http.Request) {
+	m.serve(w, r, func(media database.MediaAttachment) string { return media.ThumbnailKey })
+}
+
+// GetUsage reports how much of their media quota the caller has used.
+func (m *Media) GetUsage(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, m.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	used, err := m.db.GetMediaUsage(r.Context(), userID)
+	if err != nil {
+		log.Println("Error retrieving media usage:", err)
+		http.Error(w, "Failed to retrieve media usage", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, MediaUsageResponseModel{
+		UsedBytes:  used,
+		QuotaBytes: m.quotaBytes,
+	})
+}
+
+// UpdateMedia replaces the alt text of one of the caller's uploads.
+func (m *Media) UpdateMedia(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, m.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mediaID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	var req MediaRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.AltText = strings.TrimSpace(req.AltText)
+	if utf8.RuneCountInString(req.AltText) > maxAltTextLength {
+		http.Error(w, fmt.Sprintf("Alt text exceeds %d characters", maxAltTextLength), http.StatusBadRequest)
+		return
+	}
+
+	media, err := m.db.UpdateMediaAltText(r.Context(), database.UpdateMediaAltTextParams{
+		AltText: req.AltText,
+		ID:      mediaID,
+		UserID:  userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error updating media:", err)
+		http.Error(w, "Failed to update media", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertMediaToResponseModel(media))
+}
+
+// DeleteMedia removes one of the caller's uploads that is not attached to a
+// chirp. Attached media goes away with its chirp.
+func (m *Media) DeleteMedia(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, m.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mediaID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := m.db.DeleteUnattachedMedia(r.Context(), database.DeleteUnattachedMediaParams{
+		ID:     mediaID,
+		UserID: userID,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Media not found or attached to a chirp", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error deleting media:", err)
+		http.Error(w, "Failed to delete media", http.StatusInternalServerError)
+		return
+	}
+
+	purgeBlobsOrLog(r.Context(), m.db, media.StorageKey, media.ThumbnailKey)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serve writes the blob of an upload chosen by key. Attached media is as
+// visible as its chirp, and unattached uploads only to their owner.
+func (m *Media) serve(w http.ResponseWriter, r *http.Request, key func(database.MediaAttachment) string) {
+	viewerID, err := viewer(r, m.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	mediaID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+
+	media, err := m.db.GetMediaAttachmentByID(r.Context(), mediaID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving media:", err)
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+
+	public, visible, err := m.mediaAudience(r.Context(), viewerID, media)
+	if err != nil {
+		log.Println("Error checking media visibility:", err)
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+
+	blob, err := m.blobs.Get(r.Context(), key(media))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Media not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error reading media:", err)
+		http.Error(w, "Failed to retrieve media", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// Stored blobs never change, but the chirp they belong to can be hidden
+	// or deleted, so shared caches only keep public media for a while.
+	cacheControl := "private, max-age=3600"
+	if public {
+		cacheControl = "public, max-age=3600"
+	}
+	w.Header().Set("Content-Type", media.ContentType)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// mediaAudience reports whether anyone may see media, and whether viewerID
+// may.
+func (m *Media) mediaAudience(ctx context.Context, viewerID uuid.UUID, media database.MediaAttachment) (public, visible bool, err error) {
+	if !media.ChirpID.Valid {
+		return false, viewerID != uuid.Nil && viewerID == media.UserID, nil
+	}
+
+	chirp, err := m.db.GetChirpByID(ctx, media.ChirpID.UUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	visible, err = visibility.CanSee(ctx, m.db, viewerID, chirp)
+	if err != nil {
+		return false, false, err
+	}
+	public = visibility.IsPublic(chirp)
+	return public, visible, nil
+}
+
+// attachMedia attaches the caller's uploads with the given IDs to chirpID.
+// It returns errInvalidMedia unless every ID names an unattached upload of
+// userID.
+func attachMedia(ctx context.Context, db *database.Queries, chirpID, userID uuid.UUID, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	n, err := db.AttachMedia(ctx, database.AttachMediaParams{
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+		Ids:     ids,
+		UserID:  userID,
+	})
+	if err != nil {
+		return err
+	}
+	if n != int64(len(ids)) {
+		return errInvalidMedia
+	}
+	return nil
+}
+
+// validateMediaIDs checks the media IDs of a chirp request.
+func validateMediaIDs(ids []uuid.UUID) error {
+	if len(ids) > maxMediaPerChirp {
+		return fmt.Errorf("a chirp can have at most %d media attachments", maxMediaPerChirp)
+	}
+
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			return errors.New("duplicate media ID")
+		}
+		seen[id] = struct{}{}
+	}
+	return nil
+}
+
+// fillMedia loads the attachments of models.
+func fillMedia(ctx context.Context, db *database.Queries, models []*ChirpResponseModel) error {
+	ids := make([]uuid.UUID, 0, len(models))
+	byID := make(map[uuid.UUID][]*ChirpResponseModel, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+		byID[m.ID] = append(byID[m.ID], m)
+		m.Media = []MediaResponseModel{}
+	}
+
+	attachments, err := db.GetMediaAttachmentsByChirpIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		for _, m := range byID[a.ChirpID.UUID] {
+			m.Media = append(m.Media, convertMediaToResponseModel(a))
+		}
+	}
+	return nil
+}
+
+// jobPurgeBlobs is the kind of the job that deletes stored files.
+const jobPurgeBlobs = "blobs.purge"
+
+type purgeBlobsPayload struct {
+	Keys []string `json:"keys"`
+}
+
+// RegisterJobs registers the handlers of the jobs that handlers enqueue.
+func RegisterJobs(queue *jobs.Queue, blobs storage.Storage) {
+	queue.Register(jobPurgeBlobs, func(ctx context.Context, job jobs.Job) error {
+		var payload purgeBlobsPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return jobs.Permanent(err)
+		}
+		for _, key := range payload.Keys {
+			if err := blobs.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// purgeMediaBlobs enqueues the removal of the stored files of attachments
+// whose rows are deleted.
+func purgeMediaBlobs(ctx context.Context, db *database.Queries, attachments []database.MediaAttachment) error {
+	var keys []string
+	for _, a := range attachments {
+		keys = append(keys, a.StorageKey, a.ThumbnailKey)
+	}
+	return purgeBlobs(ctx, db, keys...)
+}
+
+// purgeBlobs enqueues the removal of keys from storage, which is retried
+// until it succeeds. Pass the Queries of the transaction that drops the last
+// reference to the keys.
+func purgeBlobs(ctx context.Context, db *database.Queries, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := jobs.Enqueue(ctx, db, jobPurgeBlobs, purgeBlobsPayload{Keys: keys}, jobs.Options{})
+	return err
+}
+
+// purgeBlobsOrLog is purgeBlobs for when the response does not depend on it.
+// A failure only leaves orphaned files behind.
+func purgeBlobsOrLog(ctx context.Context, db *database.Queries, keys ...string) {
+	if err := purgeBlobs(ctx, db, keys...); err != nil {
+		log.Printf("Failed to enqueue the purge of blobs %v: %v", keys, err)
+	}
+}
+
+func convertMediaToResponseModel(media database.MediaAttachment) MediaResponseModel {
+	url := path.Join("/api/media", media.ID.String())
+	return MediaResponseModel{
+		ID:           media.ID,
+		URL:          url,
+		ThumbnailURL: url + "/thumbnail",
+		ContentType:  media.ContentType,
+		Width:        media.Width,
+		Height:       media.Height,
+		AltText:      media.AltText,
+	}
+}
@@ -1,13 +1,55 @@
 package handler
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type ChirptRequestModel struct {
-	Body string `json:"body"`
+	Body          string     `json:"body"`
+	QuotedChirpID *uuid.UUID `json:"quoted_chirp_id,omitempty"`
+	// MediaIDs are uploads from POST /api/media, in display order.
+	MediaIDs []uuid.UUID `json:"media_ids,omitempty"`
+	// Poll, if set, attaches a poll to the chirp.
+	Poll *PollRequestModel `json:"poll,omitempty"`
+	// Visibility is "public" (the default), "followers" or "unlisted".
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// DraftRequestModel is a chirp to publish later. When PublishAt is set the
+// draft is published automatically at that time.
+type DraftRequestModel struct {
+	Body          string      `json:"body"`
+	QuotedChirpID *uuid.UUID  `json:"quoted_chirp_id,omitempty"`
+	MediaIDs      []uuid.UUID `json:"media_ids,omitempty"`
+	PublishAt     *time.Time  `json:"publish_at,omitempty"`
+	Visibility    string      `json:"visibility,omitempty"`
+}
+
+// DraftResponseModel is a draft of the caller. LastError is set when a
+// scheduled publish failed, in which case the draft is no longer scheduled.
+type DraftResponseModel struct {
+	ID            uuid.UUID   `json:"id"`
+	Body          string      `json:"body"`
+	QuotedChirpID *uuid.UUID  `json:"quoted_chirp_id,omitempty"`
+	MediaIDs      []uuid.UUID `json:"media_ids"`
+	PublishAt     *time.Time  `json:"publish_at,omitempty"`
+	Visibility    string      `json:"visibility"`
+	LastError     string      `json:"last_error,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+type PollRequestModel struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+type PollVoteRequestModel struct {
+	// Option is the position of the chosen option, starting at 0.
+	Option int32 `json:"option"`
 }
 
 type ChirpResponseModel struct {
@@ -16,4 +58,432 @@ type ChirpResponseModel struct {
 	Body      string    `json:"body"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	LikeCount int32     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+
+	RechirpCount  int32 `json:"rechirp_count"`
+	QuoteCount    int32 `json:"quote_count"`
+	RechirpedByMe bool  `json:"rechirped_by_me"`
+
+	// ReferenceKind is "rechirp" or "quote" for chirps that repost another
+	// one. ReferenceUnavailable is set when that chirp has been deleted.
+	ReferenceKind        string              `json:"reference_kind,omitempty"`
+	ReferencedChirp      *ChirpResponseModel `json:"referenced_chirp,omitempty"`
+	ReferenceUnavailable bool                `json:"reference_unavailable,omitempty"`
+
+	Visibility string `json:"visibility"`
+
+	// Hidden is set on chirps a moderator has hidden. Only their author still
+	// sees them.
+	Hidden bool `json:"hidden,omitempty"`
+
+	Entities []EntityResponseModel `json:"entities"`
+	Media    []MediaResponseModel  `json:"media"`
+	Poll     *PollResponseModel    `json:"poll,omitempty"`
+}
+
+// PollResponseModel holds the live tallies of a poll. Once Closed is set no
+// more votes are accepted, so the tallies are final. VotedOption is the
+// caller's choice, if they have voted.
+type PollResponseModel struct {
+	ClosesAt    time.Time                 `json:"closes_at"`
+	Closed      bool                      `json:"closed"`
+	TotalVotes  int64                     `json:"total_votes"`
+	Options     []PollOptionResponseModel `json:"options"`
+	VotedOption *int32                    `json:"voted_option,omitempty"`
+}
+
+type PollOptionResponseModel struct {
+	Position int32  `json:"position"`
+	Text     string `json:"text"`
+	Votes    int32  `json:"votes"`
+}
+
+// MediaResponseModel describes an uploaded image. URL and ThumbnailURL are
+// relative to the API host.
+type MediaResponseModel struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	AltText      string    `json:"alt_text"`
+}
+
+type MediaRequestModel struct {
+	AltText string `json:"alt_text"`
+}
+
+type MediaUsageResponseModel struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// EntityResponseModel locates a hashtag or mention in a chirp body. Start and
+// End are offsets in Unicode code points, End exclusive.
+type EntityResponseModel struct {
+	Type   string     `json:"type"`
+	Text   string     `json:"text"`
+	Start  int32      `json:"start"`
+	End    int32      `json:"end"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+}
+
+type FollowUserResponseModel struct {
+	ID         uuid.UUID `json:"id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowListResponseModel struct {
+	Users      []FollowUserResponseModel `json:"users"`
+	Count      int64                     `json:"count"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+}
+
+// RelationUserResponseModel is an entry of the caller's block or mute list.
+type RelationUserResponseModel struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RelationListResponseModel struct {
+	Users      []RelationUserResponseModel `json:"users"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+}
+
+type ChirpListResponseModel struct {
+	Chirps     []ChirpResponseModel `json:"chirps"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type LikeResponseModel struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	LikeCount int32     `json:"like_count"`
+	LikedByMe bool      `json:"liked_by_me"`
+}
+
+type LikerResponseModel struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	LikedAt  time.Time `json:"liked_at"`
+}
+
+type LikerListResponseModel struct {
+	Users      []LikerResponseModel `json:"users"`
+	Count      int32                `json:"count"`
+	NextCursor string               `json:"next_cursor,omitempty"`
+}
+
+type SearchResultResponseModel struct {
+	Chirp ChirpResponseModel `json:"chirp"`
+	Rank  float32            `json:"rank"`
+	// Snippet is an HTML excerpt of the body: the text is escaped and
+	// matches are wrapped in <mark> tags.
+	Snippet string `json:"snippet"`
+}
+
+type SearchResponseModel struct {
+	Results    []SearchResultResponseModel `json:"results"`
+	NextCursor string                      `json:"next_cursor,omitempty"`
+}
+
+// PublicProfileResponseModel is what anyone may see about a user.
+type PublicProfileResponseModel struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	JoinedAt    time.Time `json:"joined_at"`
+	ChirpCount  int64     `json:"chirp_count"`
+	// Avatar maps a size name ("small", "medium", "large") to its URL.
+	Avatar map[string]string `json:"avatar,omitempty"`
+}
+
+type ProfileRequestModel struct {
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+}
+
+type UserSummaryResponseModel struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+}
+
+// ReportRequestModel reports a chirp, or with UserID alone, a user.
+type ReportRequestModel struct {
+	ChirpID  *uuid.UUID `json:"chirp_id,omitempty"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	Category string     `json:"category"`
+	Details  string     `json:"details"`
+}
+
+// ResolveReportRequestModel closes a report. Action is dismiss, hide_chirp,
+// warn or suspend; SuspendDays is required for suspend.
+type ResolveReportRequestModel struct {
+	Action      string `json:"action"`
+	Note        string `json:"note"`
+	SuspendDays int    `json:"suspend_days,omitempty"`
+}
+
+// ReportResponseModel is a report in the moderation queue. ReporterID is
+// empty for reports raised by the moderation pipeline.
+type ReportResponseModel struct {
+	ID         uuid.UUID                       `json:"id"`
+	ReporterID *uuid.UUID                      `json:"reporter_id,omitempty"`
+	UserID     uuid.UUID                       `json:"user_id"`
+	ChirpID    *uuid.UUID                      `json:"chirp_id,omitempty"`
+	Category   string                          `json:"category"`
+	Details    string                          `json:"details"`
+	Status     string                          `json:"status"`
+	ClaimedBy  *uuid.UUID                      `json:"claimed_by,omitempty"`
+	Resolution string                          `json:"resolution,omitempty"`
+	ResolvedAt *time.Time                      `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time                       `json:"created_at"`
+	Actions    []ModerationActionResponseModel `json:"actions,omitempty"`
+}
+
+type ReportListResponseModel struct {
+	Reports    []ReportResponseModel `json:"reports"`
+	NextOffset *int32                `json:"next_offset,omitempty"`
+}
+
+// ModerationActionResponseModel is an entry of the moderation audit trail.
+type ModerationActionResponseModel struct {
+	ID          uuid.UUID  `json:"id"`
+	ModeratorID *uuid.UUID `json:"moderator_id,omitempty"`
+	ReportID    *uuid.UUID `json:"report_id,omitempty"`
+	ChirpID     *uuid.UUID `json:"chirp_id,omitempty"`
+	Action      string     `json:"action"`
+	Note        string     `json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ConversationRequestModel starts a conversation between the caller and
+// MemberIDs. A single member makes a one-to-one conversation, which is reused
+// if the two users already have one.
+type ConversationRequestModel struct {
+	MemberIDs []uuid.UUID `json:"member_ids"`
+}
+
+type ConversationMemberResponseModel struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+// ConversationResponseModel is a conversation as seen by one of its members.
+// UnreadCount counts the messages from others since the caller last marked
+// it read.
+type ConversationResponseModel struct {
+	ID          uuid.UUID                         `json:"id"`
+	Group       bool                              `json:"group"`
+	Members     []ConversationMemberResponseModel `json:"members"`
+	LastMessage *MessageResponseModel             `json:"last_message,omitempty"`
+	UnreadCount int64                             `json:"unread_count"`
+	LastReadAt  *time.Time                        `json:"last_read_at,omitempty"`
+	CreatedAt   time.Time                         `json:"created_at"`
+	UpdatedAt   time.Time                         `json:"updated_at"`
+}
+
+type ConversationListResponseModel struct {
+	Conversations []ConversationResponseModel `json:"conversations"`
+	NextCursor    string                      `json:"next_cursor,omitempty"`
+}
+
+type MessageRequestModel struct {
+	Body string `json:"body"`
+}
+
+type MessageResponseModel struct {
+	ID             uuid.UUID `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type MessageListResponseModel struct {
+	Messages   []MessageResponseModel `json:"messages"`
+	NextCursor string                 `json:"next_cursor,omitempty"`
+}
+
+type NotificationActorResponseModel struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+// NotificationResponseModel groups the unread events about one item. Actors
+// holds the most recent actors and ActorCount counts all of them.
+type NotificationResponseModel struct {
+	ID         uuid.UUID                        `json:"id"`
+	Kind       string                           `json:"kind"`
+	ChirpID    *uuid.UUID                       `json:"chirp_id,omitempty"`
+	Actors     []NotificationActorResponseModel `json:"actors"`
+	ActorCount int64                            `json:"actor_count"`
+	Summary    string                           `json:"summary"`
+	Read       bool                             `json:"read"`
+	CreatedAt  time.Time                        `json:"created_at"`
+	UpdatedAt  time.Time                        `json:"updated_at"`
+}
+
+type NotificationListResponseModel struct {
+	Notifications []NotificationResponseModel `json:"notifications"`
+	UnreadCount   int64                       `json:"unread_count"`
+	NextCursor    string                      `json:"next_cursor,omitempty"`
+}
+
+// SocketCommandModel is a frame sent by a WebSocket client. Type is auth,
+// subscribe, unsubscribe or send; ID is echoed in the reply. Target names the
+// chirp of a thread channel and the conversation of a conversation channel or
+// a send. Since resumes a subscription after the given event sequence number.
+type SocketCommandModel struct {
+	ID      string    `json:"id,omitempty"`
+	Type    string    `json:"type"`
+	Token   string    `json:"token,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Target  uuid.UUID `json:"target"`
+	Since   uint64    `json:"since,omitempty"`
+	Body    string    `json:"body,omitempty"`
+}
+
+// SocketEventModel is a frame sent to a WebSocket client. Type is ack or
+// error in reply to a command, event for an update on a subscription, or
+// reset when a subscription could not be resumed and has to be refetched.
+type SocketEventModel struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Channel string          `json:"channel,omitempty"`
+	Target  *uuid.UUID      `json:"target,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// WebhookRequestModel creates or replaces a webhook. Enabled re-enables a
+// disabled webhook, or disables one; it is left as is when omitted.
+type WebhookRequestModel struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Enabled *bool    `json:"enabled,omitempty"`
+}
+
+// WebhookResponseModel is a webhook of the caller. Secret is only returned
+// when the webhook is created.
+type WebhookResponseModel struct {
+	ID                  uuid.UUID  `json:"id"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Enabled             bool       `json:"enabled"`
+	Secret              string     `json:"secret,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// WebhookDeliveryResponseModel is one event sent to a webhook. Status is
+// pending, succeeded or failed; NextAttemptAt is set while it is pending.
+type WebhookDeliveryResponseModel struct {
+	ID             uuid.UUID       `json:"id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int32          `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type WebhookDeliveryListResponseModel struct {
+	Deliveries []WebhookDeliveryResponseModel `json:"deliveries"`
+	NextCursor string                         `json:"next_cursor,omitempty"`
+}
+
+// JobResponseModel is a background job as shown to moderators.
+type JobResponseModel struct {
+	ID          uuid.UUID       `json:"id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	UniqueKey   string          `json:"unique_key,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+}
+
+type JobListResponseModel struct {
+	Jobs       []JobResponseModel `json:"jobs"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// OutboxEventResponseModel is an event the outbox gave up on, as shown to
+// moderators.
+type OutboxEventResponseModel struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int32           `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	FailedAt  *time.Time      `json:"failed_at,omitempty"`
+}
+
+type OutboxEventListResponseModel struct {
+	Events     []OutboxEventResponseModel `json:"events"`
+	NextCursor string                     `json:"next_cursor,omitempty"`
+}
+
+// JobCountResponseModel counts the jobs of one kind in one status.
+type JobCountResponseModel struct {
+	Kind   string `json:"kind"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+type RemoteFollowRequestModel struct {
+	// Account is the remote account to follow, such as user@example.com.
+	Account string `json:"account"`
+}
+
+// RemoteActorResponseModel is an actor of another server followed by the
+// caller. Accepted is false until its server accepts the follow.
+type RemoteActorResponseModel struct {
+	ID          uuid.UUID `json:"id"`
+	URI         string    `json:"uri"`
+	Account     string    `json:"account"`
+	DisplayName string    `json:"display_name"`
+	URL         string    `json:"url,omitempty"`
+	Accepted    bool      `json:"accepted"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+// RemoteNoteResponseModel is a post of a followed remote actor.
+type RemoteNoteResponseModel struct {
+	ID          uuid.UUID `json:"id"`
+	URI         string    `json:"uri"`
+	Body        string    `json:"body"`
+	URL         string    `json:"url,omitempty"`
+	InReplyTo   string    `json:"in_reply_to,omitempty"`
+	ActorURI    string    `json:"actor_uri"`
+	Account     string    `json:"account"`
+	DisplayName string    `json:"display_name"`
+	PublishedAt time.Time `json:"published_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RemoteNoteListResponseModel struct {
+	Notes      []RemoteNoteResponseModel `json:"notes"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
 }
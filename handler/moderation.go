@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/moderation"
+)
+
+// moderateBody runs body through the moderation pipeline. It returns the
+// masked body and the matches to flag for review, or a *requestError if the
+// body is rejected.
+func moderateBody(moderator *moderation.Pipeline, body string) (string, []moderation.Match, error) {
+	result := moderator.Moderate(body)
+	if m, ok := result.Rejection(); ok {
+		return "", nil, badRequest("Chirp rejected by moderation rule %q", m.Rule)
+	}
+	return result.Text, result.Flags(), nil
+}
+
+// saveFlags records the moderation flags raised by chirp's body and puts the
+// chirp in the moderation queue.
+func saveFlags(ctx context.Context, db *database.Queries, chirp database.Chirp, flags []moderation.Match) error {
+	if len(flags) == 0 {
+		return nil
+	}
+
+	params := database.InsertModerationFlagsParams{ChirpID: chirp.ID}
+	var details []string
+	for _, f := range flags {
+		params.Rules = append(params.Rules, f.Rule)
+		params.Excerpts = append(params.Excerpts, f.Text)
+		details = append(details, fmt.Sprintf("%s: %q", f.Rule, f.Text))
+	}
+	if err := db.InsertModerationFlags(ctx, params); err != nil {
+		return err
+	}
+
+	_, err := db.CreateReport(ctx, database.CreateReportParams{
+		UserID:   chirp.UserID,
+		ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		Category: categoryAutomated,
+		Details:  truncate(strings.Join(details, "; "), maxReportDetailsLength),
+	})
+	return err
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/notifications"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+// notificationActorsShown is how many actors of a grouped notification are
+// listed by name.
+const notificationActorsShown = 3
+
+type Notification struct {
+	db        *database.Queries
+	secretKey string
+}
+
+func NewNotificationHandler(db *database.Queries, secretKey string) *Notification {
+	return &Notification{db: db, secretKey: secretKey}
+}
+
+// GetNotifications lists the caller's notifications, most recently updated
+// first, together with the number of unread ones.
+func (n *Notification) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, n.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := n.db.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:          userID,
+		BeforeUpdatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving notifications:", err)
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	unread, err := n.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		log.Println("Error counting notifications:", err)
+		http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+		return
+	}
+
+	actors := make(map[uuid.UUID][]NotificationActorResponseModel, len(rows))
+	if len(rows) > 0 {
+		ids := make([]uuid.UUID, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+
+		actorRows, err := n.db.GetNotificationActors(r.Context(), database.GetNotificationActorsParams{
+			NotificationIds: ids,
+			PerNotification: notificationActorsShown,
+		})
+		if err != nil {
+			log.Println("Error retrieving notification actors:", err)
+			http.Error(w, "Failed to retrieve notifications", http.StatusInternalServerError)
+			return
+		}
+		for _, a := range actorRows {
+			actors[a.NotificationID] = append(actors[a.NotificationID], NotificationActorResponseModel{
+				ID:       a.ID,
+				Username: a.Username,
+			})
+		}
+	}
+
+	resp := NotificationListResponseModel{
+		Notifications: []NotificationResponseModel{},
+		UnreadCount:   unread,
+	}
+	for _, row := range rows {
+		resp.Notifications = append(resp.Notifications, convertNotificationToResponseModel(row, actors[row.ID]))
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.UpdatedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// MarkRead marks one of the caller's notifications as read. Later events
+// about the same item start a new notification.
+func (n *Notification) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, n.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := n.db.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Println("Error marking notification read:", err)
+		http.Error(w, "Failed to mark notification read", http.StatusInternalServerError)
+		return
+	}
+	if updated == 0 {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead marks every notification of the caller as read.
+func (n *Notification) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, n.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if err := n.db.MarkAllNotificationsRead(r.Context(), userID); err != nil {
+		log.Println("Error marking notifications read:", err)
+		http.Error(w, "Failed to mark notifications read", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func convertNotificationToResponseModel(row database.GetNotificationsRow, actors []NotificationActorResponseModel) NotificationResponseModel {
+	if actors == nil {
+		actors = []NotificationActorResponseModel{}
+	}
+	names := make([]string, 0, len(actors))
+	for _, a := range actors {
+		names = append(names, a.Username)
+	}
+
+	resp := NotificationResponseModel{
+		ID:         row.ID,
+		Kind:       row.Kind,
+		Actors:     actors,
+		ActorCount: row.ActorCount,
+		Summary:    notifications.Summary(row.Kind, names, row.ActorCount),
+		Read:       row.ReadAt.Valid,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+	}
+	if row.ChirpID.Valid {
+		resp.ChirpID = &row.ChirpID.UUID
+	}
+	return resp
+}
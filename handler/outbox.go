@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+// Outbox lets moderators inspect the events the outbox gave up on and
+// dispatch them again.
+type Outbox struct {
+	db        *database.Queries
+	secretKey string
+	relay     *outbox.Relay
+}
+
+func NewOutboxHandler(db *database.Queries, secretKey string, relay *outbox.Relay) *Outbox {
+	return &Outbox{db: db, secretKey: secretKey, relay: relay}
+}
+
+// GetFailedEvents lists failed events, most recently failed first.
+func (o *Outbox) GetFailedEvents(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r, o.db, o.secretKey); !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := o.db.GetFailedOutboxEvents(r.Context(), database.GetFailedOutboxEventsParams{
+		BeforeFailedAt: p.beforeAt(),
+		BeforeID:       p.beforeID(),
+		Limit:          p.Limit,
+	})
+	if err != nil {
+		log.Println("Error listing failed events:", err)
+		http.Error(w, "Failed to retrieve events", http.StatusInternalServerError)
+		return
+	}
+
+	resp := OutboxEventListResponseModel{Events: []OutboxEventResponseModel{}}
+	for _, e := range list {
+		resp.Events = append(resp.Events, convertOutboxEventToResponseModel(e))
+	}
+	if len(list) > 0 {
+		last := list[len(list)-1]
+		resp.NextCursor = p.nextCursor(len(list), pageCursor{At: last.FailedAt.Time, ID: last.EventID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// RetryEvent dispatches a failed event again right away, with its attempts
+// reset. Subscribers that handled it before are skipped while the outbox
+// still remembers them.
+func (o *Outbox) RetryEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r, o.db, o.secretKey); !ok {
+		return
+	}
+
+	eventID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid event ID", http.StatusBadRequest)
+		return
+	}
+
+	e, err := o.db.RetryOutboxEvent(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No failed event with this ID", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrying event:", err)
+		http.Error(w, "Failed to retry event", http.StatusInternalServerError)
+		return
+	}
+	o.relay.Wake()
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertOutboxEventToResponseModel(e))
+}
+
+func convertOutboxEventToResponseModel(e database.OutboxEvent) OutboxEventResponseModel {
+	resp := OutboxEventResponseModel{
+		ID:        e.EventID,
+		Type:      e.EventType,
+		Payload:   json.RawMessage(e.Payload),
+		Attempts:  e.Attempts,
+		LastError: e.LastError.String,
+		CreatedAt: e.CreatedAt,
+	}
+	if e.FailedAt.Valid {
+		resp.FailedAt = &e.FailedAt.Time
+	}
+	return resp
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor points at the last item of a page ordered by (time, id) descending.
+type pageCursor struct {
+	At time.Time
+	ID uuid.UUID
+	// Rank comes before the time in the order of relevance-ranked lists such
+	// as search results, and is zero everywhere else.
+	Rank float32
+}
+
+func (c pageCursor) encode() string {
+	raw := c.At.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	if c.Rank != 0 {
+		raw += "|" + strconv.FormatFloat(float64(c.Rank), 'g', -1, 32)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	parsedID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+
+	c := pageCursor{At: t, ID: parsedID}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 32)
+		if err != nil {
+			return pageCursor{}, errors.New("malformed cursor")
+		}
+		c.Rank = float32(rank)
+	}
+	return c, nil
+}
+
+// page holds the pagination parameters of a list request.
+type page struct {
+	Limit  int32
+	Cursor *pageCursor
+}
+
+// parsePage reads the "limit" and "cursor" query parameters.
+func parsePage(r *http.Request) (page, error) {
+	p := page{Limit: defaultPageLimit}
+
+	if s := r.URL.Query().Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return page{}, errors.New("limit must be a positive integer")
+		}
+		p.Limit = int32(min(limit, maxPageLimit))
+	}
+
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		c, err := decodeCursor(s)
+		if err != nil {
+			return page{}, err
+		}
+		p.Cursor = &c
+	}
+
+	return p, nil
+}
+
+func (p page) beforeAt() sql.NullTime {
+	if p.Cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.Cursor.At, Valid: true}
+}
+
+func (p page) beforeID() uuid.NullUUID {
+	if p.Cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+func (p page) beforeRank() float32 {
+	if p.Cursor == nil {
+		return 0
+	}
+	return p.Cursor.Rank
+}
+
+// nextCursor returns the cursor for the page after one that returned n items,
+// or "" when the page was not full.
+func (p page) nextCursor(n int, last pageCursor) string {
+	if n < int(p.Limit) {
+		return ""
+	}
+	return last.encode()
+}
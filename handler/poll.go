@@ -0,0 +1,245 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+
+	minPollDuration = 5 * time.Minute
+	maxPollDuration = 7 * 24 * time.Hour
+)
+
+type Poll struct {
+	db        *database.Queries
+	secretKey string
+}
+
+func NewPollHandler(db *database.Queries, secretKey string) *Poll {
+	return &Poll{db: db, secretKey: secretKey}
+}
+
+// Vote records the caller's vote in the poll of the chirp with the {id} path
+// value. Each user votes once, and only until the poll closes.
+func (p *Poll) Vote(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, p.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid chirp ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PollVoteRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := p.db.GetChirpByID(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Poll not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving chirp:", err)
+		http.Error(w, "Failed to vote", http.StatusInternalServerError)
+		return
+	}
+
+	visible, err := visibility.CanSee(r.Context(), p.db, userID, chirp)
+	if err != nil {
+		log.Println("Error checking chirp visibility:", err)
+		http.Error(w, "Failed to vote", http.StatusInternalServerError)
+		return
+	}
+	if !visible {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	}
+
+	poll, err := p.db.GetPollByChirpID(r.Context(), chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Poll not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error retrieving poll:", err)
+		http.Error(w, "Failed to vote", http.StatusInternalServerError)
+		return
+	}
+
+	if !time.Now().Before(poll.ClosesAt) {
+		http.Error(w, "Poll is closed", http.StatusConflict)
+		return
+	}
+
+	n, err := p.db.VoteInPoll(r.Context(), database.VoteInPollParams{
+		UserID:   userID,
+		ChirpID:  chirpID,
+		Position: req.Option,
+	})
+	if err != nil {
+		log.Println("Error voting:", err)
+		http.Error(w, "Failed to vote", http.StatusInternalServerError)
+		return
+	}
+
+	polls, err := pollResponses(r.Context(), p.db, userID, []uuid.UUID{chirpID})
+	if err != nil || polls[chirpID] == nil {
+		log.Println("Error building poll response:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := polls[chirpID]
+
+	if n == 0 {
+		switch {
+		case resp.VotedOption != nil:
+			http.Error(w, "You have already voted in this poll", http.StatusConflict)
+		case resp.Closed:
+			http.Error(w, "Poll is closed", http.StatusConflict)
+		default:
+			http.Error(w, "Invalid poll option", http.StatusBadRequest)
+		}
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// validatePoll checks the poll of a chirp request and returns its cleaned
+// option texts.
+func validatePoll(req *PollRequestModel, now time.Time) ([]string, error) {
+	if len(req.Options) < minPollOptions || len(req.Options) > maxPollOptions {
+		return nil, fmt.Errorf("a poll must have %d to %d options", minPollOptions, maxPollOptions)
+	}
+
+	options := make([]string, 0, len(req.Options))
+	seen := make(map[string]struct{}, len(req.Options))
+	for _, o := range req.Options {
+		o = strings.TrimSpace(o)
+		if o == "" {
+			return nil, errors.New("poll options must not be empty")
+		}
+		if utf8.RuneCountInString(o) > maxPollOptionLength {
+			return nil, fmt.Errorf("poll options must be at most %d characters", maxPollOptionLength)
+		}
+		if _, ok := seen[strings.ToLower(o)]; ok {
+			return nil, errors.New("poll options must be distinct")
+		}
+		seen[strings.ToLower(o)] = struct{}{}
+		options = append(options, o)
+	}
+
+	if d := req.ClosesAt.Sub(now); d < minPollDuration || d > maxPollDuration {
+		return nil, fmt.Errorf("a poll must close between %v and %v from now", minPollDuration, maxPollDuration)
+	}
+	return options, nil
+}
+
+// createPoll attaches a poll with the given options to chirpID.
+func createPoll(ctx context.Context, db *database.Queries, chirpID uuid.UUID, options []string, closesAt time.Time) error {
+	if err := db.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: closesAt.UTC(),
+	}); err != nil {
+		return err
+	}
+	return db.InsertPollOptions(ctx, database.InsertPollOptionsParams{
+		ChirpID: chirpID,
+		Texts:   options,
+	})
+}
+
+// fillPolls loads the polls of models, with the vote of viewerID if any.
+func fillPolls(ctx context.Context, db *database.Queries, viewerID uuid.UUID, models []*ChirpResponseModel) error {
+	ids := make([]uuid.UUID, 0, len(models))
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+
+	polls, err := pollResponses(ctx, db, viewerID, ids)
+	if err != nil {
+		return err
+	}
+	for _, m := range models {
+		m.Poll = polls[m.ID]
+	}
+	return nil
+}
+
+// pollResponses returns the polls of the chirps in ids, keyed by chirp ID.
+// Chirps without a poll are left out.
+func pollResponses(ctx context.Context, db *database.Queries, viewerID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*PollResponseModel, error) {
+	polls, err := db.GetPollsByChirpIDs(ctx, ids)
+	if err != nil || len(polls) == 0 {
+		return nil, err
+	}
+
+	pollIDs := make([]uuid.UUID, 0, len(polls))
+	responses := make(map[uuid.UUID]*PollResponseModel, len(polls))
+	now := time.Now()
+	for _, p := range polls {
+		pollIDs = append(pollIDs, p.ChirpID)
+		responses[p.ChirpID] = &PollResponseModel{
+			ClosesAt: p.ClosesAt,
+			Closed:   !now.Before(p.ClosesAt),
+			Options:  []PollOptionResponseModel{},
+		}
+	}
+
+	options, err := db.GetPollOptionsByChirpIDs(ctx, pollIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range options {
+		resp := responses[o.ChirpID]
+		resp.Options = append(resp.Options, PollOptionResponseModel{
+			Position: o.Position,
+			Text:     o.Text,
+			Votes:    o.VoteCount,
+		})
+		resp.TotalVotes += int64(o.VoteCount)
+	}
+
+	if viewerID == uuid.Nil {
+		return responses, nil
+	}
+
+	votes, err := db.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+		UserID:   viewerID,
+		ChirpIds: pollIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range votes {
+		position := v.Position
+		responses[v.ChirpID].VotedOption = &position
+	}
+	return responses, nil
+}
@@ -0,0 +1,562 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// Report statuses, in queue order.
+const (
+	reportOpen     = "open"
+	reportClaimed  = "claimed"
+	reportResolved = "resolved"
+)
+
+// categoryAutomated marks reports raised by the moderation pipeline rather
+// than by a user.
+const categoryAutomated = "automated"
+
+// reportCategories are the reasons a user can give for a report.
+var reportCategories = map[string]struct{}{
+	"spam":          {},
+	"harassment":    {},
+	"hate":          {},
+	"violence":      {},
+	"self_harm":     {},
+	"impersonation": {},
+	"other":         {},
+}
+
+// Resolution actions. Every action a moderator takes, claims included, is
+// also recorded in the moderation_actions audit trail.
+const (
+	actionClaim     = "claim"
+	actionDismiss   = "dismiss"
+	actionHideChirp = "hide_chirp"
+	actionWarn      = "warn"
+	actionSuspend   = "suspend"
+)
+
+const (
+	maxReportDetailsLength = 500
+	maxSuspensionDays      = 365
+)
+
+type Report struct {
+	conn      *sql.DB
+	db        *database.Queries
+	secretKey string
+}
+
+func NewReportHandler(conn *sql.DB, db *database.Queries, secretKey string) *Report {
+	return &Report{conn: conn, db: db, secretKey: secretKey}
+}
+
+// CreateReport reports a chirp, or a user directly, to the moderators.
+func (rp *Report) CreateReport(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, rp.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req ReportRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := reportCategories[req.Category]; !ok {
+		http.Error(w, "Unknown report category", http.StatusBadRequest)
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if utf8.RuneCountInString(req.Details) > maxReportDetailsLength {
+		http.Error(w, fmt.Sprintf("Details exceed %d characters", maxReportDetailsLength), http.StatusBadRequest)
+		return
+	}
+
+	params := database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: userID, Valid: true},
+		Category:   req.Category,
+		Details:    req.Details,
+	}
+	switch {
+	case req.ChirpID != nil:
+		chirp, err := rp.db.GetChirpByID(r.Context(), *req.ChirpID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Chirp not found", http.StatusNotFound)
+				return
+			}
+
+			log.Println("Error retrieving chirp:", err)
+			http.Error(w, "Failed to create report", http.StatusInternalServerError)
+			return
+		}
+
+		visible, err := visibility.CanSee(r.Context(), rp.db, userID, chirp)
+		if err != nil {
+			log.Println("Error checking chirp visibility:", err)
+			http.Error(w, "Failed to create report", http.StatusInternalServerError)
+			return
+		}
+		if !visible {
+			http.Error(w, "Chirp not found", http.StatusNotFound)
+			return
+		}
+		params.ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		params.UserID = chirp.UserID
+	case req.UserID != nil:
+		params.UserID = *req.UserID
+	default:
+		http.Error(w, "chirp_id or user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	if params.UserID == userID {
+		http.Error(w, "You cannot report yourself", http.StatusBadRequest)
+		return
+	}
+
+	report, err := rp.db.CreateReport(r.Context(), params)
+	if err != nil {
+		if utils.IsForeignKeyViolation(err) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error creating report:", err)
+		http.Error(w, "Failed to create report", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusCreated, convertReportToResponseModel(report))
+}
+
+// GetReports lists the moderation queue, oldest first. The "status" query
+// parameter selects open (the default), claimed or resolved reports.
+func (rp *Report) GetReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := rp.moderator(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	params := database.ListReportsParams{
+		Status: reportOpen,
+		Limit:  defaultPageLimit,
+	}
+	if v := query.Get("status"); v != "" {
+		if v != reportOpen && v != reportClaimed && v != reportResolved {
+			http.Error(w, "status must be open, claimed or resolved", http.StatusBadRequest)
+			return
+		}
+		params.Status = v
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		params.Limit = int32(min(limit, maxPageLimit))
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		params.Offset = int32(offset)
+	}
+
+	reports, err := rp.db.ListReports(r.Context(), params)
+	if err != nil {
+		log.Println("Error listing reports:", err)
+		http.Error(w, "Failed to retrieve reports", http.StatusInternalServerError)
+		return
+	}
+
+	resp := ReportListResponseModel{Reports: make([]ReportResponseModel, 0, len(reports))}
+	for _, report := range reports {
+		resp.Reports = append(resp.Reports, convertReportToResponseModel(report))
+	}
+	if len(reports) == int(params.Limit) {
+		next := params.Offset + params.Limit
+		resp.NextOffset = &next
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// GetReport returns a report with its audit trail.
+func (rp *Report) GetReport(w http.ResponseWriter, r *http.Request) {
+	if _, ok := rp.moderator(w, r); !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	report, err := rp.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+
+	actions, err := rp.db.GetModerationActionsByReport(r.Context(), uuid.NullUUID{UUID: reportID, Valid: true})
+	if err != nil {
+		log.Println("Error retrieving moderation actions:", err)
+		http.Error(w, "Failed to retrieve report", http.StatusInternalServerError)
+		return
+	}
+
+	resp := convertReportToResponseModel(report)
+	resp.Actions = convertModerationActions(actions)
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// ClaimReport assigns an open report to the calling moderator.
+func (rp *Report) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := rp.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	var report database.Report
+	err = withTx(r.Context(), rp.conn, rp.db, func(qtx *database.Queries) error {
+		var err error
+		report, err = qtx.ClaimReport(r.Context(), database.ClaimReportParams{
+			ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ID:          reportID,
+		})
+		if err != nil {
+			return err
+		}
+		return qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ReportID:      uuid.NullUUID{UUID: report.ID, Valid: true},
+			Action:        actionClaim,
+			TargetUserID:  uuid.NullUUID{UUID: report.UserID, Valid: true},
+			TargetChirpID: report.ChirpID,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		rp.respondWithUnavailableReport(w, r, reportID)
+		return
+	}
+	if err != nil {
+		log.Println("Error claiming report:", err)
+		http.Error(w, "Failed to claim report", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertReportToResponseModel(report))
+}
+
+// ResolveReport closes a report claimed by the calling moderator, applying
+// the chosen action to the reported chirp or user.
+func (rp *Report) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	moderatorID, ok := rp.moderator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveReportRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch req.Action {
+	case actionDismiss, actionHideChirp, actionWarn:
+	case actionSuspend:
+		if req.SuspendDays < 1 || req.SuspendDays > maxSuspensionDays {
+			http.Error(w, fmt.Sprintf("suspend_days must be between 1 and %d", maxSuspensionDays), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "action must be dismiss, hide_chirp, warn or suspend", http.StatusBadRequest)
+		return
+	}
+
+	var report database.Report
+	err = withTx(r.Context(), rp.conn, rp.db, func(qtx *database.Queries) error {
+		var err error
+		report, err = qtx.ResolveReport(r.Context(), database.ResolveReportParams{
+			Resolution:  sql.NullString{String: req.Action, Valid: true},
+			ID:          reportID,
+			ModeratorID: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		switch req.Action {
+		case actionHideChirp:
+			if !report.ChirpID.Valid {
+				return badRequest("This report is not about a chirp")
+			}
+			if err := qtx.HideChirp(r.Context(), report.ChirpID.UUID); err != nil {
+				return err
+			}
+		case actionSuspend:
+			if err := qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+				SuspendedUntil: sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, req.SuspendDays), Valid: true},
+				ID:             report.UserID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		return qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+			ModeratorID:   uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ReportID:      uuid.NullUUID{UUID: report.ID, Valid: true},
+			Action:        req.Action,
+			TargetUserID:  uuid.NullUUID{UUID: report.UserID, Valid: true},
+			TargetChirpID: report.ChirpID,
+			Note:          strings.TrimSpace(req.Note),
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		rp.respondWithUnavailableReport(w, r, reportID)
+		return
+	}
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.msg, reqErr.status)
+			return
+		}
+
+		log.Println("Error resolving report:", err)
+		http.Error(w, "Failed to resolve report", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertReportToResponseModel(report))
+}
+
+// GetUserActions lists the moderation actions taken against the user with the
+// {id} path value, newest first.
+func (rp *Report) GetUserActions(w http.ResponseWriter, r *http.Request) {
+	if _, ok := rp.moderator(w, r); !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	rp.respondWithActions(w, r, userID, sql.NullString{})
+}
+
+// GetWarnings lists the warnings the caller has received, newest first.
+func (rp *Report) GetWarnings(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, rp.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	rp.respondWithActions(w, r, userID, sql.NullString{String: actionWarn, Valid: true})
+}
+
+func (rp *Report) respondWithActions(w http.ResponseWriter, r *http.Request, userID uuid.UUID, action sql.NullString) {
+	actions, err := rp.db.GetModerationActionsByUser(r.Context(), database.GetModerationActionsByUserParams{
+		UserID: uuid.NullUUID{UUID: userID, Valid: true},
+		Action: action,
+	})
+	if err != nil {
+		log.Println("Error retrieving moderation actions:", err)
+		http.Error(w, "Failed to retrieve moderation actions", http.StatusInternalServerError)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertModerationActions(actions))
+}
+
+func (rp *Report) moderator(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	return requireModerator(w, r, rp.db, rp.secretKey)
+}
+
+// requireModerator authenticates the caller and checks that they are a
+// moderator.
+func requireModerator(w http.ResponseWriter, r *http.Request, db *database.Queries, secretKey string) (uuid.UUID, bool) {
+	userID, err := authenticate(r, secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, false
+	}
+
+	status, err := db.GetUserModeration(r.Context(), userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Error retrieving moderator status:", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return uuid.Nil, false
+	}
+	if !status.IsModerator {
+		http.Error(w, "Moderator access required", http.StatusForbidden)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// respondWithUnavailableReport explains why a report could not be claimed or
+// resolved by the caller.
+func (rp *Report) respondWithUnavailableReport(w http.ResponseWriter, r *http.Request, reportID uuid.UUID) {
+	report, err := rp.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondWithReportError(w, err)
+		return
+	}
+
+	switch report.Status {
+	case reportResolved:
+		http.Error(w, "Report is already resolved", http.StatusConflict)
+	case reportClaimed:
+		http.Error(w, "Report is claimed by another moderator", http.StatusConflict)
+	default:
+		http.Error(w, "Report must be claimed first", http.StatusConflict)
+	}
+}
+
+// RejectSuspended refuses the writes of suspended users on every route of
+// next. Requests with safe methods, and requests without a valid access
+// token, pass through for next to authenticate.
+func RejectSuspended(db *database.Queries, secretKey string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := authenticate(r, secretKey)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := checkNotSuspended(r.Context(), db, userID); err != nil {
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				http.Error(w, reqErr.msg, reqErr.status)
+				return
+			}
+
+			log.Println("Error checking suspension:", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// checkNotSuspended returns a *requestError if userID is suspended.
+func checkNotSuspended(ctx context.Context, db *database.Queries, userID uuid.UUID) error {
+	status, err := db.GetUserModeration(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &requestError{status: http.StatusUnauthorized, msg: "User not found"}
+		}
+		return err
+	}
+
+	if status.SuspendedUntil.Valid && time.Now().Before(status.SuspendedUntil.Time) {
+		return &requestError{
+			status: http.StatusForbidden,
+			msg:    "Your account is suspended until " + status.SuspendedUntil.Time.Format(time.RFC3339),
+		}
+	}
+	return nil
+}
+
+func respondWithReportError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Error retrieving report:", err)
+	http.Error(w, "Failed to retrieve report", http.StatusInternalServerError)
+}
+
+func convertReportToResponseModel(report database.Report) ReportResponseModel {
+	resp := ReportResponseModel{
+		ID:         report.ID,
+		UserID:     report.UserID,
+		Category:   report.Category,
+		Details:    report.Details,
+		Status:     report.Status,
+		Resolution: report.Resolution.String,
+		CreatedAt:  report.CreatedAt,
+	}
+	if report.ReporterID.Valid {
+		resp.ReporterID = &report.ReporterID.UUID
+	}
+	if report.ChirpID.Valid {
+		resp.ChirpID = &report.ChirpID.UUID
+	}
+	if report.ClaimedBy.Valid {
+		resp.ClaimedBy = &report.ClaimedBy.UUID
+	}
+	if report.ResolvedAt.Valid {
+		resp.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return resp
+}
+
+func convertModerationActions(actions []database.ModerationAction) []ModerationActionResponseModel {
+	resp := make([]ModerationActionResponseModel, 0, len(actions))
+	for _, a := range actions {
+		m := ModerationActionResponseModel{
+			ID:        a.ID,
+			Action:    a.Action,
+			Note:      a.Note,
+			CreatedAt: a.CreatedAt,
+		}
+		if a.ModeratorID.Valid {
+			m.ModeratorID = &a.ModeratorID.UUID
+		}
+		if a.ReportID.Valid {
+			m.ReportID = &a.ReportID.UUID
+		}
+		if a.TargetChirpID.Valid {
+			m.ChirpID = &a.TargetChirpID.UUID
+		}
+		resp = append(resp, m)
+	}
+	return resp
+}
@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/search"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+type Search struct {
+	db        *database.Queries
+	secretKey string
+}
+
+func NewSearchHandler(db *database.Queries, secretKey string) *Search {
+	return &Search{db: db, secretKey: secretKey}
+}
+
+// SearchChirps runs a full-text search over chirp bodies. Results are ordered
+// by relevance and paged with "limit" and "cursor". Optional filters are
+// "author" (a user ID) and "since"/"until" (RFC 3339 times or YYYY-MM-DD dates).
+func (s *Search) SearchChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := viewer(r, s.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	tsQuery, err := search.ToTSQuery(query.Get("q"))
+	if err != nil {
+		http.Error(w, "Invalid search query: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := database.SearchChirpsParams{
+		Query:           tsQuery,
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeRank:      p.beforeRank(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	}
+
+	if v := query.Get("author"); v != "" {
+		authorID, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "Invalid author ID", http.StatusBadRequest)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	if params.Since, err = parseTimeParam(query.Get("since")); err != nil {
+		http.Error(w, "Invalid since: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if params.Until, err = parseTimeParam(query.Get("until")); err != nil {
+		http.Error(w, "Invalid until: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := s.db.SearchChirps(r.Context(), params)
+	if err != nil {
+		log.Println("Error searching chirps:", err)
+		http.Error(w, "Failed to search chirps", http.StatusInternalServerError)
+		return
+	}
+
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, database.Chirp{
+			ID:            row.ID,
+			UserID:        row.UserID,
+			Body:          row.Body,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+			LikeCount:     row.LikeCount,
+			ReferenceID:   row.ReferenceID,
+			ReferenceKind: row.ReferenceKind,
+			RechirpCount:  row.RechirpCount,
+			QuoteCount:    row.QuoteCount,
+			HiddenAt:      row.HiddenAt,
+			Visibility:    row.Visibility,
+		})
+	}
+
+	models, err := chirpResponses(r.Context(), s.db, viewerID, chirps)
+	if err != nil {
+		log.Println("Error building chirp responses:", err)
+		http.Error(w, "Failed to search chirps", http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[uuid.UUID]ChirpResponseModel, len(models))
+	for _, m := range models {
+		byID[m.ID] = m
+	}
+
+	resp := SearchResponseModel{Results: make([]SearchResultResponseModel, 0, len(rows))}
+	for _, row := range rows {
+		model, ok := byID[row.ID]
+		if !ok {
+			continue
+		}
+		resp.Results = append(resp.Results, SearchResultResponseModel{
+			Chirp:   model,
+			Rank:    row.Rank,
+			Snippet: row.Snippet,
+		})
+	}
+	if len(rows) > 0 {
+		last := rows[len(rows)-1]
+		resp.NextCursor = p.nextCursor(len(rows), pageCursor{At: last.CreatedAt.Time, ID: last.ID, Rank: last.Rank})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+func parseTimeParam(v string) (sql.NullTime, error) {
+	if v == "" {
+		return sql.NullTime{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return sql.NullTime{Time: t.UTC(), Valid: true}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return sql.NullTime{Time: t, Valid: true}, nil
+	}
+	return sql.NullTime{}, errors.New("expected an RFC 3339 time or a YYYY-MM-DD date")
+}
@@ -0,0 +1,676 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/auth"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/realtime"
+	"github.com/jacosy/go-web-server/internal/visibility"
+	"github.com/jacosy/go-web-server/internal/websocket"
+)
+
+const (
+	socketPingInterval = 30 * time.Second
+	// socketPongWait is how long a connection may stay silent, pongs
+	// included, before it is considered dead.
+	socketPongWait     = 2 * socketPingInterval
+	socketWriteTimeout = 10 * time.Second
+	// socketCloseWait is how long to wait for the client to answer a close.
+	socketCloseWait        = 5 * time.Second
+	socketMaxCommandSize   = 4 << 10
+	socketSendBuffer       = 64
+	socketMaxSubscriptions = 50
+	// Clients may send socketCommandBurst commands at once and
+	// socketCommandRate per second after that.
+	socketCommandRate  = 5
+	socketCommandBurst = 20
+	// closeTokenExpired closes connections whose token expired without an
+	// auth command refreshing it.
+	closeTokenExpired = 4001
+)
+
+// Socket serves live updates and direct messages over WebSockets. Each
+// connection multiplexes any number of channel subscriptions on the same hub
+// as the SSE stream.
+type Socket struct {
+	db        *database.Queries
+	secretKey string
+	hub       *realtime.Hub
+	bus       *events.Bus
+	renders   *chirpRenders
+
+	mu       sync.Mutex
+	conns    map[*socketConn]struct{}
+	draining bool
+	active   sync.WaitGroup
+}
+
+func NewSocketHandler(db *database.Queries, secretKey string, hub *realtime.Hub, bus *events.Bus) *Socket {
+	return &Socket{
+		db:        db,
+		secretKey: secretKey,
+		hub:       hub,
+		bus:       bus,
+		renders:   newChirpRenders(db),
+		conns:     make(map[*socketConn]struct{}),
+	}
+}
+
+// Connect upgrades the request to a WebSocket. Browsers cannot set headers on
+// WebSocket requests, so the access token may also be passed as the
+// access_token query parameter. Without one the connection is anonymous until
+// it sends an auth command.
+//
+// Clients send JSON commands:
+//
+//	{"id": "1", "type": "auth", "token": "..."}
+//	{"id": "2", "type": "subscribe", "channel": "timeline", "since": 41}
+//	{"id": "3", "type": "subscribe", "channel": "thread", "target": "<chirp ID>"}
+//	{"id": "4", "type": "unsubscribe", "channel": "thread", "target": "<chirp ID>"}
+//	{"id": "5", "type": "send", "target": "<conversation ID>", "body": "hi"}
+//
+// Channels are those of the SSE stream plus thread, the rechirps and quotes
+// of a chirp, and conversation, the messages of one of the caller's
+// conversations. Every command is answered with an ack or error frame
+// carrying its ID. Auth refreshes the token of a signed-in connection, which
+// is closed with code 4001 once its token expires.
+func (s *Socket) Connect(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("access_token")
+	if token == "" && r.Header.Get("Authorization") != "" {
+		var err error
+		if token, err = auth.GetBearerToken(r.Header); err != nil {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var userID uuid.UUID
+	var expiresAt time.Time
+	if token != "" {
+		var err error
+		if userID, expiresAt, err = auth.ParseJWT(token, s.secretKey); err != nil {
+			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	if s.draining {
+		s.mu.Unlock()
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	s.active.Add(1)
+	s.mu.Unlock()
+	defer s.active.Done()
+
+	ws, err := websocket.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+
+	c := newSocketConn(s, ws)
+	c.setUser(userID, expiresAt)
+
+	s.mu.Lock()
+	s.conns[c] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+	}()
+
+	c.serve()
+}
+
+// Drain closes every connection as going away and waits for them to finish,
+// or for ctx to be done. New connections are refused from then on.
+func (s *Socket) Drain(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	for c := range s.conns {
+		c.close(websocket.CloseGoingAway, "Server is shutting down")
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type socketCloseFrame struct {
+	code   int
+	reason string
+}
+
+// socketConn is one WebSocket connection. Commands are read and handled on
+// the request goroutine, frames are written by writeLoop and every
+// subscription is forwarded by a goroutine of its own, as are the
+// relationship signals of the signed-in user.
+type socketConn struct {
+	s      *Socket
+	ws     *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	out    chan SocketEventModel
+
+	closing   atomic.Bool
+	closeOnce sync.Once
+	closeReq  chan socketCloseFrame
+
+	// tokens and lastCommand rate limit commands. Only the reader uses them.
+	tokens      float64
+	lastCommand time.Time
+
+	viewer *liveViewer
+
+	mu        sync.Mutex
+	userID    uuid.UUID
+	expiry    *time.Timer
+	subs      map[string]*socketSubscription
+	relations *realtime.Subscription
+}
+
+type socketSubscription struct {
+	key     string
+	channel string
+	target  uuid.UUID
+	sub     *realtime.Subscription
+	// sent deduplicates chirps within the subscription, such as a rechirp
+	// of a chirp that is already on the timeline.
+	sent map[uuid.UUID]bool
+}
+
+func newSocketConn(s *Socket, ws *websocket.Conn) *socketConn {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &socketConn{
+		s:           s,
+		ws:          ws,
+		ctx:         ctx,
+		cancel:      cancel,
+		out:         make(chan SocketEventModel, socketSendBuffer),
+		closeReq:    make(chan socketCloseFrame, 1),
+		tokens:      socketCommandBurst,
+		lastCommand: time.Now(),
+		viewer:      newLiveViewer(s.db, uuid.Nil),
+		subs:        make(map[string]*socketSubscription),
+	}
+}
+
+// serve reads commands until the connection closes.
+func (c *socketConn) serve() {
+	defer c.cleanup()
+	go c.writeLoop()
+
+	c.ws.SetReadLimit(socketMaxCommandSize)
+	c.ws.SetWriteTimeout(socketWriteTimeout)
+	c.ws.SetReadDeadline(time.Now().Add(socketPongWait))
+	c.ws.SetPongHandler(func([]byte) {
+		if !c.closing.Load() {
+			c.ws.SetReadDeadline(time.Now().Add(socketPongWait))
+		}
+	})
+
+	for {
+		opcode, data, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if c.closing.Load() {
+			// Waiting for the client to answer the close.
+			continue
+		}
+		c.ws.SetReadDeadline(time.Now().Add(socketPongWait))
+
+		if opcode != websocket.TextMessage {
+			c.close(websocket.CloseUnsupportedData, "Commands must be text messages")
+			continue
+		}
+
+		var cmd SocketCommandModel
+		err = json.Unmarshal(data, &cmd)
+		switch {
+		case !c.allow(time.Now()):
+			c.send(SocketEventModel{Type: "error", ID: cmd.ID, Error: "Rate limit exceeded"})
+		case err != nil:
+			c.send(SocketEventModel{Type: "error", ID: cmd.ID, Error: "Invalid command"})
+		default:
+			c.handle(cmd)
+		}
+	}
+}
+
+// allow takes a token from the connection's command bucket.
+func (c *socketConn) allow(now time.Time) bool {
+	c.tokens = min(socketCommandBurst, c.tokens+now.Sub(c.lastCommand).Seconds()*socketCommandRate)
+	c.lastCommand = now
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+func (c *socketConn) handle(cmd SocketCommandModel) {
+	var data any
+	var start func()
+	var err error
+	switch cmd.Type {
+	case "auth":
+		data, err = c.authenticate(cmd.Token)
+	case "subscribe":
+		start, err = c.subscribe(cmd)
+	case "unsubscribe":
+		c.unsubscribe(cmd)
+	case "send":
+		data, err = c.sendMessage(cmd)
+	default:
+		err = badRequest("Unknown command %q", cmd.Type)
+	}
+
+	if err != nil {
+		var reqErr *requestError
+		if !errors.As(err, &reqErr) {
+			log.Printf("Error handling %s socket command: %v", cmd.Type, err)
+			reqErr = &requestError{status: http.StatusInternalServerError, msg: "Internal server error"}
+		}
+		c.send(SocketEventModel{Type: "error", ID: cmd.ID, Error: reqErr.msg})
+		return
+	}
+
+	ack := SocketEventModel{Type: "ack", ID: cmd.ID}
+	if data != nil {
+		if ack.Data, err = json.Marshal(data); err != nil {
+			log.Printf("Error encoding %s socket reply: %v", cmd.Type, err)
+		}
+	}
+	c.send(ack)
+
+	// Events of a new subscription follow its ack.
+	if start != nil {
+		start()
+	}
+}
+
+// authenticate signs the connection in, or refreshes its token. The token
+// must belong to the user the connection is already signed in as.
+func (c *socketConn) authenticate(token string) (any, error) {
+	userID, expiresAt, err := auth.ParseJWT(token, c.s.secretKey)
+	if err != nil {
+		return nil, &requestError{status: http.StatusUnauthorized, msg: "Unauthorized: " + err.Error()}
+	}
+
+	c.mu.Lock()
+	current := c.userID
+	c.mu.Unlock()
+	if current != uuid.Nil && current != userID {
+		return nil, &requestError{status: http.StatusForbidden, msg: "The token belongs to another user"}
+	}
+
+	c.setUser(userID, expiresAt)
+	return map[string]any{"user_id": userID, "expires_at": expiresAt}, nil
+}
+
+// setUser signs the connection in as userID until expiresAt.
+func (c *socketConn) setUser(userID uuid.UUID, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if userID != c.userID {
+		c.viewer.reset(userID)
+		if c.relations != nil {
+			c.relations.Close()
+			c.relations = nil
+		}
+		if userID != uuid.Nil {
+			c.relations, _, _ = c.s.hub.Subscribe([]string{realtime.RelationshipTopic(userID)}, 0)
+			go c.watchRelationships(c.relations)
+		}
+	}
+
+	c.userID = userID
+	if c.expiry != nil {
+		c.expiry.Stop()
+		c.expiry = nil
+	}
+	if userID != uuid.Nil && !expiresAt.IsZero() {
+		c.expiry = time.AfterFunc(time.Until(expiresAt), func() {
+			c.close(closeTokenExpired, "Token expired")
+		})
+	}
+}
+
+// watchRelationships reloads the viewer and the topics of its timeline
+// subscriptions whenever its follows, blocks or mutes change.
+func (c *socketConn) watchRelationships(relations *realtime.Subscription) {
+	for range relations.C {
+		if err := c.viewer.reload(c.ctx); err != nil {
+			log.Println("Error reloading socket viewer:", err)
+			c.close(websocket.CloseInternalError, "Internal server error")
+			return
+		}
+
+		c.mu.Lock()
+		var timelines []*socketSubscription
+		for _, ss := range c.subs {
+			if ss.channel == "timeline" {
+				timelines = append(timelines, ss)
+			}
+		}
+		c.mu.Unlock()
+
+		if len(timelines) == 0 {
+			continue
+		}
+		topics, err := c.viewer.timelineTopics(c.ctx)
+		if err != nil {
+			log.Println("Error resolving socket timeline topics:", err)
+			c.close(websocket.CloseInternalError, "Internal server error")
+			return
+		}
+		for _, ss := range timelines {
+			ss.sub.SetTopics(topics)
+		}
+	}
+
+	c.mu.Lock()
+	dropped := c.relations == relations
+	c.mu.Unlock()
+	if dropped {
+		// Without its signals the viewer would go stale.
+		c.close(websocket.CloseTryAgainLater, "Client is too slow")
+	}
+}
+
+func (c *socketConn) viewerID() uuid.UUID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// subscribe opens a subscription and returns a function that starts
+// forwarding it. Subscribing twice to the same channel is a no-op.
+func (c *socketConn) subscribe(cmd SocketCommandModel) (func(), error) {
+	key := subscriptionKey(cmd)
+
+	c.mu.Lock()
+	_, exists := c.subs[key]
+	count := len(c.subs)
+	c.mu.Unlock()
+	if exists {
+		return nil, nil
+	}
+	if count >= socketMaxSubscriptions {
+		return nil, badRequest("A connection can have at most %d subscriptions", socketMaxSubscriptions)
+	}
+
+	topics, err := c.topics(cmd.Channel, cmd.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, missed, complete := c.s.hub.Subscribe(topics, cmd.Since)
+	ss := &socketSubscription{
+		key:     key,
+		channel: cmd.Channel,
+		target:  cmd.Target,
+		sub:     sub,
+		sent:    make(map[uuid.UUID]bool),
+	}
+
+	c.mu.Lock()
+	c.subs[key] = ss
+	c.mu.Unlock()
+
+	return func() {
+		go c.forward(ss, missed, complete)
+	}, nil
+}
+
+func (c *socketConn) unsubscribe(cmd SocketCommandModel) {
+	key := subscriptionKey(cmd)
+
+	c.mu.Lock()
+	ss, ok := c.subs[key]
+	delete(c.subs, key)
+	c.mu.Unlock()
+
+	if ok {
+		ss.sub.Close()
+	}
+}
+
+func subscriptionKey(cmd SocketCommandModel) string {
+	if cmd.Target == uuid.Nil {
+		return cmd.Channel
+	}
+	return cmd.Channel + ":" + cmd.Target.String()
+}
+
+// topics maps a channel to hub topics, checking that the connection may
+// follow it.
+func (c *socketConn) topics(channel string, target uuid.UUID) ([]string, error) {
+	viewerID := c.viewerID()
+
+	switch channel {
+	case "thread":
+		chirp, err := c.s.db.GetChirpByID(c.ctx, target)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, &requestError{status: http.StatusNotFound, msg: "Chirp not found"}
+			}
+			return nil, err
+		}
+		visible, err := visibility.CanSee(c.ctx, c.s.db, viewerID, chirp)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, &requestError{status: http.StatusNotFound, msg: "Chirp not found"}
+		}
+		return []string{realtime.ThreadTopic(chirp.ID)}, nil
+
+	case "conversation":
+		if viewerID == uuid.Nil {
+			return nil, &requestError{status: http.StatusUnauthorized, msg: "Unauthorized: conversations require a token"}
+		}
+		if _, err := conversationMembers(c.ctx, c.s.db, viewerID, target); err != nil {
+			return nil, err
+		}
+		return []string{realtime.ConversationTopic(target)}, nil
+	}
+
+	return streamTopics(c.ctx, c.viewer, channel)
+}
+
+// forward delivers the messages of a subscription until it is closed. A
+// subscription the hub dropped for falling behind is reported with a reset
+// frame; the client resubscribes from the last seq it saw.
+func (c *socketConn) forward(ss *socketSubscription, missed []realtime.Message, complete bool) {
+	if !complete {
+		c.send(c.subscriptionEvent(ss, "reset"))
+	}
+	for _, msg := range missed {
+		c.deliver(ss, msg)
+	}
+	for msg := range ss.sub.C {
+		c.deliver(ss, msg)
+	}
+
+	c.mu.Lock()
+	dropped := c.subs[ss.key] == ss
+	if dropped {
+		delete(c.subs, ss.key)
+	}
+	c.mu.Unlock()
+
+	if dropped {
+		c.send(c.subscriptionEvent(ss, "reset"))
+	}
+}
+
+func (c *socketConn) deliver(ss *socketSubscription, msg realtime.Message) {
+	data, err := renderRealtime(c.ctx, c.s.db, c.s.renders, c.viewer, msg, ss.sent)
+	if err != nil {
+		log.Printf("Error rendering %s socket message: %v", msg.Type, err)
+		return
+	}
+	if data == nil {
+		return
+	}
+
+	ev := c.subscriptionEvent(ss, "event")
+	ev.Event = msg.Type
+	ev.Seq = msg.ID
+	ev.Data = data
+	c.send(ev)
+}
+
+func (c *socketConn) subscriptionEvent(ss *socketSubscription, typ string) SocketEventModel {
+	ev := SocketEventModel{Type: typ, Channel: ss.channel}
+	if ss.target != uuid.Nil {
+		ev.Target = &ss.target
+	}
+	return ev
+}
+
+// sendMessage posts a message to one of the caller's conversations.
+func (c *socketConn) sendMessage(cmd SocketCommandModel) (any, error) {
+	userID := c.viewerID()
+	if userID == uuid.Nil {
+		return nil, &requestError{status: http.StatusUnauthorized, msg: "Unauthorized: sending messages requires a token"}
+	}
+
+	members, err := conversationMembers(c.ctx, c.s.db, userID, cmd.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := postMessage(c.ctx, c.s.db, c.s.bus, userID, cmd.Target, members, cmd.Body)
+	if err != nil {
+		return nil, err
+	}
+	return convertMessageToResponseModel(message), nil
+}
+
+// send queues a frame for the writer. A client that reads too slowly to keep
+// up is disconnected rather than buffered for.
+func (c *socketConn) send(ev SocketEventModel) {
+	if c.closing.Load() {
+		return
+	}
+
+	select {
+	case c.out <- ev:
+	default:
+		c.close(websocket.CloseTryAgainLater, "Client is too slow")
+	}
+}
+
+// close starts the closing handshake: the writer flushes queued frames, sends
+// the close frame and gives the client a moment to answer it.
+func (c *socketConn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.closing.Store(true)
+		c.closeReq <- socketCloseFrame{code: code, reason: reason}
+	})
+}
+
+func (c *socketConn) writeLoop() {
+	ping := time.NewTicker(socketPingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+
+		case ev := <-c.out:
+			if !c.write(ev) {
+				return
+			}
+
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil); err != nil {
+				c.ws.Close()
+				return
+			}
+
+		case frame := <-c.closeReq:
+			for len(c.out) > 0 {
+				if !c.write(<-c.out) {
+					return
+				}
+			}
+			c.ws.WriteClose(frame.code, frame.reason)
+
+			// The reader returns once the client answers. Hang up on
+			// clients that do not.
+			select {
+			case <-c.ctx.Done():
+			case <-time.After(socketCloseWait):
+				c.ws.Close()
+			}
+			return
+		}
+	}
+}
+
+func (c *socketConn) write(ev SocketEventModel) bool {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("Error encoding socket %s frame: %v", ev.Type, err)
+		return true
+	}
+	if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+		if !errors.Is(err, websocket.ErrCloseSent) {
+			c.ws.Close()
+		}
+		return false
+	}
+	return true
+}
+
+// cleanup releases the connection once the reader is done.
+func (c *socketConn) cleanup() {
+	c.closing.Store(true)
+	c.cancel()
+
+	c.mu.Lock()
+	subs := c.subs
+	c.subs = nil
+	if c.expiry != nil {
+		c.expiry.Stop()
+	}
+	if c.relations != nil {
+		c.relations.Close()
+		c.relations = nil
+	}
+	c.mu.Unlock()
+
+	for _, ss := range subs {
+		ss.sub.Close()
+	}
+	c.ws.Close()
+}
@@ -0,0 +1,529 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/realtime"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	// streamRecentChirps bounds the chirp IDs remembered per connection to
+	// avoid sending a chirp twice when it arrives on two streams.
+	streamRecentChirps = 1000
+	// streamRenderedMessages bounds the chirp messages whose rendering is
+	// shared between connections.
+	streamRenderedMessages = 256
+)
+
+// Stream serves live updates as Server-Sent Events.
+type Stream struct {
+	db        *database.Queries
+	secretKey string
+	hub       *realtime.Hub
+	renders   *chirpRenders
+	draining  chan struct{}
+	drainOnce sync.Once
+}
+
+func NewStreamHandler(db *database.Queries, secretKey string, hub *realtime.Hub) *Stream {
+	return &Stream{
+		db:        db,
+		secretKey: secretKey,
+		hub:       hub,
+		renders:   newChirpRenders(db),
+		draining:  make(chan struct{}),
+	}
+}
+
+// Drain ends every open stream so that the server can shut down. Clients
+// reconnect with their Last-Event-ID, to another instance if there is one.
+func (s *Stream) Drain() {
+	s.drainOnce.Do(func() { close(s.draining) })
+}
+
+// GetStream streams new chirps and notification signals. The streams query
+// parameter picks any of "public", "timeline" and "notifications"; the last
+// two require authentication and are the default for signed-in callers.
+// Clients that reconnect with Last-Event-ID are sent what they missed, or a
+// "reset" event when that is no longer known and they should refetch. Chirps
+// are rendered once for everyone who may see them, so the fields describing
+// the caller's own likes, rechirps and votes are left unset.
+func (s *Stream) GetStream(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := viewer(r, s.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	v := newLiveViewer(s.db, viewerID)
+	streams := streamNames(r, viewerID)
+	topics, err := s.topics(r.Context(), v, streams)
+	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			http.Error(w, reqErr.msg, reqErr.status)
+			return
+		}
+
+		log.Println("Error resolving stream topics:", err)
+		http.Error(w, "Failed to open stream", http.StatusInternalServerError)
+		return
+	}
+
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		if lastID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "Invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	sub, missed, complete := s.hub.Subscribe(topics, lastID)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+
+	sent := make(map[uuid.UUID]bool)
+	for _, msg := range missed {
+		if err := s.send(r, w, v, msg, sent); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.Println("Error flushing stream:", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-s.draining:
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind. The client reconnects with its
+				// Last-Event-ID and catches up from the history.
+				return
+			}
+			if msg.Type == realtime.TypeRelationship {
+				// The viewer's follows, blocks or mutes changed.
+				if err := v.reload(r.Context()); err != nil {
+					log.Println("Error reloading stream viewer:", err)
+					return
+				}
+				topics, err := s.topics(r.Context(), v, streams)
+				if err != nil {
+					log.Println("Error resolving stream topics:", err)
+					return
+				}
+				sub.SetTopics(topics)
+				continue
+			}
+			if err := s.send(r, w, v, msg, sent); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// streamNames returns the streams requested by the streams query parameter,
+// or the default ones for viewerID.
+func streamNames(r *http.Request, viewerID uuid.UUID) []string {
+	streams := r.URL.Query().Get("streams")
+	if streams == "" {
+		streams = "timeline,notifications"
+		if viewerID == uuid.Nil {
+			streams = "public"
+		}
+	}
+	return strings.Split(streams, ",")
+}
+
+// topics maps streams to hub topics. Signed-in viewers also receive their
+// relationship signals, which keep v and the timeline topics current.
+func (s *Stream) topics(ctx context.Context, v *liveViewer, streams []string) ([]string, error) {
+	var topics []string
+	if id := v.id(); id != uuid.Nil {
+		topics = append(topics, realtime.RelationshipTopic(id))
+	}
+	for _, name := range streams {
+		named, err := streamTopics(ctx, v, name)
+		if err != nil {
+			return nil, err
+		}
+		topics = append(topics, named...)
+	}
+	return topics, nil
+}
+
+// streamTopics maps the "public", "timeline" and "notifications" streams to
+// hub topics for the viewer. The last two require authentication.
+func streamTopics(ctx context.Context, v *liveViewer, name string) ([]string, error) {
+	viewerID := v.id()
+	switch name {
+	case "public":
+		return []string{realtime.TopicPublic}, nil
+
+	case "timeline":
+		if viewerID == uuid.Nil {
+			return nil, &requestError{status: http.StatusUnauthorized, msg: "Unauthorized: the timeline stream requires a token"}
+		}
+		return v.timelineTopics(ctx)
+
+	case "notifications":
+		if viewerID == uuid.Nil {
+			return nil, &requestError{status: http.StatusUnauthorized, msg: "Unauthorized: the notifications stream requires a token"}
+		}
+		return []string{realtime.NotificationTopic(viewerID)}, nil
+	}
+	return nil, badRequest("Unknown stream %q", name)
+}
+
+// send writes msg as an event, rendered for v. Chirps the viewer may not see
+// are skipped.
+func (s *Stream) send(r *http.Request, w http.ResponseWriter, v *liveViewer, msg realtime.Message, sent map[uuid.UUID]bool) error {
+	data, err := renderRealtime(r.Context(), s.db, s.renders, v, msg, sent)
+	if err != nil {
+		log.Printf("Error rendering %s stream message: %v", msg.Type, err)
+		return nil
+	}
+	if data == nil {
+		return nil
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, data)
+	return err
+}
+
+// renderRealtime renders msg for v. It returns nil for chirps the viewer may
+// not see, that no longer exist or that are already in sent, the chirp IDs
+// delivered on the same connection.
+func renderRealtime(ctx context.Context, db *database.Queries, renders *chirpRenders, v *liveViewer, msg realtime.Message, sent map[uuid.UUID]bool) ([]byte, error) {
+	switch msg.Type {
+	case realtime.TypeChirp:
+		var payload realtime.ChirpPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		if sent[payload.ChirpID] {
+			return nil, nil
+		}
+
+		rendered, err := renders.get(ctx, msg.ID, payload.ChirpID)
+		if err != nil || rendered.chirp == nil {
+			return nil, err
+		}
+		visible, refVisible, err := v.canSee(ctx, *rendered.chirp, rendered.ref)
+		if err != nil || !visible {
+			return nil, err
+		}
+		data, err := renders.body(ctx, rendered, refVisible)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sent) >= streamRecentChirps {
+			clear(sent)
+		}
+		sent[payload.ChirpID] = true
+		return data, nil
+
+	case realtime.TypeChirpDeleted:
+		return msg.Payload, nil
+
+	case realtime.TypeNotification:
+		unread, err := db.CountUnreadNotifications(ctx, v.id())
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]int64{"unread_count": unread})
+
+	case realtime.TypeMessage:
+		// Only members are subscribed to a conversation's topic.
+		var payload realtime.MessagePayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return nil, err
+		}
+		message, err := db.GetMessageByID(ctx, payload.MessageID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		return json.Marshal(convertMessageToResponseModel(message))
+	}
+	return nil, nil
+}
+
+// liveViewer is the visibility.Filter of a live connection. It is loaded once and
+// reloaded on the relationship signals of the viewer, so that hub messages
+// are filtered without reading the viewer's relationships for each of them.
+type liveViewer struct {
+	db *database.Queries
+
+	mu        sync.Mutex
+	viewerID  uuid.UUID
+	filter    visibility.Filter
+	followees []uuid.UUID
+	loaded    bool
+}
+
+func newLiveViewer(db *database.Queries, viewerID uuid.UUID) *liveViewer {
+	return &liveViewer{db: db, viewerID: viewerID}
+}
+
+func (v *liveViewer) id() uuid.UUID {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.viewerID
+}
+
+// reset switches the connection to viewerID, such as when a socket signs in.
+func (v *liveViewer) reset(viewerID uuid.UUID) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.viewerID = viewerID
+	v.filter = visibility.Filter{}
+	v.followees = nil
+	v.loaded = false
+}
+
+// reload reads the viewer's relationships again.
+func (v *liveViewer) reload(ctx context.Context) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.load(ctx)
+}
+
+// load reads the viewer's blocks, mutes and follows. v.mu must be held.
+func (v *liveViewer) load(ctx context.Context) error {
+	filter, err := visibility.Load(ctx, v.db, v.viewerID)
+	if err != nil {
+		return err
+	}
+
+	var followees []uuid.UUID
+	if v.viewerID != uuid.Nil {
+		if followees, err = v.db.GetFolloweeIDs(ctx, v.viewerID); err != nil {
+			return err
+		}
+		filter.SetFollowed(followees)
+	}
+
+	v.filter, v.followees, v.loaded = filter, followees, true
+	return nil
+}
+
+// timelineTopics returns the hub topics of the viewer's timeline: their own
+// chirps and those of the accounts they follow.
+func (v *liveViewer) timelineTopics(ctx context.Context) ([]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.loaded {
+		if err := v.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	topics := make([]string, 0, len(v.followees)+1)
+	topics = append(topics, realtime.UserTopic(v.viewerID))
+	for _, id := range v.followees {
+		topics = append(topics, realtime.UserTopic(id))
+	}
+	return topics, nil
+}
+
+// canSee applies the rules of chirpResponses to chirp and the chirp it
+// references, if that still exists: visible reports whether chirp is shown at
+// all and refVisible whether ref is embedded in it.
+func (v *liveViewer) canSee(ctx context.Context, chirp database.Chirp, ref *database.Chirp) (visible, refVisible bool, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.loaded {
+		if err := v.load(ctx); err != nil {
+			return false, false, err
+		}
+	}
+
+	chirps := []database.Chirp{chirp}
+	if ref != nil {
+		chirps = append(chirps, *ref)
+	}
+	if err := v.filter.LoadFollows(ctx, v.db, chirps); err != nil {
+		return false, false, err
+	}
+
+	if !v.filter.CanSee(chirp) {
+		return false, false, nil
+	}
+	if ref == nil {
+		return true, false, nil
+	}
+	refVisible = v.filter.CanSee(*ref)
+	if !refVisible && chirp.ReferenceKind.String == referenceRechirp && chirp.UserID != v.viewerID {
+		return false, false, nil
+	}
+	return true, refVisible, nil
+}
+
+// chirpRenders renders the chirps of hub messages once per message and
+// visibility class instead of once per connection. Each connection only
+// decides, with its liveViewer, which rendering its viewer gets.
+type chirpRenders struct {
+	db *database.Queries
+
+	mu      sync.Mutex
+	entries map[uint64]*chirpRender
+}
+
+// chirpRender is the chirp of one hub message, nil once deleted, and the
+// chirp it references. bodies holds its JSON by whether ref is embedded.
+type chirpRender struct {
+	ready chan struct{}
+	chirp *database.Chirp
+	ref   *database.Chirp
+	err   error
+
+	mu     sync.Mutex
+	bodies map[bool][]byte
+}
+
+func newChirpRenders(db *database.Queries) *chirpRenders {
+	return &chirpRenders{db: db, entries: make(map[uint64]*chirpRender)}
+}
+
+// get returns the chirp of message msgID, reading it for the first
+// connection that asks and letting the others wait for it.
+func (c *chirpRenders) get(ctx context.Context, msgID uint64, chirpID uuid.UUID) (*chirpRender, error) {
+	c.mu.Lock()
+	rendered, ok := c.entries[msgID]
+	if !ok {
+		if len(c.entries) >= streamRenderedMessages {
+			clear(c.entries)
+		}
+		rendered = &chirpRender{ready: make(chan struct{}), bodies: make(map[bool][]byte)}
+		c.entries[msgID] = rendered
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		// Other connections wait on this read, so it must outlive the
+		// request that happens to make it.
+		rendered.chirp, rendered.ref, rendered.err = c.read(context.WithoutCancel(ctx), chirpID)
+		close(rendered.ready)
+		if rendered.err != nil {
+			c.mu.Lock()
+			if c.entries[msgID] == rendered {
+				delete(c.entries, msgID)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	select {
+	case <-rendered.ready:
+		return rendered, rendered.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *chirpRenders) read(ctx context.Context, chirpID uuid.UUID) (chirp, ref *database.Chirp, err error) {
+	found, err := c.db.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if !found.ReferenceID.Valid {
+		return &found, nil, nil
+	}
+
+	referenced, err := c.db.GetChirpByID(ctx, found.ReferenceID.UUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &found, nil, nil
+		}
+		return nil, nil, err
+	}
+	return &found, &referenced, nil
+}
+
+// body returns the JSON of rendered, embedding its referenced chirp if
+// withRef is set. Fields describing a viewer's own interactions are unset.
+func (c *chirpRenders) body(ctx context.Context, rendered *chirpRender, withRef bool) ([]byte, error) {
+	rendered.mu.Lock()
+	defer rendered.mu.Unlock()
+	if data, ok := rendered.bodies[withRef]; ok {
+		return data, nil
+	}
+
+	model := convertChirpToResponseModel(*rendered.chirp)
+	all := []*ChirpResponseModel{&model}
+	if withRef {
+		ref := convertChirpToResponseModel(*rendered.ref)
+		model.ReferencedChirp = &ref
+		all = append(all, &ref)
+	} else if rendered.chirp.ReferenceID.Valid {
+		// Deleted, or hidden from this class of viewers.
+		model.ReferenceUnavailable = true
+	}
+
+	if err := fillEntities(ctx, c.db, all); err != nil {
+		return nil, err
+	}
+	if err := fillMedia(ctx, c.db, all); err != nil {
+		return nil, err
+	}
+	if err := fillPolls(ctx, c.db, uuid.Nil, all); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	rendered.bodies[withRef] = data
+	return data, nil
+}
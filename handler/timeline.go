@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+type Timeline struct {
+	db        *database.Queries
+	secretKey string
+	fanout    *timeline.Fanout
+}
+
+func NewTimelineHandler(db *database.Queries, secretKey string, fanout *timeline.Fanout) *Timeline {
+	return &Timeline{db: db, secretKey: secretKey, fanout: fanout}
+}
+
+// GetTimeline returns the newest chirps from the accounts the caller follows.
+func (t *Timeline) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, t.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var cursor *timeline.Entry
+	if p.Cursor != nil {
+		cursor = &timeline.Entry{CreatedAt: p.Cursor.At, ChirpID: p.Cursor.ID}
+	}
+
+	entries, err := t.fanout.Read(r.Context(), userID, cursor, int(p.Limit))
+	if err != nil {
+		log.Println("Error reading timeline:", err)
+		http.Error(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(entries))
+	for _, entry := range entries {
+		ids = append(ids, entry.ChirpID)
+	}
+
+	chirps, err := t.db.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		log.Println("Error retrieving timeline chirps:", err)
+		http.Error(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[uuid.UUID]database.Chirp, len(chirps))
+	for _, chirp := range chirps {
+		byID[chirp.ID] = chirp
+	}
+
+	// Entries whose chirp is gone were deleted after being fanned out and
+	// are skipped until the store catches up.
+	ordered := make([]database.Chirp, 0, len(entries))
+	for _, entry := range entries {
+		if chirp, ok := byID[entry.ChirpID]; ok {
+			ordered = append(ordered, chirp)
+		}
+	}
+
+	resp := ChirpListResponseModel{}
+	resp.Chirps, err = chirpResponses(r.Context(), t.db, userID, ordered)
+	if err != nil {
+		log.Println("Error building timeline chirps:", err)
+		http.Error(w, "Failed to retrieve timeline", http.StatusInternalServerError)
+		return
+	}
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		resp.NextCursor = p.nextCursor(len(entries), pageCursor{At: last.CreatedAt, ID: last.ChirpID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
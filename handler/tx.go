@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jacosy/go-web-server/internal/database"
+)
+
+// withTx runs fn with queries bound to a new transaction, committing if fn
+// succeeds and rolling back otherwise.
+func withTx(ctx context.Context, conn *sql.DB, db *database.Queries, fn func(qtx *database.Queries) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
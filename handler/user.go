@@ -0,0 +1,373 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/imaging"
+	"github.com/jacosy/go-web-server/internal/storage"
+	"github.com/jacosy/go-web-server/internal/utils"
+)
+
+const (
+	// maxUserSearchResults caps the autocomplete list.
+	maxUserSearchResults = 20
+
+	maxDisplayNameLength = 100
+	maxBioLength         = 300
+	maxAvatarBytes       = 5 << 20
+)
+
+// avatarSizes are the square sizes, in pixels, every avatar is stored at.
+var avatarSizes = []struct {
+	name string
+	size int
+}{
+	{"small", 48},
+	{"medium", 128},
+	{"large", 400},
+}
+
+type User struct {
+	db        *database.Queries
+	secretKey string
+	blobs     storage.Storage
+}
+
+func NewUserHandler(db *database.Queries, secretKey string, blobs storage.Storage) *User {
+	return &User{db: db, secretKey: secretKey, blobs: blobs}
+}
+
+func (u *User) GetUserByID(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	u.respondWithProfile(w, r, userID, http.StatusOK)
+}
+
+func (u *User) GetUserByUsername(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimPrefix(r.PathValue("username"), "@")
+	if username == "" {
+		http.Error(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := u.db.GetPublicProfileByUsername(r.Context(), username)
+	if err != nil {
+		respondWithProfileError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, u.convertProfile(database.GetPublicProfileByIDRow(profile)))
+}
+
+// SearchUsers lists users whose username starts with the "q" query parameter,
+// for @-mention autocomplete.
+func (u *User) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Query().Get("q"), "@")
+	if prefix == "" {
+		http.Error(w, "Query parameter q is required", http.StatusBadRequest)
+		return
+	}
+
+	limit := maxUserSearchResults
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxUserSearchResults)
+	}
+
+	rows, err := u.db.SearchUsersByUsernamePrefix(r.Context(), database.SearchUsersByUsernamePrefixParams{
+		Prefix: escapeLike(prefix),
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		log.Println("Error searching users:", err)
+		http.Error(w, "Failed to search users", http.StatusInternalServerError)
+		return
+	}
+
+	users := make([]UserSummaryResponseModel, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, UserSummaryResponseModel{
+			ID:          row.ID,
+			Username:    row.Username,
+			DisplayName: row.DisplayName,
+		})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, users)
+}
+
+// UpdateProfile replaces the caller's display name and bio.
+func (u *User) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, u.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req ProfileRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Bio = strings.TrimSpace(req.Bio)
+	if utf8.RuneCountInString(req.DisplayName) > maxDisplayNameLength {
+		http.Error(w, fmt.Sprintf("Display name exceeds %d characters", maxDisplayNameLength), http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(req.Bio) > maxBioLength {
+		http.Error(w, fmt.Sprintf("Bio exceeds %d characters", maxBioLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := u.db.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		ID:          userID,
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+	}); err != nil {
+		log.Println("Error updating profile:", err)
+		http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+		return
+	}
+
+	u.respondWithProfile(w, r, userID, http.StatusOK)
+}
+
+// UploadAvatar replaces the caller's avatar with the image in the "avatar"
+// field of a multipart form. The image is cropped to a square and stored at
+// every size in avatarSizes.
+func (u *User) UploadAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, u.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Leave room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, maxAvatarBytes+1<<20)
+	file, header, err := r.FormFile("avatar")
+	if err != nil {
+		http.Error(w, "Expected an image in the avatar form field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if declared := header.Header.Get("Content-Type"); declared != "" {
+		if _, ok := imaging.ContentTypes[declared]; !ok {
+			http.Error(w, "Avatar must be a JPEG, PNG or GIF image", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAvatarBytes {
+		http.Error(w, fmt.Sprintf("Avatar exceeds %d bytes", maxAvatarBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	img, contentType, err := imaging.Decode(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrUnsupportedType) {
+			http.Error(w, "Avatar must be a JPEG, PNG or GIF image", http.StatusUnsupportedMediaType)
+			return
+		}
+		http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := u.db.GetPublicProfileByID(r.Context(), userID)
+	if err != nil {
+		respondWithProfileError(w, err)
+		return
+	}
+
+	var key string
+	for _, s := range avatarSizes {
+		var buf bytes.Buffer
+		storedType, err := imaging.Encode(&buf, imaging.Square(img, s.size), contentType)
+		if err != nil {
+			log.Println("Error encoding avatar:", err)
+			http.Error(w, "Failed to process avatar", http.StatusInternalServerError)
+			return
+		}
+
+		if key == "" {
+			ext := ".png"
+			if storedType == "image/jpeg" {
+				ext = ".jpg"
+			}
+			key = fmt.Sprintf("avatars/%s/%s%s", userID, uuid.NewString(), ext)
+		}
+
+		if err := u.blobs.Put(r.Context(), avatarSizeKey(key, s.size), &buf, storedType); err != nil {
+			log.Println("Error storing avatar:", err)
+			http.Error(w, "Failed to store avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := u.db.UpdateUserAvatar(r.Context(), database.UpdateUserAvatarParams{
+		ID:        userID,
+		AvatarKey: key,
+	}); err != nil {
+		log.Println("Error updating avatar:", err)
+		u.deleteAvatar(r.Context(), key)
+		http.Error(w, "Failed to update avatar", http.StatusInternalServerError)
+		return
+	}
+
+	u.deleteAvatar(r.Context(), current.AvatarKey)
+	u.respondWithProfile(w, r, userID, http.StatusOK)
+}
+
+// DeleteAvatar removes the caller's avatar.
+func (u *User) DeleteAvatar(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, u.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	current, err := u.db.GetPublicProfileByID(r.Context(), userID)
+	if err != nil {
+		respondWithProfileError(w, err)
+		return
+	}
+
+	if err := u.db.UpdateUserAvatar(r.Context(), database.UpdateUserAvatarParams{ID: userID}); err != nil {
+		log.Println("Error removing avatar:", err)
+		http.Error(w, "Failed to remove avatar", http.StatusInternalServerError)
+		return
+	}
+
+	u.deleteAvatar(r.Context(), current.AvatarKey)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (u *User) respondWithProfile(w http.ResponseWriter, r *http.Request, userID uuid.UUID, statusCode int) {
+	profile, err := u.db.GetPublicProfileByID(r.Context(), userID)
+	if err != nil {
+		respondWithProfileError(w, err)
+		return
+	}
+
+	utils.ResponseWithJSON(w, statusCode, u.convertProfile(profile))
+}
+
+func (u *User) convertProfile(profile database.GetPublicProfileByIDRow) PublicProfileResponseModel {
+	resp := PublicProfileResponseModel{
+		ID:          profile.ID,
+		Username:    profile.Username,
+		DisplayName: profile.DisplayName,
+		Bio:         profile.Bio,
+		JoinedAt:    profile.CreatedAt.Time,
+		ChirpCount:  profile.ChirpCount,
+	}
+
+	if profile.AvatarKey != "" {
+		resp.Avatar = map[string]string{}
+		for _, s := range avatarSizes {
+			resp.Avatar[s.name] = u.blobs.URL(avatarSizeKey(profile.AvatarKey, s.size))
+		}
+	}
+	return resp
+}
+
+// GetAvatar serves a stored avatar for blob stores that have no public URLs
+// of their own. Only keys below "avatars/" can be reached this way.
+func (u *User) GetAvatar(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(r.PathValue("user"))
+	if err != nil {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+	file := r.PathValue("file")
+	if file == "." || file == ".." {
+		http.Error(w, "Avatar not found", http.StatusNotFound)
+		return
+	}
+
+	blob, err := u.blobs.Get(r.Context(), "avatars/"+ownerID.String()+"/"+file)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Avatar not found", http.StatusNotFound)
+			return
+		}
+
+		log.Println("Error reading avatar:", err)
+		http.Error(w, "Failed to retrieve avatar", http.StatusInternalServerError)
+		return
+	}
+	defer blob.Close()
+
+	// Every upload is stored under a new key.
+	w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(file)))
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, blob)
+}
+
+// deleteAvatar enqueues the removal of every stored size of the avatar under
+// key. A failure only leaves orphaned files behind.
+func (u *User) deleteAvatar(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+
+	keys := make([]string, 0, len(avatarSizes))
+	for _, s := range avatarSizes {
+		keys = append(keys, avatarSizeKey(key, s.size))
+	}
+	purgeBlobsOrLog(ctx, u.db, keys...)
+}
+
+// avatarSizeKey returns the blob key of one size of the avatar under key,
+// e.g. "avatars/<user>/<id>_48.png" for "avatars/<user>/<id>.png".
+func avatarSizeKey(key string, size int) string {
+	ext := path.Ext(key)
+	return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(key, ext), size, ext)
+}
+
+func respondWithProfileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Error retrieving user profile:", err)
+	http.Error(w, "Failed to retrieve user", http.StatusInternalServerError)
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+)
+
+// isBlocked reports whether either of the two users has blocked the other.
+func isBlocked(ctx context.Context, db *database.Queries, userID, otherID uuid.UUID) (bool, error) {
+	ids, err := db.GetBlockedAmong(ctx, database.GetBlockedAmongParams{
+		UserID:  userID,
+		UserIds: []uuid.UUID{otherID},
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
@@ -0,0 +1,363 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/utils"
+	"github.com/jacosy/go-web-server/internal/webhooks"
+)
+
+// maxWebhooks is how many webhooks a user may have.
+const maxWebhooks = 10
+
+// Webhook manages the caller's webhooks and their deliveries.
+type Webhook struct {
+	db         *database.Queries
+	secretKey  string
+	dispatcher *webhooks.Dispatcher
+}
+
+func NewWebhookHandler(db *database.Queries, secretKey string, dispatcher *webhooks.Dispatcher) *Webhook {
+	return &Webhook{db: db, secretKey: secretKey, dispatcher: dispatcher}
+}
+
+// CreateWebhook subscribes a URL to events of the caller. The response holds
+// the signing secret, which is not shown again.
+func (h *Webhook) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	var req WebhookRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(&req); err != nil {
+		respondWithCreateError(w, err)
+		return
+	}
+
+	count, err := h.db.CountWebhooks(r.Context(), userID)
+	if err != nil {
+		log.Println("Error counting webhooks:", err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+	if count >= maxWebhooks {
+		http.Error(w, "Webhook limit reached", http.StatusConflict)
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		log.Println("Error generating webhook secret:", err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	hook, err := h.db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID: userID,
+		URL:    req.URL,
+		Secret: secret,
+		Events: req.Events,
+	})
+	if err != nil {
+		log.Println("Error creating webhook:", err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	resp := convertWebhookToResponseModel(hook)
+	resp.Secret = hook.Secret
+	utils.ResponseWithJSON(w, http.StatusCreated, resp)
+}
+
+func (h *Webhook) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	hooks, err := h.db.GetWebhooks(r.Context(), userID)
+	if err != nil {
+		log.Println("Error retrieving webhooks:", err)
+		http.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]WebhookResponseModel, 0, len(hooks))
+	for _, hook := range hooks {
+		responses = append(responses, convertWebhookToResponseModel(hook))
+	}
+	utils.ResponseWithJSON(w, http.StatusOK, responses)
+}
+
+func (h *Webhook) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.lookupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertWebhookToResponseModel(hook))
+}
+
+// UpdateWebhook replaces the URL and events of one of the caller's webhooks.
+// Enabling a disabled webhook resets its failures, and its pending deliveries
+// resume.
+func (h *Webhook) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.lookupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequestModel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhook(&req); err != nil {
+		respondWithCreateError(w, err)
+		return
+	}
+
+	enabled := !hook.DisabledAt.Valid
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	hook, err := h.db.UpdateWebhook(r.Context(), database.UpdateWebhookParams{
+		URL:     req.URL,
+		Events:  req.Events,
+		Enabled: enabled,
+		ID:      hook.ID,
+		UserID:  hook.UserID,
+	})
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return
+	}
+	if enabled {
+		h.dispatcher.Wake()
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, convertWebhookToResponseModel(hook))
+}
+
+// DeleteWebhook deletes one of the caller's webhooks with its deliveries.
+func (h *Webhook) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, webhookID, ok := h.webhookRequest(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := h.db.DeleteWebhook(r.Context(), database.DeleteWebhookParams{ID: webhookID, UserID: userID})
+	if err != nil {
+		log.Println("Error deleting webhook:", err)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries lists the deliveries of one of the caller's webhooks, newest
+// first.
+func (h *Webhook) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.lookupWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	p, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.db.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID:       hook.ID,
+		BeforeCreatedAt: p.beforeAt(),
+		BeforeID:        p.beforeID(),
+		Limit:           p.Limit,
+	})
+	if err != nil {
+		log.Println("Error retrieving webhook deliveries:", err)
+		http.Error(w, "Failed to retrieve deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	resp := WebhookDeliveryListResponseModel{Deliveries: []WebhookDeliveryResponseModel{}}
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, convertWebhookDeliveryToResponseModel(delivery))
+	}
+	if len(deliveries) > 0 {
+		last := deliveries[len(deliveries)-1]
+		resp.NextCursor = p.nextCursor(len(deliveries), pageCursor{At: last.CreatedAt, ID: last.ID})
+	}
+
+	utils.ResponseWithJSON(w, http.StatusOK, resp)
+}
+
+// Redeliver queues the event of a past delivery again as a new delivery. It
+// keeps the event ID, so receivers can tell it from a new event.
+func (h *Webhook) Redeliver(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.lookupWebhook(w, r)
+	if !ok {
+		return
+	}
+	if hook.DisabledAt.Valid {
+		http.Error(w, "Webhook is disabled", http.StatusConflict)
+		return
+	}
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+
+	original, err := h.db.GetWebhookDelivery(r.Context(), database.GetWebhookDeliveryParams{ID: deliveryID, WebhookID: hook.ID})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Delivery not found", http.StatusNotFound)
+			return
+		}
+		log.Println("Error retrieving webhook delivery:", err)
+		http.Error(w, "Failed to redeliver", http.StatusInternalServerError)
+		return
+	}
+
+	delivery, err := h.db.CreateWebhookDelivery(r.Context(), database.CreateWebhookDeliveryParams{
+		WebhookID: hook.ID,
+		EventID:   original.EventID,
+		EventType: original.EventType,
+		Payload:   original.Payload,
+	})
+	if err != nil {
+		log.Println("Error creating webhook delivery:", err)
+		http.Error(w, "Failed to redeliver", http.StatusInternalServerError)
+		return
+	}
+	h.dispatcher.Wake()
+
+	utils.ResponseWithJSON(w, http.StatusAccepted, convertWebhookDeliveryToResponseModel(delivery))
+}
+
+func (h *Webhook) webhookRequest(w http.ResponseWriter, r *http.Request) (userID, webhookID uuid.UUID, ok bool) {
+	userID, err := authenticate(r, h.secretKey)
+	if err != nil {
+		http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	webhookID, err = uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, webhookID, true
+}
+
+// lookupWebhook returns the webhook of the request, which must belong to the
+// caller.
+func (h *Webhook) lookupWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	userID, webhookID, ok := h.webhookRequest(w, r)
+	if !ok {
+		return database.Webhook{}, false
+	}
+
+	hook, err := h.db.GetWebhook(r.Context(), database.GetWebhookParams{ID: webhookID, UserID: userID})
+	if err != nil {
+		respondWithWebhookError(w, err)
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+// validateWebhook checks the URL and events of req and removes duplicate
+// events.
+func validateWebhook(req *WebhookRequestModel) error {
+	if err := webhooks.ValidateURL(req.URL); err != nil {
+		return badRequest("%s", err)
+	}
+	if len(req.Events) == 0 {
+		return badRequest("At least one event is required")
+	}
+
+	var unique []string
+	for _, e := range req.Events {
+		if !slices.Contains(webhooks.Events, events.Type(e)) {
+			return badRequest("Unsupported event %q", e)
+		}
+		if !slices.Contains(unique, e) {
+			unique = append(unique, e)
+		}
+	}
+	req.Events = unique
+	return nil
+}
+
+func respondWithWebhookError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	log.Println("Error retrieving webhook:", err)
+	http.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+}
+
+func convertWebhookToResponseModel(hook database.Webhook) WebhookResponseModel {
+	resp := WebhookResponseModel{
+		ID:                  hook.ID,
+		URL:                 hook.URL,
+		Events:              hook.Events,
+		Enabled:             !hook.DisabledAt.Valid,
+		ConsecutiveFailures: hook.ConsecutiveFailures,
+		CreatedAt:           hook.CreatedAt,
+		UpdatedAt:           hook.UpdatedAt,
+	}
+	if hook.DisabledAt.Valid {
+		resp.DisabledAt = &hook.DisabledAt.Time
+	}
+	return resp
+}
+
+func convertWebhookDeliveryToResponseModel(delivery database.WebhookDelivery) WebhookDeliveryResponseModel {
+	resp := WebhookDeliveryResponseModel{
+		ID:        delivery.ID,
+		EventID:   delivery.EventID,
+		EventType: delivery.EventType,
+		Payload:   json.RawMessage(delivery.Payload),
+		Status:    delivery.Status,
+		Attempts:  delivery.Attempts,
+		LastError: delivery.LastError.String,
+		CreatedAt: delivery.CreatedAt,
+	}
+	if delivery.Status == "pending" {
+		resp.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastStatusCode.Valid {
+		resp.LastStatusCode = &delivery.LastStatusCode.Int32
+	}
+	if delivery.DeliveredAt.Valid {
+		resp.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return resp
+}
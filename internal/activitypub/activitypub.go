@@ -0,0 +1,166 @@
+// Package activitypub federates Chirpy with the fediverse. Local users are
+// Person actors discoverable through WebFinger, their chirps are Notes in
+// their outboxes, and activities from other servers arrive in their inboxes.
+// Requests between servers are authenticated with HTTP Signatures.
+package activitypub
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ContentType is the media type of ActivityPub documents.
+const ContentType = "application/activity+json"
+
+// Public addresses an activity to everyone.
+const Public = "https://www.w3.org/ns/activitystreams#Public"
+
+const (
+	contextActivityStreams = "https://www.w3.org/ns/activitystreams"
+	contextSecurity        = "https://w3id.org/security/v1"
+)
+
+// Actor is a Person, local or remote.
+type Actor struct {
+	Context           any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	URL               IRI        `json:"url,omitempty"`
+	Inbox             string     `json:"inbox"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Following         string     `json:"following,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         PublicKey  `json:"publicKey"`
+	Published         *time.Time `json:"published,omitempty"`
+}
+
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// Note is a post. Content is HTML.
+type Note struct {
+	Context      any        `json:"@context,omitempty"`
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	AttributedTo IRI        `json:"attributedTo"`
+	Content      string     `json:"content"`
+	URL          IRI        `json:"url,omitempty"`
+	InReplyTo    IRI        `json:"inReplyTo,omitempty"`
+	Published    time.Time  `json:"published"`
+	Updated      *time.Time `json:"updated,omitempty"`
+	To           IRIs       `json:"to,omitempty"`
+	Cc           IRIs       `json:"cc,omitempty"`
+}
+
+// Activity is any activity. Its Object is an IRI or an embedded object:
+// built activities hold the value to send, received ones a string or a
+// map[string]any.
+type Activity struct {
+	Context   any        `json:"@context,omitempty"`
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Actor     IRI        `json:"actor"`
+	Object    any        `json:"object"`
+	To        IRIs       `json:"to,omitempty"`
+	Cc        IRIs       `json:"cc,omitempty"`
+	Published *time.Time `json:"published,omitempty"`
+}
+
+// Tombstone stands in for a deleted object.
+type Tombstone struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type OrderedCollection struct {
+	Context    any    `json:"@context,omitempty"`
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	TotalItems int64  `json:"totalItems"`
+	First      string `json:"first,omitempty"`
+}
+
+type OrderedCollectionPage struct {
+	Context      any    `json:"@context,omitempty"`
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	PartOf       string `json:"partOf"`
+	Next         string `json:"next,omitempty"`
+	OrderedItems []any  `json:"orderedItems"`
+}
+
+// IRI is a reference to an object. Other servers may send a link or an
+// embedded object in its place, of which only the ID or href is kept.
+type IRI string
+
+func (i *IRI) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*i = IRI(objectID(v))
+	return nil
+}
+
+// IRIs is a list of references. A single reference is accepted for it.
+type IRIs []string
+
+func (l *IRIs) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*l = nil
+	if items, ok := v.([]any); ok {
+		for _, item := range items {
+			if id := objectID(item); id != "" {
+				*l = append(*l, id)
+			}
+		}
+		return nil
+	}
+	if id := objectID(v); id != "" {
+		*l = IRIs{id}
+	}
+	return nil
+}
+
+// objectID returns the ID of an object given as an IRI, an embedded object
+// or a link, or of the first of a list of them.
+func objectID(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]any:
+		for _, key := range []string{"id", "href"} {
+			if s, ok := v[key].(string); ok {
+				return s
+			}
+		}
+	case []any:
+		if len(v) > 0 {
+			return objectID(v[0])
+		}
+	}
+	return ""
+}
+
+// objectType returns the type of an embedded object, or "" for an IRI.
+func objectType(v any) string {
+	if m, ok := v.(map[string]any); ok {
+		s, _ := m["type"].(string)
+		return s
+	}
+	return ""
+}
@@ -0,0 +1,688 @@
+package activitypub_test
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/handler"
+	"github.com/jacosy/go-web-server/internal/activitypub"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+)
+
+// instance is an in-process Chirpy server with only its federation routes,
+// backed by memory instead of a database. Deliveries are made right away.
+type instance struct {
+	server  *httptest.Server
+	store   *memoryStore
+	service *activitypub.Service
+}
+
+func newInstance(t *testing.T) *instance {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	store := newMemoryStore()
+	queue := &immediateQueue{}
+	service, err := activitypub.NewService(server.URL, store, server.Client(), queue)
+	if err != nil {
+		t.Fatalf("NewService: %v", err)
+	}
+	queue.service = service
+
+	h := handler.NewFederationHandler(nil, "", service)
+	mux.HandleFunc("GET /.well-known/webfinger", h.WebFinger)
+	mux.HandleFunc("GET /users/{username}", h.GetActor)
+	mux.HandleFunc("GET /users/{username}/outbox", h.GetOutbox)
+	mux.HandleFunc("POST /users/{username}/inbox", h.PostInbox)
+	mux.HandleFunc("POST /inbox", h.PostInbox)
+	mux.HandleFunc("GET /chirps/{id}", h.GetObject)
+
+	return &instance{server: server, store: store, service: service}
+}
+
+func (in *instance) actorURI(username string) string {
+	return in.server.URL + "/users/" + username
+}
+
+func (in *instance) account(username string) string {
+	return username + "@" + strings.TrimPrefix(in.server.URL, "http://")
+}
+
+type immediateQueue struct {
+	service *activitypub.Service
+}
+
+func (q *immediateQueue) Enqueue(ctx context.Context, d activitypub.Delivery) error {
+	return q.service.Deliver(ctx, d)
+}
+
+func TestFederation(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t)
+	b := newInstance(t)
+	alice := a.store.addUser("alice")
+	bob := b.store.addUser("bob")
+
+	// bob on b follows alice on a, found through WebFinger. a accepts.
+	actorA, err := b.service.Follow(ctx, bob, a.account("alice"))
+	if err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+	if actorA.URI != a.actorURI("alice") {
+		t.Fatalf("expected to follow %s, got %s", a.actorURI("alice"), actorA.URI)
+	}
+	if !b.store.followingAccepted(bob, actorA.ID) {
+		t.Fatal("expected the follow to be accepted")
+	}
+	if n := a.store.followerCount(alice); n != 1 {
+		t.Fatalf("expected alice to have 1 remote follower, got %d", n)
+	}
+
+	// A chirp of alice is delivered to b and stored there.
+	chirp := a.store.addChirp(alice, "Hello <fediverse> & friends\nsecond line", "public")
+	if err := a.service.Publish(ctx, events.Event{Type: events.ChirpCreated, ActorID: alice, ChirpID: chirp.ID}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	chirpURI := a.server.URL + "/chirps/" + chirp.ID.String()
+	note, ok := b.store.note(chirpURI)
+	if !ok {
+		t.Fatal("expected the chirp to be stored on b")
+	}
+	if note.Body != chirp.Body {
+		t.Errorf("expected the body %q, got %q", chirp.Body, note.Body)
+	}
+	if !note.PublishedAt.Equal(chirp.CreatedAt.Time) {
+		t.Errorf("expected the note to be published at %v, got %v", chirp.CreatedAt.Time, note.PublishedAt)
+	}
+
+	// The Note is served to anyone, unlike followers-only chirps.
+	if status := get(t, chirpURI); status != http.StatusOK {
+		t.Errorf("expected the Note to be served, got %d", status)
+	}
+	private := a.store.addChirp(alice, "just for followers", "followers")
+	if status := get(t, a.server.URL+"/chirps/"+private.ID.String()); status != http.StatusNotFound {
+		t.Errorf("expected a followers-only chirp not to be served, got %d", status)
+	}
+
+	// bob likes the chirp, and takes it back.
+	bobURI := b.actorURI("bob")
+	like := map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       bobURI + "#likes/1",
+		"type":     "Like",
+		"actor":    bobURI,
+		"object":   chirpURI,
+	}
+	deliver(t, b, bob, a.actorURI("alice")+"/inbox", like)
+	if n := a.store.reactionCount(chirp.ID, "like"); n != 1 {
+		t.Fatalf("expected 1 like, got %d", n)
+	}
+	deliver(t, b, bob, a.server.URL+"/inbox", map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       bobURI + "#likes/1/undo",
+		"type":     "Undo",
+		"actor":    bobURI,
+		"object":   like,
+	})
+	if n := a.store.reactionCount(chirp.ID, "like"); n != 0 {
+		t.Fatalf("expected the like to be undone, got %d", n)
+	}
+
+	// Deleting the chirp deletes it on b.
+	a.store.deleteChirp(chirp.ID)
+	if err := a.service.Publish(ctx, events.Event{Type: events.ChirpDeleted, ActorID: alice, ChirpID: chirp.ID}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if _, ok := b.store.note(chirpURI); ok {
+		t.Error("expected the chirp to be deleted on b")
+	}
+
+	// Unfollowing removes bob from the followers of alice.
+	if err := b.service.Unfollow(ctx, bob, actorA.ID); err != nil {
+		t.Fatalf("Unfollow: %v", err)
+	}
+	if n := a.store.followerCount(alice); n != 0 {
+		t.Errorf("expected alice to have no remote followers, got %d", n)
+	}
+}
+
+func TestInboxRecordsNoteAudience(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t)
+	b := newInstance(t)
+	alice := a.store.addUser("alice")
+	bob := b.store.addUser("bob")
+	if _, err := b.service.Follow(ctx, bob, a.account("alice")); err != nil {
+		t.Fatalf("Follow: %v", err)
+	}
+
+	for _, visibility := range []string{"public", "unlisted", "followers"} {
+		chirp := a.store.addChirp(alice, visibility, visibility)
+		if err := a.service.Publish(ctx, events.Event{Type: events.ChirpCreated, ActorID: alice, ChirpID: chirp.ID}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		note, ok := b.store.note(a.server.URL + "/chirps/" + chirp.ID.String())
+		if !ok {
+			t.Fatalf("expected the %s chirp to be stored on b", visibility)
+		}
+		want := "public"
+		if visibility == "followers" {
+			want = "followers"
+		}
+		if note.Audience != want || len(note.RecipientIds) != 0 {
+			t.Errorf("expected the %s chirp to be stored for %s, got %s to %v", visibility, want, note.Audience, note.RecipientIds)
+		}
+	}
+
+	// A direct message to bob, which the other followers of alice on b must
+	// not see.
+	aliceURI := a.actorURI("alice")
+	dm := a.server.URL + "/notes/1"
+	deliver(t, a, alice, b.actorURI("bob")+"/inbox", map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       dm + "/activity",
+		"type":     "Create",
+		"actor":    aliceURI,
+		"to":       []string{b.actorURI("bob")},
+		"object": map[string]any{
+			"id":           dm,
+			"type":         "Note",
+			"attributedTo": aliceURI,
+			"content":      "just for bob",
+			"to":           []string{b.actorURI("bob"), b.actorURI("nobody")},
+		},
+	})
+	note, ok := b.store.note(dm)
+	if !ok {
+		t.Fatal("expected the direct message to be stored on b")
+	}
+	if note.Audience != "direct" || len(note.RecipientIds) != 1 || note.RecipientIds[0] != bob {
+		t.Errorf("expected the note to be addressed to bob only, got %s to %v", note.Audience, note.RecipientIds)
+	}
+}
+
+func TestInboxRejectsForgeries(t *testing.T) {
+	ctx := context.Background()
+	a := newInstance(t)
+	b := newInstance(t)
+	alice := a.store.addUser("alice")
+	bob := b.store.addUser("bob")
+	mallory := b.store.addUser("mallory")
+	chirp := a.store.addChirp(alice, "like me", "public")
+	chirpURI := a.server.URL + "/chirps/" + chirp.ID.String()
+
+	like := func(actor string) []byte {
+		body, _ := json.Marshal(map[string]any{
+			"id":     actor + "#likes/1",
+			"type":   "Like",
+			"actor":  actor,
+			"object": chirpURI,
+		})
+		return body
+	}
+
+	// Unsigned.
+	resp, err := http.Post(a.server.URL+"/inbox", activitypub.ContentType, bytes.NewReader(like(b.actorURI("bob"))))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an unsigned activity to be rejected with 401, got %d", resp.StatusCode)
+	}
+
+	// Signed by mallory in the name of bob.
+	err = b.service.Deliver(ctx, activitypub.Delivery{
+		UserID:   mallory,
+		Inbox:    a.server.URL + "/inbox",
+		Activity: like(b.actorURI("bob")),
+	})
+	var rejected *activitypub.DeliveryError
+	if !errors.As(err, &rejected) || rejected.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected an activity signed by another actor to be rejected with 401, got %v", err)
+	}
+
+	if n := a.store.reactionCount(chirp.ID, "like"); n != 0 {
+		t.Errorf("expected no likes, got %d", n)
+	}
+
+	// Signed by bob himself.
+	err = b.service.Deliver(ctx, activitypub.Delivery{
+		UserID:   bob,
+		Inbox:    a.server.URL + "/inbox",
+		Activity: like(b.actorURI("bob")),
+	})
+	if err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+	if n := a.store.reactionCount(chirp.ID, "like"); n != 1 {
+		t.Errorf("expected 1 like, got %d", n)
+	}
+}
+
+func get(t *testing.T, url string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Accept", activitypub.ContentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func deliver(t *testing.T, from *instance, userID uuid.UUID, inbox string, activity any) {
+	t.Helper()
+	body, err := json.Marshal(activity)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := from.service.Deliver(context.Background(), activitypub.Delivery{UserID: userID, Inbox: inbox, Activity: body}); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+}
+
+// memoryStore implements activitypub.Store for one instance.
+type memoryStore struct {
+	mu        sync.Mutex
+	users     map[uuid.UUID]database.GetPublicProfileByIDRow
+	chirps    map[uuid.UUID]database.Chirp
+	keys      map[uuid.UUID]database.ActorKey
+	actors    map[uuid.UUID]database.RemoteActor
+	followers map[[2]uuid.UUID]string
+	following map[[2]uuid.UUID]database.RemoteFollowing
+	notes     map[string]database.RemoteNote
+	reactions map[string]database.RemoteReaction
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:     make(map[uuid.UUID]database.GetPublicProfileByIDRow),
+		chirps:    make(map[uuid.UUID]database.Chirp),
+		keys:      make(map[uuid.UUID]database.ActorKey),
+		actors:    make(map[uuid.UUID]database.RemoteActor),
+		followers: make(map[[2]uuid.UUID]string),
+		following: make(map[[2]uuid.UUID]database.RemoteFollowing),
+		notes:     make(map[string]database.RemoteNote),
+		reactions: make(map[string]database.RemoteReaction),
+	}
+}
+
+func (s *memoryStore) addUser(username string) uuid.UUID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := uuid.New()
+	s.users[id] = database.GetPublicProfileByIDRow{
+		ID:        id,
+		Username:  username,
+		CreatedAt: sql.NullTime{Time: time.Now().UTC(), Valid: true},
+	}
+	return id
+}
+
+func (s *memoryStore) addChirp(userID uuid.UUID, body, visibility string) database.Chirp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := sql.NullTime{Time: time.Now().UTC().Truncate(time.Second), Valid: true}
+	chirp := database.Chirp{ID: uuid.New(), UserID: userID, Body: body, CreatedAt: now, UpdatedAt: now, Visibility: visibility}
+	s.chirps[chirp.ID] = chirp
+	return chirp
+}
+
+func (s *memoryStore) deleteChirp(id uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chirps, id)
+}
+
+func (s *memoryStore) followingAccepted(userID, actorID uuid.UUID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.following[[2]uuid.UUID{userID, actorID}].AcceptedAt.Valid
+}
+
+func (s *memoryStore) followerCount(userID uuid.UUID) int {
+	n, _ := s.CountRemoteFollowers(context.Background(), userID)
+	return int(n)
+}
+
+func (s *memoryStore) note(uri string) (database.RemoteNote, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[uri]
+	return note, ok
+}
+
+func (s *memoryStore) reactionCount(chirpID uuid.UUID, kind string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, r := range s.reactions {
+		if r.ChirpID == chirpID && r.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *memoryStore) GetPublicProfileByID(_ context.Context, id uuid.UUID) (database.GetPublicProfileByIDRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[id]
+	if !ok {
+		return user, sql.ErrNoRows
+	}
+	for _, chirp := range s.chirps {
+		if chirp.UserID == id {
+			user.ChirpCount++
+		}
+	}
+	return user, nil
+}
+
+func (s *memoryStore) GetPublicProfileByUsername(ctx context.Context, username string) (database.GetPublicProfileByUsernameRow, error) {
+	s.mu.Lock()
+	var id uuid.UUID
+	for _, user := range s.users {
+		if strings.EqualFold(user.Username, username) {
+			id = user.ID
+		}
+	}
+	s.mu.Unlock()
+
+	profile, err := s.GetPublicProfileByID(ctx, id)
+	return database.GetPublicProfileByUsernameRow(profile), err
+}
+
+func (s *memoryStore) CountFollowers(context.Context, uuid.UUID) (int64, error) { return 0, nil }
+func (s *memoryStore) CountFollowing(context.Context, uuid.UUID) (int64, error) { return 0, nil }
+
+func (s *memoryStore) GetChirpByID(_ context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chirp, ok := s.chirps[id]
+	if !ok {
+		return chirp, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+func (s *memoryStore) GetOutboxChirps(_ context.Context, arg database.GetOutboxChirpsParams) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var chirps []database.Chirp
+	for _, chirp := range s.chirps {
+		if chirp.UserID != arg.UserID || (chirp.Visibility != "public" && chirp.Visibility != "unlisted") {
+			continue
+		}
+		if arg.BeforeCreatedAt.Valid && !chirp.CreatedAt.Time.Before(arg.BeforeCreatedAt.Time) {
+			continue
+		}
+		chirps = append(chirps, chirp)
+	}
+	slices.SortFunc(chirps, func(a, b database.Chirp) int {
+		return b.CreatedAt.Time.Compare(a.CreatedAt.Time)
+	})
+	return chirps[:min(len(chirps), int(arg.Limit))], nil
+}
+
+func (s *memoryStore) GetActorKey(_ context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[userID]
+	if !ok {
+		return key, sql.ErrNoRows
+	}
+	return key, nil
+}
+
+func (s *memoryStore) CreateActorKey(_ context.Context, arg database.CreateActorKeyParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.keys[arg.UserID]; !ok {
+		s.keys[arg.UserID] = database.ActorKey{UserID: arg.UserID, PublicKeyPem: arg.PublicKeyPem, PrivateKeyPem: arg.PrivateKeyPem}
+	}
+	return nil
+}
+
+func (s *memoryStore) GetRemoteActorByID(_ context.Context, id uuid.UUID) (database.RemoteActor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actor, ok := s.actors[id]
+	if !ok {
+		return actor, sql.ErrNoRows
+	}
+	return actor, nil
+}
+
+func (s *memoryStore) GetRemoteActorByURI(_ context.Context, uri string) (database.RemoteActor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, actor := range s.actors {
+		if actor.URI == uri {
+			return actor, nil
+		}
+	}
+	return database.RemoteActor{}, sql.ErrNoRows
+}
+
+func (s *memoryStore) UpsertRemoteActor(ctx context.Context, arg database.UpsertRemoteActorParams) (database.RemoteActor, error) {
+	actor, err := s.GetRemoteActorByURI(ctx, arg.URI)
+	if errors.Is(err, sql.ErrNoRows) {
+		actor = database.RemoteActor{ID: uuid.New(), CreatedAt: time.Now()}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actor.URI = arg.URI
+	actor.Username = arg.Username
+	actor.Domain = arg.Domain
+	actor.DisplayName = arg.DisplayName
+	actor.Summary = arg.Summary
+	actor.URL = arg.URL
+	actor.Inbox = arg.Inbox
+	actor.SharedInbox = arg.SharedInbox
+	actor.PublicKeyID = arg.PublicKeyID
+	actor.PublicKeyPem = arg.PublicKeyPem
+	actor.Followers = arg.Followers
+	actor.FetchedAt = time.Now()
+	actor.UpdatedAt = time.Now()
+	s.actors[actor.ID] = actor
+	return actor, nil
+}
+
+func (s *memoryStore) DeleteRemoteActor(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.actors, id)
+	return nil
+}
+
+func (s *memoryStore) UpsertRemoteFollower(_ context.Context, arg database.UpsertRemoteFollowerParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.followers[[2]uuid.UUID{arg.UserID, arg.ActorID}] = arg.ActivityURI
+	return nil
+}
+
+func (s *memoryStore) DeleteRemoteFollower(_ context.Context, arg database.DeleteRemoteFollowerParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]uuid.UUID{arg.UserID, arg.ActorID}
+	if _, ok := s.followers[key]; !ok {
+		return 0, nil
+	}
+	delete(s.followers, key)
+	return 1, nil
+}
+
+func (s *memoryStore) GetRemoteFollowerInboxes(_ context.Context, userID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var inboxes []string
+	for key := range s.followers {
+		if key[0] != userID {
+			continue
+		}
+		actor := s.actors[key[1]]
+		inbox := cmp.Or(actor.SharedInbox, actor.Inbox)
+		if !slices.Contains(inboxes, inbox) {
+			inboxes = append(inboxes, inbox)
+		}
+	}
+	return inboxes, nil
+}
+
+func (s *memoryStore) CountRemoteFollowers(_ context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key := range s.followers {
+		if key[0] == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) UpsertRemoteFollowing(_ context.Context, arg database.UpsertRemoteFollowingParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.following[[2]uuid.UUID{arg.UserID, arg.ActorID}] = database.RemoteFollowing{
+		UserID:      arg.UserID,
+		ActorID:     arg.ActorID,
+		ActivityURI: arg.ActivityURI,
+		CreatedAt:   time.Now(),
+	}
+	return nil
+}
+
+func (s *memoryStore) AcceptRemoteFollowing(_ context.Context, arg database.AcceptRemoteFollowingParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, f := range s.following {
+		if f.ActivityURI == arg.ActivityURI && f.ActorID == arg.ActorID && !f.AcceptedAt.Valid {
+			f.AcceptedAt = sql.NullTime{Time: time.Now(), Valid: true}
+			s.following[key] = f
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) DeleteRemoteFollowing(_ context.Context, arg database.DeleteRemoteFollowingParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]uuid.UUID{arg.UserID, arg.ActorID}
+	f, ok := s.following[key]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	delete(s.following, key)
+	return f.ActivityURI, nil
+}
+
+func (s *memoryStore) CountRemoteFollowing(_ context.Context, userID uuid.UUID) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for key := range s.following {
+		if key[0] == userID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) IsRemoteActorFollowed(_ context.Context, actorID uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.following {
+		if f.ActorID == actorID && f.AcceptedAt.Valid {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryStore) UpsertRemoteNote(_ context.Context, arg database.UpsertRemoteNoteParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[arg.URI]
+	if ok && note.ActorID != arg.ActorID {
+		return nil
+	}
+	if !ok {
+		note = database.RemoteNote{ID: uuid.New(), URI: arg.URI, ActorID: arg.ActorID, PublishedAt: arg.PublishedAt, CreatedAt: time.Now()}
+	}
+	note.Body = arg.Body
+	note.URL = arg.URL
+	note.InReplyTo = arg.InReplyTo
+	note.Audience = arg.Audience
+	note.RecipientIds = arg.RecipientIds
+	note.UpdatedAt = arg.UpdatedAt
+	s.notes[arg.URI] = note
+	return nil
+}
+
+func (s *memoryStore) DeleteRemoteNote(_ context.Context, arg database.DeleteRemoteNoteParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	note, ok := s.notes[arg.URI]
+	if !ok || note.ActorID != arg.ActorID {
+		return 0, nil
+	}
+	delete(s.notes, arg.URI)
+	return 1, nil
+}
+
+func (s *memoryStore) CreateRemoteReaction(_ context.Context, arg database.CreateRemoteReactionParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range s.reactions {
+		if r.Kind == arg.Kind && r.ActorID == arg.ActorID && r.ChirpID == arg.ChirpID {
+			return nil
+		}
+	}
+	if _, ok := s.reactions[arg.ActivityURI]; !ok {
+		s.reactions[arg.ActivityURI] = database.RemoteReaction{
+			ActivityURI: arg.ActivityURI,
+			Kind:        arg.Kind,
+			ActorID:     arg.ActorID,
+			ChirpID:     arg.ChirpID,
+			CreatedAt:   time.Now(),
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteRemoteReaction(_ context.Context, arg database.DeleteRemoteReactionParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.reactions[arg.ActivityURI]
+	if !ok || r.ActorID != arg.ActorID {
+		return 0, nil
+	}
+	delete(s.reactions, arg.ActivityURI)
+	return 1, nil
+}
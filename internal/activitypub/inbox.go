@@ -0,0 +1,426 @@
+package activitypub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// Inbox handles an activity posted to an inbox, personal or shared, whose
+// body has been read into body. The request must be signed by the actor of
+// the activity; an error wrapping ErrSignature is returned otherwise.
+// Activities of unsupported types are ignored.
+func (s *Service) Inbox(ctx context.Context, r *http.Request, body []byte) error {
+	var a Activity
+	if err := json.Unmarshal(body, &a); err != nil || a.ID == "" || a.Type == "" || a.Actor == "" {
+		return fmt.Errorf("%w: malformed activity", ErrInvalid)
+	}
+
+	sig, err := ParseSignature(r, body)
+	if err != nil {
+		return err
+	}
+
+	// Servers announce deleted accounts to everyone they know, and the
+	// actor can no longer be fetched to verify it. There is nothing to
+	// delete for actors never seen here.
+	if a.Type == "Delete" && objectID(a.Object) == string(a.Actor) {
+		_, err := s.db.GetRemoteActorByURI(ctx, string(a.Actor))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+	}
+
+	signer, err := s.verify(ctx, sig)
+	if err != nil {
+		return err
+	}
+	if string(a.Actor) != signer.URI {
+		return fmt.Errorf("%w: activity of %s signed by %s", ErrSignature, a.Actor, signer.URI)
+	}
+
+	switch a.Type {
+	case "Follow":
+		return s.onFollow(ctx, signer, a)
+	case "Undo":
+		return s.onUndo(ctx, signer, a)
+	case "Accept":
+		return s.onAccept(ctx, signer, a)
+	case "Create":
+		return s.onCreate(ctx, signer, a)
+	case "Like", "Announce":
+		return s.onReaction(ctx, signer, a)
+	case "Delete":
+		return s.onDelete(ctx, signer, a)
+	}
+	return nil
+}
+
+// verify checks sig against the key of the remote actor that made it.
+func (s *Service) verify(ctx context.Context, sig *Signature) (database.RemoteActor, error) {
+	actorURI, _, _ := strings.Cut(sig.KeyID, "#")
+	if _, ok := s.localUsername(actorURI); ok {
+		return database.RemoteActor{}, fmt.Errorf("%w: signed with a local key", ErrSignature)
+	}
+
+	actor, err := s.db.GetRemoteActorByURI(ctx, actorURI)
+	cached := err == nil && actor.PublicKeyID == sig.KeyID
+	if !cached {
+		if actor, err = s.fetchActor(ctx, actorURI); err != nil {
+			return database.RemoteActor{}, fmt.Errorf("%w: fetching key %s: %v", ErrSignature, sig.KeyID, err)
+		}
+	}
+
+	err = verifyWith(sig, actor)
+	if err != nil && cached {
+		// The actor may have replaced its key since it was cached.
+		if actor, err = s.fetchActor(ctx, actor.URI); err != nil {
+			return database.RemoteActor{}, fmt.Errorf("%w: fetching key %s: %v", ErrSignature, sig.KeyID, err)
+		}
+		err = verifyWith(sig, actor)
+	}
+	return actor, err
+}
+
+func verifyWith(sig *Signature, actor database.RemoteActor) error {
+	if actor.PublicKeyID != sig.KeyID {
+		return fmt.Errorf("%w: %s is not the key of %s", ErrSignature, sig.KeyID, actor.URI)
+	}
+	key, err := ParsePublicKey(actor.PublicKeyPem)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	return sig.Verify(key)
+}
+
+// actor returns a remote actor, fetching it when it is not known yet.
+func (s *Service) actor(ctx context.Context, uri string) (database.RemoteActor, error) {
+	actor, err := s.db.GetRemoteActorByURI(ctx, uri)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s.fetchActor(ctx, uri)
+	}
+	return actor, err
+}
+
+// fetchActor fetches a remote actor from its server and stores it. uri may
+// also be the URI of a key published apart from its actor.
+func (s *Service) fetchActor(ctx context.Context, uri string) (database.RemoteActor, error) {
+	var doc struct {
+		Actor
+		Owner string `json:"owner"`
+	}
+	if err := s.get(ctx, uri, ContentType, &doc); err != nil {
+		return database.RemoteActor{}, err
+	}
+	if doc.Inbox == "" && doc.Owner != "" && sameHost(doc.Owner, uri) {
+		uri = doc.Owner
+		if err := s.get(ctx, uri, ContentType, &doc.Actor); err != nil {
+			return database.RemoteActor{}, err
+		}
+	}
+
+	if doc.ID == "" || doc.Inbox == "" || doc.PublicKey.PublicKeyPem == "" {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s is not an actor", ErrRemote, uri)
+	}
+	// An actor is only believed by the server it lives on.
+	if !sameHost(doc.ID, uri) || !sameHost(doc.PublicKey.ID, uri) {
+		return database.RemoteActor{}, fmt.Errorf("%w: %s describes an actor of another server", ErrRemote, uri)
+	}
+
+	u, _ := url.Parse(doc.ID)
+	params := database.UpsertRemoteActorParams{
+		URI:          doc.ID,
+		Username:     doc.PreferredUsername,
+		Domain:       u.Host,
+		DisplayName:  doc.Name,
+		Summary:      plainText(doc.Summary),
+		URL:          string(doc.URL),
+		Inbox:        doc.Inbox,
+		PublicKeyID:  doc.PublicKey.ID,
+		PublicKeyPem: doc.PublicKey.PublicKeyPem,
+		Followers:    doc.Followers,
+	}
+	if doc.Endpoints != nil {
+		params.SharedInbox = doc.Endpoints.SharedInbox
+	}
+	return s.db.UpsertRemoteActor(ctx, params)
+}
+
+// onFollow records a remote follower of a local user and accepts the
+// follow.
+func (s *Service) onFollow(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	username, ok := s.localUsername(objectID(a.Object))
+	if !ok {
+		return fmt.Errorf("%w: Follow of an actor of another server", ErrInvalid)
+	}
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.db.UpsertRemoteFollower(ctx, database.UpsertRemoteFollowerParams{
+		UserID:      profile.ID,
+		ActorID:     signer.ID,
+		ActivityURI: a.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	me := s.actorURI(profile.Username)
+	accept := Activity{
+		Context: contextActivityStreams,
+		ID:      me + "#accepts/" + uuid.NewString(),
+		Type:    "Accept",
+		Actor:   IRI(me),
+		Object:  Activity{ID: a.ID, Type: "Follow", Actor: a.Actor, Object: me},
+		To:      IRIs{signer.URI},
+	}
+	return s.send(ctx, "", profile.ID, accept, signer.Inbox)
+}
+
+// onUndo withdraws a follow, like or announce of the signer.
+func (s *Service) onUndo(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	if objectType(a.Object) == "Follow" {
+		follow := a.Object.(map[string]any)
+		username, ok := s.localUsername(objectID(follow["object"]))
+		if !ok {
+			return nil
+		}
+		profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = s.db.DeleteRemoteFollower(ctx, database.DeleteRemoteFollowerParams{UserID: profile.ID, ActorID: signer.ID})
+		return err
+	}
+
+	_, err := s.db.DeleteRemoteReaction(ctx, database.DeleteRemoteReactionParams{
+		ActivityURI: objectID(a.Object),
+		ActorID:     signer.ID,
+	})
+	return err
+}
+
+// onAccept completes a follow of the signer by a local user.
+func (s *Service) onAccept(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	_, err := s.db.AcceptRemoteFollowing(ctx, database.AcceptRemoteFollowingParams{
+		ActivityURI: objectID(a.Object),
+		ActorID:     signer.ID,
+	})
+	return err
+}
+
+// onCreate stores a Note of an actor followed here or addressed to a local
+// user.
+func (s *Service) onCreate(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	var note Note
+	switch object := a.Object.(type) {
+	case map[string]any:
+		raw, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(raw, &note); err != nil {
+			return fmt.Errorf("%w: malformed object: %v", ErrInvalid, err)
+		}
+	case string:
+		if !sameHost(object, signer.URI) {
+			return fmt.Errorf("%w: Create of an object of another server", ErrInvalid)
+		}
+		if err := s.get(ctx, object, ContentType, &note); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: Create without an object", ErrInvalid)
+	}
+	if note.Type != "Note" {
+		return nil
+	}
+
+	relevant := s.addressesLocal(note.To, note.Cc)
+	if !relevant {
+		followed, err := s.db.IsRemoteActorFollowed(ctx, signer.ID)
+		if err != nil {
+			return err
+		}
+		relevant = followed
+	}
+	if !relevant {
+		return nil
+	}
+	return s.storeNote(ctx, signer, note)
+}
+
+// onReaction records a like or announce of a local chirp. Announces of
+// remote notes by an actor followed here store the note.
+func (s *Service) onReaction(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	target := objectID(a.Object)
+	if chirpID, ok := s.localChirpID(target); ok {
+		chirp, err := s.db.GetChirpByID(ctx, chirpID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && !visibility.Federated(chirp)) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return s.db.CreateRemoteReaction(ctx, database.CreateRemoteReactionParams{
+			ActivityURI: a.ID,
+			Kind:        strings.ToLower(a.Type),
+			ActorID:     signer.ID,
+			ChirpID:     chirp.ID,
+		})
+	}
+
+	if a.Type != "Announce" || target == "" {
+		return nil
+	}
+	followed, err := s.db.IsRemoteActorFollowed(ctx, signer.ID)
+	if err != nil || !followed {
+		return err
+	}
+
+	// An embedded note could be forged by the announcer, so it is fetched
+	// from its server.
+	var note Note
+	if err := s.get(ctx, target, ContentType, &note); err != nil {
+		return err
+	}
+	if note.Type != "Note" {
+		return nil
+	}
+	if note.ID != target {
+		return fmt.Errorf("%w: %s has the ID %s", ErrRemote, target, note.ID)
+	}
+	author, err := s.actor(ctx, string(note.AttributedTo))
+	if err != nil {
+		return err
+	}
+	return s.storeNote(ctx, author, note)
+}
+
+// onDelete deletes a note of the signer, or the signer itself.
+func (s *Service) onDelete(ctx context.Context, signer database.RemoteActor, a Activity) error {
+	target := objectID(a.Object)
+	if target == signer.URI {
+		return s.db.DeleteRemoteActor(ctx, signer.ID)
+	}
+	_, err := s.db.DeleteRemoteNote(ctx, database.DeleteRemoteNoteParams{URI: target, ActorID: signer.ID})
+	return err
+}
+
+// storeNote stores or updates a note of author.
+func (s *Service) storeNote(ctx context.Context, author database.RemoteActor, note Note) error {
+	if string(note.AttributedTo) != author.URI || !sameHost(note.ID, author.URI) {
+		return fmt.Errorf("%w: note %s is not by %s", ErrInvalid, note.ID, author.URI)
+	}
+
+	published := note.Published
+	if published.IsZero() {
+		published = time.Now()
+	}
+	updated := published
+	if note.Updated != nil {
+		updated = *note.Updated
+	}
+
+	recipients, err := s.localRecipients(ctx, note.To, note.Cc)
+	if err != nil {
+		return err
+	}
+
+	return s.db.UpsertRemoteNote(ctx, database.UpsertRemoteNoteParams{
+		URI:          note.ID,
+		ActorID:      author.ID,
+		Body:         plainText(note.Content),
+		URL:          string(note.URL),
+		InReplyTo:    string(note.InReplyTo),
+		Audience:     noteAudience(author, note),
+		RecipientIds: recipients,
+		PublishedAt:  published.UTC(),
+		UpdatedAt:    updated.UTC(),
+	})
+}
+
+// noteAudience returns who may see note of author: "public" for everyone,
+// "followers" for the followers of author, or "direct" for the local users
+// it addresses only.
+func noteAudience(author database.RemoteActor, note Note) string {
+	audience := "direct"
+	for _, addressed := range []IRIs{note.To, note.Cc} {
+		for _, uri := range addressed {
+			switch {
+			case uri == Public || uri == "as:Public" || uri == "Public":
+				return "public"
+			case author.Followers != "" && uri == author.Followers:
+				audience = "followers"
+			}
+		}
+	}
+	return audience
+}
+
+// localRecipients returns the IDs of the local users the audiences name.
+func (s *Service) localRecipients(ctx context.Context, audiences ...IRIs) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	for _, audience := range audiences {
+		for _, uri := range audience {
+			username, ok := s.localUsername(uri)
+			if !ok {
+				continue
+			}
+			profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !slices.Contains(ids, profile.ID) {
+				ids = append(ids, profile.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// addressesLocal reports whether any of the audiences names a local actor.
+func (s *Service) addressesLocal(audiences ...IRIs) bool {
+	for _, audience := range audiences {
+		for _, uri := range audience {
+			if _, ok := s.localUsername(uri); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameHost(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Host != "" && strings.EqualFold(ua.Host, ub.Host)
+}
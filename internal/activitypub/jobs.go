@@ -0,0 +1,67 @@
+package activitypub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+)
+
+// JobDeliver is the kind of the jobs that make deliveries.
+const JobDeliver = "activitypub.deliver"
+
+// deliveryAttempts spans about a day and a half with the backoff of the job
+// queue, long enough for most servers to come back.
+const deliveryAttempts = 40
+
+// JobQueue queues deliveries on the job queue, where RegisterJobs makes
+// them.
+type JobQueue struct {
+	db *database.Queries
+}
+
+func NewJobQueue(db *database.Queries) *JobQueue {
+	return &JobQueue{db: db}
+}
+
+// Enqueue queues a job for d, unless a job with the key of d is pending or
+// running.
+func (q *JobQueue) Enqueue(ctx context.Context, d Delivery) error {
+	opts := jobs.Options{MaxAttempts: deliveryAttempts}
+	if d.Key != "" {
+		opts.UniqueKey = JobDeliver + ":" + d.Key
+	}
+	_, err := jobs.Enqueue(ctx, q.db, JobDeliver, d, opts)
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+// RegisterJobs registers the handler of deliveries on queue.
+func (s *Service) RegisterJobs(queue *jobs.Queue) {
+	queue.Register(JobDeliver, s.runDelivery)
+}
+
+func (s *Service) runDelivery(ctx context.Context, job jobs.Job) error {
+	var d Delivery
+	if err := json.Unmarshal(job.Payload, &d); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	err := s.Deliver(ctx, d)
+	if errors.Is(err, ErrNotFound) {
+		// The local user has been deleted.
+		return jobs.Permanent(err)
+	}
+	var rejected *DeliveryError
+	if errors.As(err, &rejected) && rejected.StatusCode >= 400 && rejected.StatusCode < 500 &&
+		rejected.StatusCode != http.StatusRequestTimeout && rejected.StatusCode != http.StatusTooManyRequests {
+		// The inbox will not take the activity however often it is sent.
+		return jobs.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,689 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+const (
+	// outboxPageSize is how many activities a page of an outbox holds.
+	outboxPageSize = 20
+	// maxDocumentSize bounds the documents fetched from other servers.
+	maxDocumentSize = 1 << 20
+	requestTimeout  = 10 * time.Second
+)
+
+// consumer identifies the service among the consumers of events.
+const consumer = "federation"
+
+var (
+	// ErrNotFound is returned for local and remote objects that do not exist
+	// or are not public.
+	ErrNotFound = errors.New("activitypub: not found")
+	// ErrInvalid is returned for malformed activities and requests.
+	ErrInvalid = errors.New("activitypub: invalid request")
+	// ErrRemote is returned when another server cannot be reached or answers
+	// with an error.
+	ErrRemote = errors.New("activitypub: remote server failed")
+)
+
+// Store is the part of database.Queries the service uses.
+type Store interface {
+	GetPublicProfileByID(ctx context.Context, id uuid.UUID) (database.GetPublicProfileByIDRow, error)
+	GetPublicProfileByUsername(ctx context.Context, username string) (database.GetPublicProfileByUsernameRow, error)
+	CountFollowers(ctx context.Context, followeeID uuid.UUID) (int64, error)
+	CountFollowing(ctx context.Context, followerID uuid.UUID) (int64, error)
+	GetChirpByID(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	GetOutboxChirps(ctx context.Context, arg database.GetOutboxChirpsParams) ([]database.Chirp, error)
+
+	GetActorKey(ctx context.Context, userID uuid.UUID) (database.ActorKey, error)
+	CreateActorKey(ctx context.Context, arg database.CreateActorKeyParams) error
+
+	GetRemoteActorByID(ctx context.Context, id uuid.UUID) (database.RemoteActor, error)
+	GetRemoteActorByURI(ctx context.Context, uri string) (database.RemoteActor, error)
+	UpsertRemoteActor(ctx context.Context, arg database.UpsertRemoteActorParams) (database.RemoteActor, error)
+	DeleteRemoteActor(ctx context.Context, id uuid.UUID) error
+
+	UpsertRemoteFollower(ctx context.Context, arg database.UpsertRemoteFollowerParams) error
+	DeleteRemoteFollower(ctx context.Context, arg database.DeleteRemoteFollowerParams) (int64, error)
+	GetRemoteFollowerInboxes(ctx context.Context, userID uuid.UUID) ([]string, error)
+	CountRemoteFollowers(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	UpsertRemoteFollowing(ctx context.Context, arg database.UpsertRemoteFollowingParams) error
+	AcceptRemoteFollowing(ctx context.Context, arg database.AcceptRemoteFollowingParams) (int64, error)
+	DeleteRemoteFollowing(ctx context.Context, arg database.DeleteRemoteFollowingParams) (string, error)
+	CountRemoteFollowing(ctx context.Context, userID uuid.UUID) (int64, error)
+	IsRemoteActorFollowed(ctx context.Context, actorID uuid.UUID) (bool, error)
+
+	UpsertRemoteNote(ctx context.Context, arg database.UpsertRemoteNoteParams) error
+	DeleteRemoteNote(ctx context.Context, arg database.DeleteRemoteNoteParams) (int64, error)
+	CreateRemoteReaction(ctx context.Context, arg database.CreateRemoteReactionParams) error
+	DeleteRemoteReaction(ctx context.Context, arg database.DeleteRemoteReactionParams) (int64, error)
+}
+
+// Delivery is an activity to post to a remote inbox, signed as a local user.
+type Delivery struct {
+	UserID   uuid.UUID       `json:"user_id"`
+	Inbox    string          `json:"inbox"`
+	Activity json.RawMessage `json:"activity"`
+	// Key identifies the delivery when it may be queued more than once, such
+	// as the deliveries of an event, which are retried until every one is
+	// queued. A Queue queues one delivery per key at a time.
+	Key string `json:"-"`
+}
+
+// Queue queues deliveries, which are made by calling Service.Deliver.
+type Queue interface {
+	Enqueue(ctx context.Context, d Delivery) error
+}
+
+// DeliveryError is returned by Deliver when the inbox rejects an activity.
+type DeliveryError struct {
+	StatusCode int
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("activitypub: inbox answered %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Service is the ActivityPub server and client of this instance.
+type Service struct {
+	// base is the public URL of the server; actor and object IDs are built
+	// from it. domain is its host, which accounts are named after.
+	base   string
+	domain string
+	scheme string
+	db     Store
+	client *http.Client
+	queue  Queue
+}
+
+// NewService returns the service of the server at baseURL, which must not
+// change once the server has federated. Requests to other servers are made
+// with client.
+func NewService(baseURL string, db Store, client *http.Client, queue Queue) (*Service, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("activitypub: base URL must be an absolute http or https URL")
+	}
+	return &Service{
+		base:   baseURL,
+		domain: u.Host,
+		scheme: u.Scheme,
+		db:     db,
+		client: client,
+		queue:  queue,
+	}, nil
+}
+
+func (s *Service) actorURI(username string) string {
+	return s.base + "/users/" + username
+}
+
+func (s *Service) chirpURI(id uuid.UUID) string {
+	return s.base + "/chirps/" + id.String()
+}
+
+// localUsername returns the username of a local actor URI.
+func (s *Service) localUsername(uri string) (string, bool) {
+	username, ok := strings.CutPrefix(uri, s.base+"/users/")
+	if !ok || username == "" || strings.ContainsAny(username, "/?#") {
+		return "", false
+	}
+	return username, true
+}
+
+// localChirpID returns the ID of a local chirp URI.
+func (s *Service) localChirpID(uri string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(uri, s.base+"/chirps/")
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(rest)
+	return id, err == nil
+}
+
+// Actor returns the actor of a local user.
+func (s *Service) Actor(ctx context.Context, username string) (Actor, error) {
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Actor{}, ErrNotFound
+	}
+	if err != nil {
+		return Actor{}, err
+	}
+
+	key, err := s.key(ctx, profile.ID)
+	if err != nil {
+		return Actor{}, err
+	}
+
+	uri := s.actorURI(profile.Username)
+	actor := Actor{
+		Context:           []any{contextActivityStreams, contextSecurity},
+		ID:                uri,
+		Type:              "Person",
+		PreferredUsername: profile.Username,
+		Name:              profile.DisplayName,
+		URL:               IRI(uri),
+		Inbox:             uri + "/inbox",
+		Outbox:            uri + "/outbox",
+		Followers:         uri + "/followers",
+		Following:         uri + "/following",
+		Endpoints:         &Endpoints{SharedInbox: s.base + "/inbox"},
+		PublicKey: PublicKey{
+			ID:           uri + "#main-key",
+			Owner:        uri,
+			PublicKeyPem: key.PublicKeyPem,
+		},
+	}
+	if profile.Bio != "" {
+		actor.Summary = htmlContent(profile.Bio)
+	}
+	if profile.CreatedAt.Valid {
+		actor.Published = &profile.CreatedAt.Time
+	}
+	return actor, nil
+}
+
+// key returns the key pair of a local user, creating it on first use.
+func (s *Service) key(ctx context.Context, userID uuid.UUID) (database.ActorKey, error) {
+	key, err := s.db.GetActorKey(ctx, userID)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return key, err
+	}
+
+	public, private, err := GenerateKey()
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	err = s.db.CreateActorKey(ctx, database.CreateActorKeyParams{
+		UserID:        userID,
+		PublicKeyPem:  public,
+		PrivateKeyPem: private,
+	})
+	if err != nil {
+		return database.ActorKey{}, err
+	}
+	// Read back, in case another request created a key first.
+	return s.db.GetActorKey(ctx, userID)
+}
+
+// Object returns the Note of a chirp, or the Announce of a rechirp. Only
+// chirps visible to anyone are served.
+func (s *Service) Object(ctx context.Context, chirpID uuid.UUID) (any, error) {
+	chirp, err := s.db.GetChirpByID(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !visibility.Federated(chirp)) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.db.GetPublicProfileByID(ctx, chirp.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := s.chirpActivity(chirp, profile.Username)
+	if activity.Type == "Announce" {
+		activity.Context = contextActivityStreams
+		return activity, nil
+	}
+	note := activity.Object.(Note)
+	note.Context = contextActivityStreams
+	return note, nil
+}
+
+// chirpActivity returns the activity that publishes chirp: an Announce for
+// a rechirp and a Create of its Note otherwise.
+func (s *Service) chirpActivity(chirp database.Chirp, username string) Activity {
+	actor := s.actorURI(username)
+	to, cc := audience(chirp.Visibility, actor+"/followers")
+	uri := s.chirpURI(chirp.ID)
+
+	if chirp.ReferenceKind.String == "rechirp" {
+		// The Announce is the rechirp, so it takes the URI of the chirp and
+		// deleting the chirp deletes it.
+		return Activity{
+			ID:        uri,
+			Type:      "Announce",
+			Actor:     IRI(actor),
+			Object:    s.chirpURI(chirp.ReferenceID.UUID),
+			To:        to,
+			Cc:        cc,
+			Published: &chirp.CreatedAt.Time,
+		}
+	}
+
+	content := htmlContent(chirp.Body)
+	if chirp.ReferenceID.Valid {
+		quoted := s.chirpURI(chirp.ReferenceID.UUID)
+		content += `<p>RE: <a href="` + quoted + `">` + quoted + `</a></p>`
+	}
+	note := Note{
+		ID:           uri,
+		Type:         "Note",
+		AttributedTo: IRI(actor),
+		Content:      content,
+		URL:          IRI(uri),
+		Published:    chirp.CreatedAt.Time,
+		To:           to,
+		Cc:           cc,
+	}
+	if chirp.UpdatedAt.Valid && chirp.UpdatedAt.Time.After(chirp.CreatedAt.Time) {
+		note.Updated = &chirp.UpdatedAt.Time
+	}
+
+	return Activity{
+		ID:        uri + "/activity",
+		Type:      "Create",
+		Actor:     IRI(actor),
+		Object:    note,
+		To:        to,
+		Cc:        cc,
+		Published: &chirp.CreatedAt.Time,
+	}
+}
+
+// audience addresses a chirp of the given visibility: public chirps to
+// everyone, unlisted ones to followers with everyone in copy so that they
+// stay off public timelines, and the rest to followers only.
+func audience(v, followers string) (to, cc IRIs) {
+	switch v {
+	case visibility.Public:
+		return IRIs{Public}, IRIs{followers}
+	case visibility.Unlisted:
+		return IRIs{followers}, IRIs{Public}
+	default:
+		return IRIs{followers}, nil
+	}
+}
+
+// Outbox returns the outbox collection of a local user. Its items are on
+// pages, starting with OutboxPage(username, uuid.Nil).
+func (s *Service) Outbox(ctx context.Context, username string) (OrderedCollection, error) {
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrderedCollection{}, ErrNotFound
+	}
+	if err != nil {
+		return OrderedCollection{}, err
+	}
+
+	uri := s.actorURI(profile.Username) + "/outbox"
+	return OrderedCollection{
+		Context:    contextActivityStreams,
+		ID:         uri,
+		Type:       "OrderedCollection",
+		TotalItems: profile.ChirpCount,
+		First:      uri + "?page=true",
+	}, nil
+}
+
+// OutboxPage returns the activities of a local user older than the chirp
+// before, or the newest ones when before is uuid.Nil.
+func (s *Service) OutboxPage(ctx context.Context, username string, before uuid.UUID) (OrderedCollectionPage, error) {
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrderedCollectionPage{}, ErrNotFound
+	}
+	if err != nil {
+		return OrderedCollectionPage{}, err
+	}
+
+	uri := s.actorURI(profile.Username) + "/outbox"
+	params := database.GetOutboxChirpsParams{UserID: profile.ID, Limit: outboxPageSize}
+	page := OrderedCollectionPage{
+		Context:      contextActivityStreams,
+		ID:           uri + "?page=true",
+		Type:         "OrderedCollectionPage",
+		PartOf:       uri,
+		OrderedItems: []any{},
+	}
+
+	if before != uuid.Nil {
+		chirp, err := s.db.GetChirpByID(ctx, before)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.UserID != profile.ID) {
+			return OrderedCollectionPage{}, ErrNotFound
+		}
+		if err != nil {
+			return OrderedCollectionPage{}, err
+		}
+		params.BeforeCreatedAt = chirp.CreatedAt
+		params.BeforeID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		page.ID += "&before=" + before.String()
+	}
+
+	chirps, err := s.db.GetOutboxChirps(ctx, params)
+	if err != nil {
+		return OrderedCollectionPage{}, err
+	}
+	for _, chirp := range chirps {
+		page.OrderedItems = append(page.OrderedItems, s.chirpActivity(chirp, profile.Username))
+	}
+	if len(chirps) == outboxPageSize {
+		page.Next = uri + "?page=true&before=" + chirps[len(chirps)-1].ID.String()
+	}
+	return page, nil
+}
+
+// Followers returns the size of the followers collection of a local user,
+// local and remote followers together. The members are not listed.
+func (s *Service) Followers(ctx context.Context, username string) (OrderedCollection, error) {
+	return s.collection(ctx, username, "followers", s.db.CountFollowers, s.db.CountRemoteFollowers)
+}
+
+// Following returns the size of the following collection of a local user.
+func (s *Service) Following(ctx context.Context, username string) (OrderedCollection, error) {
+	return s.collection(ctx, username, "following", s.db.CountFollowing, s.db.CountRemoteFollowing)
+}
+
+func (s *Service) collection(ctx context.Context, username, name string, counts ...func(context.Context, uuid.UUID) (int64, error)) (OrderedCollection, error) {
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return OrderedCollection{}, ErrNotFound
+	}
+	if err != nil {
+		return OrderedCollection{}, err
+	}
+
+	var total int64
+	for _, count := range counts {
+		n, err := count(ctx, profile.ID)
+		if err != nil {
+			return OrderedCollection{}, err
+		}
+		total += n
+	}
+	return OrderedCollection{
+		Context:    contextActivityStreams,
+		ID:         s.actorURI(profile.Username) + "/" + name,
+		Type:       "OrderedCollection",
+		TotalItems: total,
+	}, nil
+}
+
+// Subscribe registers the service on bus to deliver the chirps of local
+// users to their remote followers.
+func (s *Service) Subscribe(bus *events.Bus) {
+	bus.Subscribe(consumer, s.Publish, events.ChirpCreated, events.ChirpDeleted)
+}
+
+// Publish queues deliveries of the activity for e to the remote followers of
+// its actor. The deliveries are keyed by e and inbox, so that those queued
+// already are skipped when e is delivered again.
+func (s *Service) Publish(ctx context.Context, e events.Event) error {
+	var userID uuid.UUID
+	var activity Activity
+
+	switch e.Type {
+	case events.ChirpCreated:
+		chirp, err := s.db.GetChirpByID(ctx, e.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.HiddenAt.Valid) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		profile, err := s.db.GetPublicProfileByID(ctx, chirp.UserID)
+		if err != nil {
+			return err
+		}
+		userID = chirp.UserID
+		activity = s.chirpActivity(chirp, profile.Username)
+
+	case events.ChirpDeleted:
+		profile, err := s.db.GetPublicProfileByID(ctx, e.ActorID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		uri := s.chirpURI(e.ChirpID)
+		userID = e.ActorID
+		activity = Activity{
+			ID:     uri + "#delete",
+			Type:   "Delete",
+			Actor:  IRI(s.actorURI(profile.Username)),
+			Object: Tombstone{ID: uri, Type: "Tombstone"},
+			To:     IRIs{Public},
+		}
+
+	default:
+		return nil
+	}
+
+	inboxes, err := s.db.GetRemoteFollowerInboxes(ctx, userID)
+	if err != nil || len(inboxes) == 0 {
+		return err
+	}
+	activity.Context = contextActivityStreams
+	return s.send(ctx, e.ID.String(), userID, activity, inboxes...)
+}
+
+// Follow sends a Follow from a local user to the remote account, such as
+// user@example.com. The follow counts once the remote server accepts it.
+func (s *Service) Follow(ctx context.Context, userID uuid.UUID, account string) (database.RemoteActor, error) {
+	actorURI, err := s.Resolve(ctx, account)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	actor, err := s.fetchActor(ctx, actorURI)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	profile, err := s.db.GetPublicProfileByID(ctx, userID)
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+	me := s.actorURI(profile.Username)
+	followURI := me + "#follows/" + uuid.NewString()
+
+	err = s.db.UpsertRemoteFollowing(ctx, database.UpsertRemoteFollowingParams{
+		UserID:      userID,
+		ActorID:     actor.ID,
+		ActivityURI: followURI,
+	})
+	if err != nil {
+		return database.RemoteActor{}, err
+	}
+
+	follow := Activity{
+		Context: contextActivityStreams,
+		ID:      followURI,
+		Type:    "Follow",
+		Actor:   IRI(me),
+		Object:  actor.URI,
+		To:      IRIs{actor.URI},
+	}
+	return actor, s.send(ctx, "", userID, follow, actor.Inbox)
+}
+
+// Unfollow withdraws the follow of a remote actor by a local user.
+func (s *Service) Unfollow(ctx context.Context, userID, actorID uuid.UUID) error {
+	actor, err := s.db.GetRemoteActorByID(ctx, actorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	followURI, err := s.db.DeleteRemoteFollowing(ctx, database.DeleteRemoteFollowingParams{UserID: userID, ActorID: actor.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	profile, err := s.db.GetPublicProfileByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	me := s.actorURI(profile.Username)
+	undo := Activity{
+		Context: contextActivityStreams,
+		ID:      followURI + "/undo",
+		Type:    "Undo",
+		Actor:   IRI(me),
+		Object:  Activity{ID: followURI, Type: "Follow", Actor: IRI(me), Object: actor.URI},
+		To:      IRIs{actor.URI},
+	}
+	return s.send(ctx, "", userID, undo, actor.Inbox)
+}
+
+// send queues deliveries of activity, signed as a local user, to inboxes.
+// Unless key is empty, the deliveries are keyed by it and their inbox.
+func (s *Service) send(ctx context.Context, key string, userID uuid.UUID, activity any, inboxes ...string) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	for _, inbox := range inboxes {
+		d := Delivery{UserID: userID, Inbox: inbox, Activity: body}
+		if key != "" {
+			d.Key = key + " " + inbox
+		}
+		if err := s.queue.Enqueue(ctx, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliver posts a queued activity to its inbox. A *DeliveryError reports
+// that the inbox rejected it.
+func (s *Service) Deliver(ctx context.Context, d Delivery) error {
+	profile, err := s.db.GetPublicProfileByID(ctx, d.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	key, err := s.key(ctx, d.UserID)
+	if err != nil {
+		return err
+	}
+	private, err := ParsePrivateKey(key.PrivateKeyPem)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.Inbox, bytes.NewReader(d.Activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("User-Agent", s.userAgent())
+	if err := SignRequest(req, s.actorURI(profile.Username)+"#main-key", private, d.Activity); err != nil {
+		return err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &DeliveryError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+func (s *Service) userAgent() string {
+	return "Chirpy/1.0 (+" + s.base + ")"
+}
+
+// get fetches a document from another server into v.
+func (s *Service) get(ctx context.Context, uri, accept string, v any) error {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an http or https URL", ErrInvalid, uri)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", s.userAgent())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRemote, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("%w: GET %s answered %s", ErrRemote, uri, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(v); err != nil {
+		return fmt.Errorf("%w: malformed document at %s: %v", ErrRemote, uri, err)
+	}
+	return nil
+}
+
+// htmlContent renders plain text as the HTML content of a Note.
+func htmlContent(text string) string {
+	return "<p>" + strings.ReplaceAll(html.EscapeString(text), "\n", "<br>") + "</p>"
+}
+
+// plainText renders the HTML content of a remote Note as plain text,
+// keeping line and paragraph breaks.
+func plainText(content string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(content, '<')
+		if start < 0 {
+			b.WriteString(content)
+			break
+		}
+		b.WriteString(content[:start])
+		end := strings.IndexByte(content[start:], '>')
+		if end < 0 {
+			break
+		}
+		if name := strings.Fields(strings.ToLower(content[start+1 : start+end])); len(name) > 0 {
+			switch strings.TrimSuffix(name[0], "/") {
+			case "br":
+				b.WriteString("\n")
+			case "/p":
+				b.WriteString("\n\n")
+			}
+		}
+		content = content[start+end+1:]
+	}
+	return strings.TrimSpace(html.UnescapeString(b.String()))
+}
@@ -0,0 +1,222 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// HTTP Signatures as used across the fediverse: draft-cavage-http-signatures
+// with rsa-sha256 keys, signing the request target, Host, Date and, for
+// requests with a body, a SHA-256 Digest of it.
+
+// MaxClockSkew is how far the Date of a signed request may be from now.
+const MaxClockSkew = time.Hour
+
+// ErrSignature is returned for requests whose signature is missing or does
+// not verify.
+var ErrSignature = errors.New("activitypub: invalid signature")
+
+// SignRequest signs req as keyID with key. body must be the request body,
+// or nil for requests without one. Date, Host and Digest are set as needed.
+func SignRequest(req *http.Request, keyID string, key *rsa.PrivateKey, body []byte) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		req.Header.Set("Digest", digest(body))
+		headers = append(headers, "digest")
+	}
+
+	hashed := sha256.Sum256([]byte(signingString(req, headers)))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// Signature is the parsed Signature header of a request.
+type Signature struct {
+	// KeyID identifies the key that made the signature; for actors it is the
+	// ID of their publicKey.
+	KeyID     string
+	signed    string
+	signature []byte
+}
+
+// ParseSignature reads the signature of r, whose body has been read into
+// body. It checks everything but the signature itself, which Verify checks
+// once the key of KeyID is known: the Date must be recent, the signature
+// must cover the request target, Host, Date and, with a body, a Digest
+// matching it.
+func ParseSignature(r *http.Request, body []byte) (*Signature, error) {
+	header := r.Header.Get("Signature")
+	if header == "" {
+		return nil, fmt.Errorf("%w: no Signature header", ErrSignature)
+	}
+	params := parseParams(header)
+
+	if alg := params["algorithm"]; alg != "" && alg != "rsa-sha256" && alg != "hs2019" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrSignature, alg)
+	}
+	if params["keyId"] == "" {
+		return nil, fmt.Errorf("%w: no keyId", ErrSignature)
+	}
+	signature, err := base64.StdEncoding.DecodeString(params["signature"])
+	if err != nil || len(signature) == 0 {
+		return nil, fmt.Errorf("%w: malformed signature", ErrSignature)
+	}
+
+	headers := strings.Fields(strings.ToLower(params["headers"]))
+	if len(headers) == 0 {
+		headers = []string{"date"}
+	}
+	required := []string{"(request-target)", "host", "date"}
+	if len(body) > 0 {
+		required = append(required, "digest")
+	}
+	for _, h := range required {
+		if !slices.Contains(headers, h) {
+			return nil, fmt.Errorf("%w: %s is not signed", ErrSignature, h)
+		}
+	}
+
+	date, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed Date", ErrSignature)
+	}
+	if skew := time.Since(date); skew > MaxClockSkew || skew < -MaxClockSkew {
+		return nil, fmt.Errorf("%w: Date is too far from now", ErrSignature)
+	}
+	if len(body) > 0 && r.Header.Get("Digest") != digest(body) {
+		return nil, fmt.Errorf("%w: Digest does not match the body", ErrSignature)
+	}
+
+	return &Signature{
+		KeyID:     params["keyId"],
+		signed:    signingString(r, headers),
+		signature: signature,
+	}, nil
+}
+
+// Verify checks the signature against key.
+func (s *Signature) Verify(key *rsa.PublicKey) error {
+	hashed := sha256.Sum256([]byte(s.signed))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], s.signature); err != nil {
+		return fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+	return nil
+}
+
+func signingString(r *http.Request, headers []string) string {
+	lines := make([]string, 0, len(headers))
+	for _, h := range headers {
+		var value string
+		switch h {
+		case "(request-target)":
+			value = strings.ToLower(r.Method) + " " + r.URL.RequestURI()
+		case "host":
+			value = r.Host
+			if value == "" {
+				value = r.URL.Host
+			}
+		default:
+			value = strings.Join(r.Header.Values(h), ", ")
+		}
+		lines = append(lines, h+": "+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseParams splits a header of comma-separated key="value" pairs.
+func parseParams(header string) map[string]string {
+	params := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		params[key] = strings.Trim(value, `"`)
+	}
+	return params
+}
+
+func digest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// GenerateKey returns a new key pair for an actor, PEM-encoded.
+func GenerateKey() (publicPEM, privatePEM string, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", "", err
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", "", err
+	}
+	private, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: private})), nil
+}
+
+// ParsePublicKey reads an RSA public key in PKIX or PKCS #1 PEM.
+func ParsePublicKey(s string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: malformed public key")
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("activitypub: public key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// ParsePrivateKey reads an RSA private key in PKCS #8 PEM, as made by
+// GenerateKey.
+func ParsePrivateKey(s string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(s))
+	if block == nil {
+		return nil, errors.New("activitypub: malformed private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("activitypub: private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,118 @@
+package activitypub_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/activitypub"
+)
+
+func TestSignatures(t *testing.T) {
+	publicPEM, privatePEM, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	private, err := activitypub.ParsePrivateKey(privatePEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKey: %v", err)
+	}
+	public, err := activitypub.ParsePublicKey(publicPEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+	otherPublicPEM, _, err := activitypub.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	otherPublic, err := activitypub.ParsePublicKey(otherPublicPEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKey: %v", err)
+	}
+
+	body := []byte(`{"type":"Follow"}`)
+	const keyID = "https://a.example/users/alice#main-key"
+
+	tests := []struct {
+		name   string
+		tamper func(r *http.Request) []byte
+		// otherKey verifies with a key other than the signer's.
+		otherKey bool
+		valid    bool
+	}{
+		{
+			name:   "valid",
+			tamper: func(*http.Request) []byte { return body },
+			valid:  true,
+		},
+		{
+			name:   "changed body",
+			tamper: func(*http.Request) []byte { return []byte(`{"type":"Block"}`) },
+		},
+		{
+			name: "changed path",
+			tamper: func(r *http.Request) []byte {
+				r.URL.Path = "/users/bob/inbox"
+				return body
+			},
+		},
+		{
+			name: "changed date",
+			tamper: func(r *http.Request) []byte {
+				r.Header.Set("Date", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+				return body
+			},
+		},
+		{
+			name: "old date",
+			tamper: func(r *http.Request) []byte {
+				r.Header.Set("Date", time.Now().Add(-2*activitypub.MaxClockSkew).UTC().Format(http.TimeFormat))
+				return body
+			},
+		},
+		{
+			name: "no signature",
+			tamper: func(r *http.Request) []byte {
+				r.Header.Del("Signature")
+				return body
+			},
+		},
+		{
+			name:     "other key",
+			tamper:   func(*http.Request) []byte { return body },
+			otherKey: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "https://b.example/inbox", bytes.NewReader(body))
+			if err := activitypub.SignRequest(req, keyID, private, body); err != nil {
+				t.Fatalf("SignRequest: %v", err)
+			}
+			received := tt.tamper(req)
+
+			key := public
+			if tt.otherKey {
+				key = otherPublic
+			}
+			sig, err := activitypub.ParseSignature(req, received)
+			if err == nil {
+				if sig.KeyID != keyID {
+					t.Errorf("expected key ID %q, got %q", keyID, sig.KeyID)
+				}
+				err = sig.Verify(key)
+			}
+
+			if tt.valid && err != nil {
+				t.Errorf("expected a valid signature, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, activitypub.ErrSignature) {
+				t.Errorf("expected ErrSignature, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package activitypub
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// JRD is a WebFinger response (RFC 7033).
+type JRD struct {
+	Subject string   `json:"subject"`
+	Aliases []string `json:"aliases,omitempty"`
+	Links   []Link   `json:"links"`
+}
+
+type Link struct {
+	Rel  string `json:"rel"`
+	Type string `json:"type,omitempty"`
+	Href string `json:"href,omitempty"`
+}
+
+// WebFinger describes the local user named by resource, which is an
+// acct:user@domain URI or the URI of their actor.
+func (s *Service) WebFinger(ctx context.Context, resource string) (JRD, error) {
+	username, ok := s.localUsername(resource)
+	if !ok {
+		user, domain, found := strings.Cut(strings.TrimPrefix(resource, "acct:"), "@")
+		if !found || !strings.EqualFold(domain, s.domain) {
+			return JRD{}, ErrNotFound
+		}
+		username = user
+	}
+
+	profile, err := s.db.GetPublicProfileByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return JRD{}, ErrNotFound
+	}
+	if err != nil {
+		return JRD{}, err
+	}
+
+	actorURI := s.actorURI(profile.Username)
+	return JRD{
+		Subject: "acct:" + profile.Username + "@" + s.domain,
+		Aliases: []string{actorURI},
+		Links: []Link{
+			{Rel: "self", Type: ContentType, Href: actorURI},
+		},
+	}, nil
+}
+
+// Resolve looks up the actor URI of an account such as user@example.com
+// with WebFinger on its server.
+func (s *Service) Resolve(ctx context.Context, account string) (string, error) {
+	account = strings.TrimPrefix(strings.TrimPrefix(account, "acct:"), "@")
+	user, domain, ok := strings.Cut(account, "@")
+	if !ok || user == "" || domain == "" || strings.ContainsAny(domain, "/?#@") {
+		return "", fmt.Errorf("%w: account must look like user@example.com", ErrInvalid)
+	}
+	if strings.EqualFold(domain, s.domain) {
+		return "", fmt.Errorf("%w: %s is a local account", ErrInvalid, account)
+	}
+
+	// Servers are reached with the scheme of this one, so that development
+	// instances can federate over plain HTTP.
+	endpoint := s.scheme + "://" + domain + "/.well-known/webfinger?resource=" + url.QueryEscape("acct:"+account)
+	var jrd JRD
+	if err := s.get(ctx, endpoint, "application/jrd+json", &jrd); err != nil {
+		return "", err
+	}
+
+	for _, link := range jrd.Links {
+		if link.Rel == "self" && (link.Type == ContentType || strings.HasPrefix(link.Type, "application/ld+json")) && link.Href != "" {
+			return link.Href, nil
+		}
+	}
+	return "", ErrNotFound
+}
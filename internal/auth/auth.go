@@ -21,12 +21,12 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	utcNow := time.Now().UTC()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy",
 		IssuedAt:  jwt.NewNumericDate(utcNow),
-		ExpiresAt: jwt.NewNumericDate(utcNow.Add(1 * time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(utcNow.Add(expiresIn)),
 		Subject:   userID.String(),
 	})
 
@@ -34,19 +34,36 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	userID, _, err := ParseJWT(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ParseJWT validates a token like ValidateJWT and also returns when it
+// expires, for connections that outlive a single request. Tokens without an
+// expiry return the zero time.
+func ParseJWT(tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, time.Time{}, err
 	}
 
 	claims, ok := token.Claims.(*jwt.RegisteredClaims)
 	if !ok || !token.Valid {
-		return uuid.Nil, errors.New("invalid token claims or token is not valid")
+		return uuid.Nil, time.Time{}, errors.New("invalid token claims or token is not valid")
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
 	}
 
-	return uuid.Parse(claims.Subject)
+	var expiresAt time.Time
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return userID, expiresAt, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -76,3 +76,22 @@ func TestValidateJWT(t *testing.T) {
 		}
 	}
 }
+
+func TestParseJWTReturnsExpiry(t *testing.T) {
+	before := time.Now().Add(time.Hour).Truncate(time.Second)
+	authToken, err := auth.MakeJWT(userID, tokenSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create JWT: %v", err)
+	}
+
+	parsedUserID, expiresAt, err := auth.ParseJWT(authToken, tokenSecret)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if parsedUserID != userID {
+		t.Fatalf("Expected userID '%s', but got '%s'", userID, parsedUserID)
+	}
+	if expiresAt.Before(before) || expiresAt.After(time.Now().Add(time.Hour)) {
+		t.Fatalf("Expected the token to expire in an hour, but got %v", expiresAt)
+	}
+}
@@ -0,0 +1,77 @@
+// Package broker carries messages between the instances of the server. What
+// one instance publishes is delivered to the subscribers of every instance,
+// itself included, so that in-process state such as live streams and cached
+// timelines stays in sync across them.
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is one message delivered on a channel. IDs grow in the order
+// messages are published and are the same on every instance.
+type Message struct {
+	ID      int64
+	Channel string
+	Payload []byte
+	// Lost is set, instead of a payload, when messages published since the
+	// previous one delivered may never be, such as after the broker was cut
+	// off for longer than messages are kept.
+	Lost bool
+}
+
+// Handler processes one message. Handlers run on the broker's delivery
+// goroutine and should return quickly and log their own failures.
+type Handler func(ctx context.Context, msg Message)
+
+type Broker interface {
+	// Publish sends payload to the subscribers of channel on every instance.
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe registers h for the messages of channel. Handlers must be
+	// registered before Start.
+	Subscribe(channel string, h Handler)
+	// Start begins delivering messages until ctx is done.
+	Start(ctx context.Context) error
+}
+
+// registry holds the handlers of a broker by channel.
+type registry struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func (r *registry) Subscribe(channel string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = make(map[string][]Handler)
+	}
+	r.handlers[channel] = append(r.handlers[channel], h)
+}
+
+func (r *registry) deliver(ctx context.Context, msg Message) {
+	r.mu.RLock()
+	handlers := r.handlers[msg.Channel]
+	r.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, msg)
+	}
+}
+
+// lose tells the handlers of every channel that messages may have been lost.
+func (r *registry) lose(ctx context.Context) {
+	r.mu.RLock()
+	handlers := make(map[string][]Handler, len(r.handlers))
+	for channel, hs := range r.handlers {
+		handlers[channel] = hs
+	}
+	r.mu.RUnlock()
+
+	for channel, hs := range handlers {
+		for _, h := range hs {
+			h(ctx, Message{Channel: channel, Lost: true})
+		}
+	}
+}
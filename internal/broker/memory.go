@@ -0,0 +1,27 @@
+package broker
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Memory delivers messages within the process only. It suits a single
+// instance, where it adds no latency or database load.
+type Memory struct {
+	registry
+	lastID atomic.Int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+// Publish delivers payload to the subscribers of channel before returning.
+func (m *Memory) Publish(ctx context.Context, channel string, payload []byte) error {
+	m.deliver(ctx, Message{ID: m.lastID.Add(1), Channel: channel, Payload: payload})
+	return nil
+}
+
+func (m *Memory) Start(context.Context) error {
+	return nil
+}
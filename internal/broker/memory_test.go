@@ -0,0 +1,33 @@
+package broker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/broker"
+)
+
+func TestMemoryDeliversByChannel(t *testing.T) {
+	ctx := context.Background()
+	b := broker.NewMemory()
+
+	var got []string
+	b.Subscribe("a", func(_ context.Context, msg broker.Message) { got = append(got, "a1:"+string(msg.Payload)) })
+	b.Subscribe("a", func(_ context.Context, msg broker.Message) { got = append(got, "a2:"+string(msg.Payload)) })
+	b.Subscribe("b", func(_ context.Context, msg broker.Message) { got = append(got, "b:"+string(msg.Payload)) })
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if err := b.Publish(ctx, "a", []byte("x")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := b.Publish(ctx, "c", []byte("y")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	want := []string{"a1:x", "a2:x"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,268 @@
+package broker
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	// notifyChannel is the channel the broker_messages trigger notifies.
+	notifyChannel = "broker_messages"
+	// catchUpSlack is how far below the newest ID seen catch-up reads. IDs are
+	// taken before commit, so a lower one can become visible after a higher
+	// one. Messages delivered already are skipped.
+	catchUpSlack = 100
+	// recentSize bounds the IDs remembered to skip duplicates.
+	recentSize   = 10000
+	pingInterval = 90 * time.Second
+	// defaultRetention is how long NewPostgres keeps messages for catch-up.
+	defaultRetention = time.Hour
+	pruneInterval    = 10 * time.Minute
+	minReconnect     = time.Second
+	maxReconnect     = time.Minute
+	catchUpTimeout   = 30 * time.Second
+)
+
+// Store is the part of database.Queries the Postgres broker uses.
+type Store interface {
+	CreateBrokerMessage(ctx context.Context, arg database.CreateBrokerMessageParams) error
+	GetBrokerMessage(ctx context.Context, id int64) (database.BrokerMessage, error)
+	GetBrokerMessagesAfter(ctx context.Context, id int64) ([]database.BrokerMessage, error)
+	GetLatestBrokerMessageID(ctx context.Context) (int64, error)
+	DeleteExpiredBrokerMessages(ctx context.Context, retentionSeconds float64) (int64, error)
+}
+
+// Listener receives the NOTIFY payloads of the broker_messages trigger. A
+// nil notification means the connection was re-established and
+// notifications may have been missed. *pq.Listener implements it.
+type Listener interface {
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+	Close() error
+}
+
+// Postgres delivers messages to every instance connected to the same
+// database. Messages are written to the broker_messages table, whose trigger
+// announces them with NOTIFY. After its listener reconnects, an instance reads
+// what it missed from the table, and reports messages as lost when it was cut
+// off for longer than the table keeps them.
+type Postgres struct {
+	registry
+	db        Store
+	retention time.Duration
+	connect   func() (Listener, error)
+
+	// Only the listening goroutine uses these. floor is the newest ID when the
+	// broker started; older messages were never meant for this instance.
+	// syncedAt is when the listener was last known to miss nothing.
+	floor    int64
+	lastID   int64
+	syncedAt time.Time
+	recent   map[int64]struct{}
+	order    []int64
+}
+
+// NewPostgres returns a broker that listens on its own connection to dsn.
+func NewPostgres(db Store, dsn string) *Postgres {
+	return NewPostgresWithListener(db, defaultRetention, func() (Listener, error) {
+		listener := pq.NewListener(dsn, minReconnect, maxReconnect, func(ev pq.ListenerEventType, err error) {
+			switch ev {
+			case pq.ListenerEventDisconnected:
+				log.Printf("broker: listener disconnected: %v", err)
+			case pq.ListenerEventReconnected:
+				log.Println("broker: listener reconnected")
+			case pq.ListenerEventConnectionAttemptFailed:
+				log.Printf("broker: listener failed to connect: %v", err)
+			}
+		})
+		if err := listener.Listen(notifyChannel); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		return listener, nil
+	})
+}
+
+// NewPostgresWithListener returns a broker that keeps messages for retention
+// and receives their notifications from the listener connect opens on Start.
+func NewPostgresWithListener(db Store, retention time.Duration, connect func() (Listener, error)) *Postgres {
+	return &Postgres{db: db, retention: retention, connect: connect, recent: make(map[int64]struct{})}
+}
+
+func (p *Postgres) Publish(ctx context.Context, channel string, payload []byte) error {
+	return p.db.CreateBrokerMessage(ctx, database.CreateBrokerMessageParams{
+		Channel: channel,
+		Payload: string(payload),
+	})
+}
+
+// Start listens for messages in the background. The listener reconnects on
+// its own with backoff.
+func (p *Postgres) Start(ctx context.Context) error {
+	p.syncedAt = time.Now()
+	floor, err := p.db.GetLatestBrokerMessageID(ctx)
+	if err != nil {
+		return err
+	}
+	p.floor, p.lastID = floor, floor
+
+	listener, err := p.connect()
+	if err != nil {
+		return err
+	}
+
+	go p.listen(ctx, listener)
+	go p.prune(ctx)
+	return nil
+}
+
+func (p *Postgres) listen(ctx context.Context, listener Listener) {
+	defer listener.Close()
+
+	// Messages published between reading the floor and listening.
+	caughtUp := p.catchUp(ctx)
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n, ok := <-listener.NotificationChannel():
+			if !ok {
+				return
+			}
+			if n == nil {
+				// The connection was re-established and notifications sent
+				// in the meantime are lost.
+				caughtUp = p.catchUp(ctx)
+				continue
+			}
+			if caughtUp {
+				p.syncedAt = time.Now()
+			}
+			if err := p.notified(ctx, n.Extra); err != nil {
+				log.Printf("broker: failed to handle notification %q: %v", n.Extra, err)
+			}
+
+		case <-ping.C:
+			// Detects connections that died without the listener noticing.
+			pinged := time.Now()
+			if err := listener.Ping(); err != nil {
+				log.Printf("broker: listener ping failed: %v", err)
+			} else if caughtUp {
+				p.syncedAt = pinged
+			}
+			if !caughtUp {
+				caughtUp = p.catchUp(ctx)
+			}
+		}
+	}
+}
+
+// notification is the NOTIFY payload built by the broker_messages trigger.
+// Payload is null for messages too large to fit.
+type notification struct {
+	ID      int64   `json:"id"`
+	Channel string  `json:"channel"`
+	Payload *string `json:"payload"`
+}
+
+func (p *Postgres) notified(ctx context.Context, extra string) error {
+	var n notification
+	if err := json.Unmarshal([]byte(extra), &n); err != nil {
+		return err
+	}
+
+	msg := database.BrokerMessage{ID: n.ID, Channel: n.Channel}
+	if n.Payload != nil {
+		msg.Payload = *n.Payload
+	} else {
+		var err error
+		if msg, err = p.db.GetBrokerMessage(ctx, n.ID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				// Pruned already.
+				return nil
+			}
+			return err
+		}
+	}
+
+	p.deliverOnce(ctx, msg)
+	return nil
+}
+
+// catchUp delivers the messages this instance has not seen yet. It reports
+// whether that succeeded; failures are retried on the next ping. When the
+// listener may have missed messages for longer than they are kept, the
+// subscribers are told that some are lost before the rest is delivered.
+func (p *Postgres) catchUp(ctx context.Context) bool {
+	ctx, cancel := context.WithTimeout(ctx, catchUpTimeout)
+	defer cancel()
+
+	started := time.Now()
+	messages, err := p.db.GetBrokerMessagesAfter(ctx, max(p.floor, p.lastID-catchUpSlack))
+	if err != nil {
+		log.Printf("broker: failed to catch up after message %d: %v", p.lastID, err)
+		return false
+	}
+
+	if started.Sub(p.syncedAt) >= p.retention {
+		log.Printf("broker: listener was cut off since %s, messages may be lost", p.syncedAt.Format(time.RFC3339))
+		p.lose(ctx)
+	}
+	p.syncedAt = started
+
+	for _, msg := range messages {
+		p.deliverOnce(ctx, msg)
+	}
+	return true
+}
+
+// deliverOnce delivers msg unless it came before the broker started or was
+// delivered already.
+func (p *Postgres) deliverOnce(ctx context.Context, msg database.BrokerMessage) {
+	if msg.ID <= p.floor {
+		return
+	}
+	if _, ok := p.recent[msg.ID]; ok {
+		return
+	}
+
+	p.recent[msg.ID] = struct{}{}
+	p.order = append(p.order, msg.ID)
+	if len(p.order) > recentSize {
+		delete(p.recent, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.lastID = max(p.lastID, msg.ID)
+
+	p.deliver(ctx, Message{ID: msg.ID, Channel: msg.Channel, Payload: []byte(msg.Payload)})
+}
+
+// prune deletes messages older than the catch-up window. Every instance
+// prunes; the deletes are idempotent.
+func (p *Postgres) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.db.DeleteExpiredBrokerMessages(ctx, p.retention.Seconds()); err != nil {
+				log.Printf("broker: failed to prune messages: %v", err)
+			}
+		}
+	}
+}
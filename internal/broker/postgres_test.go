@@ -0,0 +1,193 @@
+package broker_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/broker"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/lib/pq"
+)
+
+// memoryStore keeps broker messages in memory in place of the table.
+type memoryStore struct {
+	mu       sync.Mutex
+	messages []database.BrokerMessage
+}
+
+func (s *memoryStore) CreateBrokerMessage(_ context.Context, arg database.CreateBrokerMessageParams) error {
+	s.add(arg.Channel, arg.Payload)
+	return nil
+}
+
+func (s *memoryStore) add(channel, payload string) database.BrokerMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	msg := database.BrokerMessage{ID: int64(len(s.messages) + 1), Channel: channel, Payload: payload, CreatedAt: time.Now()}
+	s.messages = append(s.messages, msg)
+	return msg
+}
+
+func (s *memoryStore) GetBrokerMessage(_ context.Context, id int64) (database.BrokerMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, msg := range s.messages {
+		if msg.ID == id {
+			return msg, nil
+		}
+	}
+	return database.BrokerMessage{}, sql.ErrNoRows
+}
+
+func (s *memoryStore) GetBrokerMessagesAfter(_ context.Context, id int64) ([]database.BrokerMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var after []database.BrokerMessage
+	for _, msg := range s.messages {
+		if msg.ID > id {
+			after = append(after, msg)
+		}
+	}
+	return after, nil
+}
+
+func (s *memoryStore) GetLatestBrokerMessageID(context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.messages)), nil
+}
+
+func (s *memoryStore) DeleteExpiredBrokerMessages(context.Context, float64) (int64, error) {
+	return 0, nil
+}
+
+// fakeListener hands the notifications a test sends to the broker.
+type fakeListener struct {
+	c chan *pq.Notification
+}
+
+func (l *fakeListener) NotificationChannel() <-chan *pq.Notification { return l.c }
+func (l *fakeListener) Ping() error                                  { return nil }
+func (l *fakeListener) Close() error                                 { return nil }
+
+// notify announces msg like the broker_messages trigger, leaving the payload
+// out when large is set.
+func (l *fakeListener) notify(t *testing.T, msg database.BrokerMessage, large bool) {
+	t.Helper()
+	n := map[string]any{"id": msg.ID, "channel": msg.Channel, "payload": msg.Payload}
+	if large {
+		n["payload"] = nil
+	}
+	extra, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("Failed to encode notification: %v", err)
+	}
+	l.c <- &pq.Notification{Channel: "broker_messages", Extra: string(extra)}
+}
+
+// reconnect signals a re-established connection, as pq.Listener does.
+func (l *fakeListener) reconnect() {
+	l.c <- nil
+}
+
+func startPostgres(t *testing.T, store *memoryStore, retention time.Duration) (*fakeListener, <-chan broker.Message) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	listener := &fakeListener{c: make(chan *pq.Notification)}
+	b := broker.NewPostgresWithListener(store, retention, func() (broker.Listener, error) {
+		return listener, nil
+	})
+
+	received := make(chan broker.Message, 10)
+	b.Subscribe("realtime", func(_ context.Context, msg broker.Message) { received <- msg })
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	return listener, received
+}
+
+// expect reads the next messages delivered and compares their IDs, or
+// -1 for a lost signal, to want.
+func expect(t *testing.T, received <-chan broker.Message, want ...int64) {
+	t.Helper()
+	for _, id := range want {
+		select {
+		case msg := <-received:
+			got := msg.ID
+			if msg.Lost {
+				got = -1
+			}
+			if got != id {
+				t.Fatalf("Expected message %d, got %d", id, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected message %d, got none", id)
+		}
+	}
+	select {
+	case msg := <-received:
+		t.Fatalf("Expected no more messages, got %+v", msg)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestPostgresDeliversNotifications(t *testing.T) {
+	store := &memoryStore{}
+	store.add("realtime", "before start")
+	listener, received := startPostgres(t, store, time.Hour)
+
+	small := store.add("realtime", "small")
+	large := store.add("realtime", "large")
+	listener.notify(t, small, false)
+	listener.notify(t, large, true)
+
+	expect(t, received, small.ID, large.ID)
+}
+
+func TestPostgresDeliversOnce(t *testing.T) {
+	store := &memoryStore{}
+	listener, received := startPostgres(t, store, time.Hour)
+
+	msg := store.add("realtime", "x")
+	listener.notify(t, msg, false)
+	listener.notify(t, msg, false)
+	// Catch-up reads the message again.
+	listener.reconnect()
+
+	expect(t, received, msg.ID)
+}
+
+func TestPostgresCatchesUpAfterReconnecting(t *testing.T) {
+	store := &memoryStore{}
+	listener, received := startPostgres(t, store, time.Hour)
+
+	first := store.add("realtime", "a")
+	listener.notify(t, first, false)
+	expect(t, received, first.ID)
+
+	// Published while the listener was disconnected.
+	second := store.add("realtime", "b")
+	third := store.add("realtime", "c")
+	listener.reconnect()
+
+	expect(t, received, second.ID, third.ID)
+}
+
+func TestPostgresReportsLostMessages(t *testing.T) {
+	store := &memoryStore{}
+	retention := 50 * time.Millisecond
+	listener, received := startPostgres(t, store, retention)
+
+	// Cut off for longer than messages are kept.
+	time.Sleep(2 * retention)
+	msg := store.add("realtime", "kept")
+	listener.reconnect()
+
+	expect(t, received, -1, msg.ID)
+}
@@ -7,25 +7,44 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body, created_at, updated_at)
+WITH referenced AS (
+    UPDATE chirps
+    SET rechirp_count = rechirp_count + ($1::text = 'rechirp')::int,
+        quote_count = quote_count + ($1::text = 'quote')::int
+    WHERE id = $2::uuid
+)
+INSERT INTO chirps (id, user_id, body, created_at, updated_at, reference_id, reference_kind, visibility)
 VALUES (
-    gen_random_uuid(), $1, $2, NOW(), NOW()
+    gen_random_uuid(), $3, $4, NOW(), NOW(), $2, $1, $5
 )
-RETURNING id, user_id, body, created_at, updated_at
+RETURNING id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
 `
 
 type CreateChirpParams struct {
-	UserID uuid.UUID
-	Body   string
+	ReferenceKind sql.NullString
+	ReferenceID   uuid.NullUUID
+	UserID        uuid.UUID
+	Body          string
+	Visibility    string
 }
 
+// A rechirp or quote bumps the referenced chirp's counter in the same
+// statement, so the insert and the count succeed or fail together.
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.UserID, arg.Body)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.ReferenceKind,
+		arg.ReferenceID,
+		arg.UserID,
+		arg.Body,
+		arg.Visibility,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,13 +52,45 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.ReferenceID,
+		&i.ReferenceKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.SearchDocument,
+		&i.HiddenAt,
+		&i.Visibility,
 	)
 	return i, err
 }
 
+const deleteChirp = `-- name: DeleteChirp :exec
+WITH deleted AS (
+    DELETE FROM chirps
+    WHERE chirps.id = $1 AND chirps.user_id = $2
+    RETURNING reference_id, reference_kind
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count - (d.reference_kind = 'rechirp')::int,
+    quote_count = quote_count - (d.reference_kind = 'quote')::int
+FROM deleted d
+WHERE chirps.id = d.reference_id
+`
+
+type DeleteChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteChirp(ctx context.Context, arg DeleteChirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteChirp, arg.ID, arg.UserID)
+	return err
+}
+
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, user_id, body, created_at, updated_at
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
 FROM chirps
+WHERE visibility <> 'unlisted'
 ORDER BY created_at
 `
 
@@ -58,6 +109,83 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.Body,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.ReferenceID,
+			&i.ReferenceKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.SearchDocument,
+			&i.HiddenAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCelebrityTimeline = `-- name: GetCelebrityTimeline :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.search_document, c.hidden_at, c.visibility
+FROM chirps c
+WHERE c.user_id IN (
+    SELECT f.followee_id
+    FROM follows f
+    JOIN users u ON u.id = f.followee_id
+    WHERE f.follower_id = $1
+      AND u.follower_count >= $2::bigint
+  )
+  AND (
+    $3::timestamp IS NULL
+    OR (c.created_at, c.id) < ($3::timestamp, $4::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $5
+`
+
+type GetCelebrityTimelineParams struct {
+	UserID          uuid.UUID
+	MinFollowers    int64
+	BeforeCreatedAt sql.NullTime
+	BeforeID        uuid.NullUUID
+	Limit           int32
+}
+
+func (q *Queries) GetCelebrityTimeline(ctx context.Context, arg GetCelebrityTimelineParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getCelebrityTimeline,
+		arg.UserID,
+		arg.MinFollowers,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.ReferenceID,
+			&i.ReferenceKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.SearchDocument,
+			&i.HiddenAt,
+			&i.Visibility,
 		); err != nil {
 			return nil, err
 		}
@@ -73,7 +201,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, user_id, body, created_at, updated_at
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
 FROM chirps
 WHERE id = $1
 `
@@ -87,6 +215,220 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.Body,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.ReferenceID,
+		&i.ReferenceKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.SearchDocument,
+		&i.HiddenAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.ReferenceID,
+			&i.ReferenceKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.SearchDocument,
+			&i.HiddenAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentChirpsByUser = `-- name: GetRecentChirpsByUser :many
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2
+`
+
+type GetRecentChirpsByUserParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentChirpsByUser(ctx context.Context, arg GetRecentChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsByUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.LikeCount,
+			&i.ReferenceID,
+			&i.ReferenceKind,
+			&i.RechirpCount,
+			&i.QuoteCount,
+			&i.SearchDocument,
+			&i.HiddenAt,
+			&i.Visibility,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE user_id = $1 AND reference_id = $2 AND reference_kind = 'rechirp'
+`
+
+type GetRechirpParams struct {
+	UserID      uuid.UUID
+	ReferenceID uuid.NullUUID
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.ReferenceID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.ReferenceID,
+		&i.ReferenceKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.SearchDocument,
+		&i.HiddenAt,
+		&i.Visibility,
+	)
+	return i, err
+}
+
+const getRechirpedChirpIDs = `-- name: GetRechirpedChirpIDs :many
+SELECT reference_id
+FROM chirps
+WHERE user_id = $1
+  AND reference_kind = 'rechirp'
+  AND reference_id = ANY($2::uuid[])
+`
+
+type GetRechirpedChirpIDsParams struct {
+	UserID   uuid.UUID
+	ChirpIds []uuid.UUID
+}
+
+func (q *Queries) GetRechirpedChirpIDs(ctx context.Context, arg GetRechirpedChirpIDsParams) ([]uuid.NullUUID, error) {
+	rows, err := q.db.QueryContext(ctx, getRechirpedChirpIDs, arg.UserID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.NullUUID
+	for rows.Next() {
+		var reference_id uuid.NullUUID
+		if err := rows.Scan(&reference_id); err != nil {
+			return nil, err
+		}
+		items = append(items, reference_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+`
+
+type UpdateChirpBodyParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Body   string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.UserID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Body,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LikeCount,
+		&i.ReferenceID,
+		&i.ReferenceKind,
+		&i.RechirpCount,
+		&i.QuoteCount,
+		&i.SearchDocument,
+		&i.HiddenAt,
+		&i.Visibility,
 	)
 	return i, err
 }
@@ -11,12 +11,214 @@ import (
 	"github.com/google/uuid"
 )
 
+type ActorKey struct {
+	UserID        uuid.UUID
+	PublicKeyPem  string
+	PrivateKeyPem string
+	CreatedAt     time.Time
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type BrokerMessage struct {
+	ID        int64
+	Channel   string
+	Payload   string
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	Body           string
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+	LikeCount      int32
+	ReferenceID    uuid.NullUUID
+	ReferenceKind  sql.NullString
+	RechirpCount   int32
+	QuoteCount     int32
+	SearchDocument interface{}
+	HiddenAt       sql.NullTime
+	Visibility     string
+}
+
+type ChirpHashtag struct {
+	ChirpID     uuid.UUID
+	Tag         string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID
+	UserID      uuid.UUID
+	Username    string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedBy uuid.UUID
+	DirectKey sql.NullString
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	LastReadAt     sql.NullTime
+	JoinedAt       time.Time
+}
+
+type Draft struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	Body          string
+	QuotedChirpID uuid.NullUUID
+	MediaIds      []uuid.UUID
+	PublishAt     sql.NullTime
+	LastError     string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Visibility    string
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     string
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   sql.NullString
+	UniqueKey   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	FinishedAt  sql.NullTime
+}
+
+type MediaAttachment struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     sql.NullInt32
+	StorageKey   string
+	ThumbnailKey string
+	ContentType  string
+	Width        int32
+	Height       int32
+	SizeBytes    int64
+	AltText      string
+	CreatedAt    time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+	CreatedAt      time.Time
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID
+	ModeratorID   uuid.NullUUID
+	ReportID      uuid.NullUUID
+	Action        string
+	TargetUserID  uuid.NullUUID
+	TargetChirpID uuid.NullUUID
+	Note          string
+	CreatedAt     time.Time
+}
+
+type ModerationFlag struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
+	Rule      string
+	Excerpt   string
+	CreatedAt time.Time
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
-	Body      string
-	CreatedAt sql.NullTime
-	UpdatedAt sql.NullTime
+	Kind      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type NotificationActor struct {
+	NotificationID uuid.UUID
+	ActorID        uuid.UUID
+	CreatedAt      time.Time
+}
+
+type OutboxEvent struct {
+	ID            int64
+	EventID       uuid.UUID
+	EventType     string
+	Payload       string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastError     sql.NullString
+	CreatedAt     time.Time
+	PublishedAt   sql.NullTime
+	FailedAt      sql.NullTime
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	CreatedAt time.Time
+}
+
+type PollOption struct {
+	ChirpID   uuid.UUID
+	Position  int32
+	Text      string
+	VoteCount int32
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
+}
+
+type ProcessedEvent struct {
+	Consumer    string
+	EventID     uuid.UUID
+	ProcessedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +230,88 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type RemoteActor struct {
+	ID           uuid.UUID
+	URI          string
+	Username     string
+	Domain       string
+	DisplayName  string
+	Summary      string
+	URL          string
+	Inbox        string
+	SharedInbox  string
+	PublicKeyID  string
+	PublicKeyPem string
+	FetchedAt    time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Followers    string
+}
+
+type RemoteFollower struct {
+	UserID      uuid.UUID
+	ActorID     uuid.UUID
+	ActivityURI string
+	CreatedAt   time.Time
+}
+
+type RemoteFollowing struct {
+	UserID      uuid.UUID
+	ActorID     uuid.UUID
+	ActivityURI string
+	AcceptedAt  sql.NullTime
+	CreatedAt   time.Time
+}
+
+type RemoteNote struct {
+	ID           uuid.UUID
+	URI          string
+	ActorID      uuid.UUID
+	Body         string
+	URL          string
+	InReplyTo    string
+	PublishedAt  time.Time
+	UpdatedAt    time.Time
+	CreatedAt    time.Time
+	Audience     string
+	RecipientIds []uuid.UUID
+}
+
+type RemoteReaction struct {
+	ActivityURI string
+	Kind        string
+	ActorID     uuid.UUID
+	ChirpID     uuid.UUID
+	CreatedAt   time.Time
+}
+
+type Report struct {
+	ID         uuid.UUID
+	ReporterID uuid.NullUUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Category   string
+	Details    string
+	Status     string
+	ClaimedBy  uuid.NullUUID
+	ClaimedAt  sql.NullTime
+	Resolution sql.NullString
+	ResolvedAt sql.NullTime
+	CreatedAt  time.Time
+}
+
+type TimelineEntry struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+type TimelineHorizon struct {
+	UserID  uuid.UUID
+	Horizon time.Time
+}
+
 type User struct {
 	ID             uuid.UUID
 	Username       string
@@ -35,4 +319,38 @@ type User struct {
 	CreatedAt      sql.NullTime
 	UpdatedAt      sql.NullTime
 	HashedPassword string
+	DisplayName    string
+	Bio            string
+	AvatarKey      string
+	IsModerator    bool
+	SuspendedUntil sql.NullTime
+	FollowerCount  int32
+}
+
+type Webhook struct {
+	ID                  uuid.UUID
+	UserID              uuid.UUID
+	URL                 string
+	Secret              string
+	Events              []string
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        string
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode sql.NullInt32
+	LastError      sql.NullString
+	DeliveredAt    sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
@@ -10,6 +10,7 @@ import (
 	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
@@ -47,8 +48,72 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 	return i, err
 }
 
+const getPublicProfileByID = `-- name: GetPublicProfileByID :one
+SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.created_at,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id) AS chirp_count
+FROM users u
+WHERE u.id = $1
+`
+
+type GetPublicProfileByIDRow struct {
+	ID          uuid.UUID
+	Username    string
+	DisplayName string
+	Bio         string
+	AvatarKey   string
+	CreatedAt   sql.NullTime
+	ChirpCount  int64
+}
+
+func (q *Queries) GetPublicProfileByID(ctx context.Context, id uuid.UUID) (GetPublicProfileByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfileByID, id)
+	var i GetPublicProfileByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.CreatedAt,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
+const getPublicProfileByUsername = `-- name: GetPublicProfileByUsername :one
+SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.created_at,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id) AS chirp_count
+FROM users u
+WHERE lower(u.username) = lower($1)
+`
+
+type GetPublicProfileByUsernameRow struct {
+	ID          uuid.UUID
+	Username    string
+	DisplayName string
+	Bio         string
+	AvatarKey   string
+	CreatedAt   sql.NullTime
+	ChirpCount  int64
+}
+
+func (q *Queries) GetPublicProfileByUsername(ctx context.Context, username string) (GetPublicProfileByUsernameRow, error) {
+	row := q.db.QueryRowContext(ctx, getPublicProfileByUsername, username)
+	var i GetPublicProfileByUsernameRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarKey,
+		&i.CreatedAt,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, username, email, hashed_password, created_at, updated_at
+SELECT id, username, email, hashed_password, created_at, updated_at, display_name, bio
 FROM users
 WHERE email = $1
 `
@@ -60,6 +125,8 @@ type GetUserByEmailRow struct {
 	HashedPassword string
 	CreatedAt      sql.NullTime
 	UpdatedAt      sql.NullTime
+	DisplayName    string
+	Bio            string
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -72,10 +139,91 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.HashedPassword,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.DisplayName,
+		&i.Bio,
 	)
 	return i, err
 }
 
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, username, email, created_at, updated_at
+FROM users
+WHERE id = $1
+`
+
+type GetUserByIDRow struct {
+	ID        uuid.UUID
+	Username  string
+	Email     string
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i GetUserByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Email,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserModeration = `-- name: GetUserModeration :one
+SELECT is_moderator, suspended_until
+FROM users
+WHERE id = $1
+`
+
+type GetUserModerationRow struct {
+	IsModerator    bool
+	SuspendedUntil sql.NullTime
+}
+
+func (q *Queries) GetUserModeration(ctx context.Context, id uuid.UUID) (GetUserModerationRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserModeration, id)
+	var i GetUserModerationRow
+	err := row.Scan(&i.IsModerator, &i.SuspendedUntil)
+	return i, err
+}
+
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, username
+FROM users
+WHERE lower(username) = ANY($1::text[])
+`
+
+type GetUsersByUsernamesRow struct {
+	ID       uuid.UUID
+	Username string
+}
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]GetUsersByUsernamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernamesRow
+	for rows.Next() {
+		var i GetUsersByUsernamesRow
+		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const reset = `-- name: Reset :exec
 DELETE FROM users
 `
@@ -85,6 +233,65 @@ func (q *Queries) Reset(ctx context.Context) error {
 	return err
 }
 
+const searchUsersByUsernamePrefix = `-- name: SearchUsersByUsernamePrefix :many
+SELECT id, username, display_name
+FROM users
+WHERE lower(username) LIKE lower($1::text) || '%'
+ORDER BY lower(username)
+LIMIT $2
+`
+
+type SearchUsersByUsernamePrefixParams struct {
+	Prefix string
+	Limit  int32
+}
+
+type SearchUsersByUsernamePrefixRow struct {
+	ID          uuid.UUID
+	Username    string
+	DisplayName string
+}
+
+func (q *Queries) SearchUsersByUsernamePrefix(ctx context.Context, arg SearchUsersByUsernamePrefixParams) ([]SearchUsersByUsernamePrefixRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsersByUsernamePrefix, arg.Prefix, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchUsersByUsernamePrefixRow
+	for rows.Next() {
+		var i SearchUsersByUsernamePrefixRow
+		if err := rows.Scan(&i.ID, &i.Username, &i.DisplayName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil sql.NullTime
+	ID             uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.ID)
+	return err
+}
+
 const truncateUsers = `-- name: TruncateUsers :exec
 TRUNCATE TABLE users RESTART IDENTITY
 `
@@ -93,3 +300,36 @@ func (q *Queries) TruncateUsers(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, truncateUsers)
 	return err
 }
+
+const updateUserAvatar = `-- name: UpdateUserAvatar :exec
+UPDATE users
+SET avatar_key = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserAvatarParams struct {
+	ID        uuid.UUID
+	AvatarKey string
+}
+
+func (q *Queries) UpdateUserAvatar(ctx context.Context, arg UpdateUserAvatarParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserAvatar, arg.ID, arg.AvatarKey)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :exec
+UPDATE users
+SET display_name = $2, bio = $3, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	DisplayName string
+	Bio         string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserProfile, arg.ID, arg.DisplayName, arg.Bio)
+	return err
+}
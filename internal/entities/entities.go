@@ -0,0 +1,73 @@
+// Package entities finds hashtags and @mentions in chirp bodies.
+package entities
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	TypeHashtag = "hashtag"
+	TypeMention = "mention"
+)
+
+// maxUsernameLength matches the users.username column.
+const maxUsernameLength = 50
+
+// Entity is a hashtag or mention found in a body. Start and End are offsets
+// in Unicode code points, End exclusive, and cover the leading '#' or '@'.
+type Entity struct {
+	Type  string
+	Text  string // tag or username without the sigil, lowercased for hashtags
+	Start int
+	End   int
+}
+
+// Parse returns the entities of body in the order they appear.
+func Parse(body string) []Entity {
+	runes := []rune(body)
+	var found []Entity
+
+	for i := 0; i < len(runes); i++ {
+		sigil := runes[i]
+		if sigil != '#' && sigil != '@' {
+			continue
+		}
+		// "a#b" and "me@example.com" are not entities.
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		text := string(runes[i+1 : end])
+
+		switch {
+		case sigil == '#' && strings.IndexFunc(text, unicode.IsLetter) >= 0:
+			found = append(found, Entity{Type: TypeHashtag, Text: strings.ToLower(text), Start: i, End: end})
+		case sigil == '@' && text != "" && isUsername(text):
+			found = append(found, Entity{Type: TypeMention, Text: text, Start: i, End: end})
+		}
+		i = end - 1
+	}
+
+	return found
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isUsername(s string) bool {
+	if len(s) > maxUsernameLength {
+		return false
+	}
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package entities_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/entities"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name string
+		body string
+		want []entities.Entity
+	}{
+		{
+			name: "No entities",
+			body: "just a plain chirp",
+			want: nil,
+		},
+		{
+			name: "Hashtag and mention",
+			body: "hi @alice, see #GoLang!",
+			want: []entities.Entity{
+				{Type: entities.TypeMention, Text: "alice", Start: 3, End: 9},
+				{Type: entities.TypeHashtag, Text: "golang", Start: 15, End: 22},
+			},
+		},
+		{
+			name: "Offsets count code points",
+			body: "héllo #café",
+			want: []entities.Entity{
+				{Type: entities.TypeHashtag, Text: "café", Start: 6, End: 11},
+			},
+		},
+		{
+			name: "Email and numeric tag are ignored",
+			body: "mail me@example.com about #2024",
+			want: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		got := entities.Parse(tc.body)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("Test case '%s': expected %+v, got %+v", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package events is the in-process bus that domain events are delivered on.
+// Side effects such as notifications subscribe to the bus instead of being
+// written by every handler that causes them.
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	// ChirpCreated covers every new chirp, rechirps and quotes included.
+	ChirpCreated Type = "chirp.created"
+	ChirpDeleted Type = "chirp.deleted"
+	ChirpLiked   Type = "chirp.liked"
+	UserFollowed Type = "user.followed"
+	UserCreated  Type = "user.created"
+	// RelationshipChanged covers unfollows, blocks, unblocks, mutes and
+	// unmutes by ActorID of UserID. Follows are UserFollowed.
+	RelationshipChanged Type = "relationship.changed"
+	// MessageCreated is a new direct message in ConversationID.
+	MessageCreated Type = "message.created"
+	// NotificationCreated is published after a notification for UserID has
+	// been recorded or grouped into an unread one.
+	NotificationCreated Type = "notification.created"
+)
+
+// Event describes something a user did. It only carries IDs, so subscribers
+// read whatever else they need from the database.
+type Event struct {
+	// ID identifies the event, however often it is delivered. Consumers use
+	// it to handle the event once.
+	ID   uuid.UUID `json:"id"`
+	Type Type      `json:"type"`
+	// ActorID is the user who caused the event.
+	ActorID uuid.UUID `json:"actor_id"`
+	// UserID is the user the event is directed at, such as the followed user
+	// or the author of a liked chirp. It is uuid.Nil when there is none.
+	UserID         uuid.UUID `json:"user_id,omitempty"`
+	ChirpID        uuid.UUID `json:"chirp_id,omitempty"`
+	ConversationID uuid.UUID `json:"conversation_id,omitempty"`
+	MessageID      uuid.UUID `json:"message_id,omitempty"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// Handler processes one event. An event may be delivered more than once, so
+// handlers with lasting effects must recognize events they have handled.
+type Handler func(ctx context.Context, e Event) error
+
+type subscription struct {
+	name    string
+	types   map[Type]bool
+	handler Handler
+}
+
+// Bus delivers published events to subscribers asynchronously, so publishing
+// never blocks a request.
+type Bus struct {
+	mu            sync.RWMutex
+	subscriptions []subscription
+	queue         chan Event
+}
+
+func NewBus(queueSize int) *Bus {
+	return &Bus{queue: make(chan Event, queueSize)}
+}
+
+// Subscribe registers h under name for events of the given types, or for
+// every event when no types are given. The name identifies the subscriber
+// across deliveries of an event, so it must be unique on the bus and stay
+// the same between releases.
+func (b *Bus) Subscribe(name string, h Handler, types ...Type) {
+	s := subscription{name: name, handler: h}
+	if len(types) > 0 {
+		s.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscriptions = append(b.subscriptions, s)
+}
+
+// Publish queues e for delivery. Events are dropped with a log line when the
+// queue is full, and failures are only logged, so Publish is meant for events
+// that may be lost. Durable events go through the outbox, which calls
+// Dispatch.
+func (b *Bus) Publish(e Event) {
+	e = Stamp(e)
+
+	select {
+	case b.queue <- e:
+	default:
+		log.Printf("events: queue is full, dropping %s event from %s", e.Type, e.ActorID)
+	}
+}
+
+// Start runs workers goroutines that deliver queued events until ctx is done.
+func (b *Bus) Start(ctx context.Context, workers int) {
+	for range workers {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case e := <-b.queue:
+					b.deliver(ctx, e)
+				}
+			}
+		}()
+	}
+}
+
+func (b *Bus) deliver(ctx context.Context, e Event) {
+	if err := b.Dispatch(ctx, e); err != nil {
+		log.Printf("events: failed to handle %s event %s: %v", e.Type, e.ID, err)
+	}
+}
+
+// Dispatch runs the subscribers of e right away and returns their failures.
+// Every subscriber runs, even when one before it fails.
+func (b *Bus) Dispatch(ctx context.Context, e Event) error {
+	_, err := b.DispatchExcept(ctx, e, nil)
+	return err
+}
+
+// DispatchExcept is Dispatch without the subscribers named in done, which
+// have handled e already. It returns the names of the subscribers that
+// handled e this time along with the failures of the rest.
+func (b *Bus) DispatchExcept(ctx context.Context, e Event, done map[string]bool) ([]string, error) {
+	b.mu.RLock()
+	subscriptions := b.subscriptions
+	b.mu.RUnlock()
+
+	var handled []string
+	var errs []error
+	for _, s := range subscriptions {
+		if done[s.name] || (s.types != nil && !s.types[e.Type]) {
+			continue
+		}
+		if err := s.handler(ctx, e); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		handled = append(handled, s.name)
+	}
+	return handled, errors.Join(errs...)
+}
+
+// Stamp returns e with an ID and OccurredAt set, unless they are already.
+func Stamp(e Event) Event {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	return e
+}
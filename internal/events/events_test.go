@@ -0,0 +1,93 @@
+package events_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/events"
+)
+
+func TestBusDeliversSubscribedTypes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	bus := events.NewBus(10)
+	likes := make(chan events.Event, 10)
+	all := make(chan events.Event, 10)
+	bus.Subscribe("likes", func(_ context.Context, e events.Event) error { likes <- e; return nil }, events.ChirpLiked)
+	bus.Subscribe("all", func(_ context.Context, e events.Event) error { all <- e; return nil })
+	bus.Start(ctx, 1)
+
+	actor := uuid.New()
+	bus.Publish(events.Event{Type: events.UserFollowed, ActorID: actor})
+	bus.Publish(events.Event{Type: events.ChirpLiked, ActorID: actor})
+
+	for _, want := range []events.Type{events.UserFollowed, events.ChirpLiked} {
+		select {
+		case e := <-all:
+			if e.Type != want || e.ActorID != actor || e.OccurredAt.IsZero() || e.ID == uuid.Nil {
+				t.Fatalf("Expected %s event from %s with an ID and a timestamp, got %+v", want, actor, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s event", want)
+		}
+	}
+
+	select {
+	case e := <-likes:
+		if e.Type != events.ChirpLiked {
+			t.Fatalf("Expected only chirp.liked events, got %s", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for chirp.liked event")
+	}
+	if len(likes) != 0 {
+		t.Fatalf("Expected the follow event to be filtered out, got %d more events", len(likes))
+	}
+}
+
+func TestDispatchRunsEverySubscriber(t *testing.T) {
+	bus := events.NewBus(10)
+	failure := errors.New("unavailable")
+	var ran []string
+	bus.Subscribe("first", func(context.Context, events.Event) error { ran = append(ran, "first"); return failure })
+	bus.Subscribe("second", func(context.Context, events.Event) error { ran = append(ran, "second"); return nil })
+	bus.Subscribe("other", func(context.Context, events.Event) error { ran = append(ran, "other"); return nil }, events.ChirpLiked)
+
+	err := bus.Dispatch(context.Background(), events.Event{Type: events.UserFollowed})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the failure to be returned, got %v", err)
+	}
+	if len(ran) != 2 || ran[0] != "first" || ran[1] != "second" {
+		t.Fatalf("Expected both subscribers of the event to run, got %v", ran)
+	}
+}
+
+func TestDispatchExceptSkipsDoneSubscribers(t *testing.T) {
+	bus := events.NewBus(10)
+	failure := errors.New("unavailable")
+	var ran []string
+	for _, name := range []string{"done", "failing", "handling"} {
+		bus.Subscribe(name, func(context.Context, events.Event) error {
+			ran = append(ran, name)
+			if name == "failing" {
+				return failure
+			}
+			return nil
+		})
+	}
+
+	handled, err := bus.DispatchExcept(context.Background(), events.Event{Type: events.UserFollowed}, map[string]bool{"done": true})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Expected the failure to be returned, got %v", err)
+	}
+	if len(ran) != 2 || ran[0] != "failing" || ran[1] != "handling" {
+		t.Fatalf("Expected the subscribers not done to run, got %v", ran)
+	}
+	if len(handled) != 1 || handled[0] != "handling" {
+		t.Fatalf("Expected only the succeeding subscriber to be reported, got %v", handled)
+	}
+}
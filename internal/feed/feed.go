@@ -0,0 +1,189 @@
+// Package feed renders lists of chirps as Atom and RSS 2.0 documents for feed
+// readers.
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+// Feed is a syndication feed, independent of its format.
+type Feed struct {
+	// ID identifies the feed for good, such as a urn:uuid: URI. Atom only.
+	ID       string
+	Title    string
+	Subtitle string
+	// Link is the page the feed describes and Self the URL of the feed.
+	Link string
+	Self string
+	// Updated is when any entry last changed.
+	Updated time.Time
+	Entries []Entry
+}
+
+// Entry is one item of a feed.
+type Entry struct {
+	// ID must stay the same for as long as the entry exists, so that readers
+	// recognize it when it changes.
+	ID      string
+	Title   string
+	Link    string
+	Author  string
+	Content string
+	// Published is when the entry was created and Updated when it last
+	// changed.
+	Published time.Time
+	Updated   time.Time
+}
+
+const generator = "Chirpy"
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Subtitle  string      `xml:"subtitle,omitempty"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Link      atomLink   `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Author    atomPerson `xml:"author"`
+	Content   atomText   `xml:"content"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// Atom renders f as an Atom 1.0 document (RFC 4287).
+func (f Feed) Atom() ([]byte, error) {
+	doc := atomFeed{
+		ID:       f.ID,
+		Title:    f.Title,
+		Subtitle: f.Subtitle,
+		Updated:  atomTime(f.Updated),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: f.Self},
+			{Rel: "alternate", Href: f.Link},
+		},
+		Generator: generator,
+	}
+	for _, e := range f.Entries {
+		doc.Entries = append(doc.Entries, atomEntry{
+			ID:        e.ID,
+			Title:     e.Title,
+			Link:      atomLink{Rel: "alternate", Href: e.Link},
+			Published: atomTime(e.Published),
+			Updated:   atomTime(e.Updated),
+			Author:    atomPerson{Name: e.Author},
+			Content:   atomText{Type: "text", Body: e.Content},
+		})
+	}
+	return marshal(doc)
+}
+
+func atomTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+type rssDoc struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	Creator     string  `xml:"dc:creator"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS renders f as an RSS 2.0 document. RSS has no update time for items, so
+// edits only show in lastBuildDate; item guids are the entry IDs.
+func (f Feed) RSS() ([]byte, error) {
+	description := f.Subtitle
+	if description == "" {
+		description = f.Title
+	}
+
+	doc := rssDoc{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   description,
+			Self:          atomLink{Rel: "self", Type: "application/rss+xml", Href: f.Self},
+			LastBuildDate: rssTime(f.Updated),
+			Generator:     generator,
+		},
+	}
+	for _, e := range f.Entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.Link,
+			Description: e.Content,
+			Creator:     e.Author,
+			GUID:        rssGUID{IsPermaLink: false, Value: e.ID},
+			PubDate:     rssTime(e.Published),
+		})
+	}
+	return marshal(doc)
+}
+
+func rssTime(t time.Time) string {
+	return t.UTC().Format(time.RFC1123Z)
+}
+
+func marshal(doc any) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,121 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/feed"
+)
+
+func testFeed() feed.Feed {
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	return feed.Feed{
+		ID:       "urn:uuid:6f1c1f9e-2a7b-4a53-9d51-1f0a4b6f9c10",
+		Title:    "Ada (@ada) on Chirpy",
+		Subtitle: "Chirps by @ada",
+		Link:     "https://chirpy.example/users/ada",
+		Self:     "https://chirpy.example/users/ada/feed.atom",
+		Updated:  published.Add(time.Hour),
+		Entries: []feed.Entry{{
+			ID:        "urn:uuid:0d8e4b3a-6a09-4d4e-b7b4-9a3c2f6e1b22",
+			Title:     "Fish & <chips>",
+			Link:      "https://chirpy.example/api/chirps/0d8e4b3a-6a09-4d4e-b7b4-9a3c2f6e1b22",
+			Author:    "ada",
+			Content:   "Fish & <chips> #dinner",
+			Published: published,
+			Updated:   published.Add(time.Hour),
+		}},
+	}
+}
+
+func TestAtom(t *testing.T) {
+	body, err := testFeed().Atom()
+	if err != nil {
+		t.Fatalf("Atom: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			ID        string `xml:"id"`
+			Published string `xml:"published"`
+			Updated   string `xml:"updated"`
+			Content   string `xml:"content"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, body)
+	}
+
+	if doc.ID != "urn:uuid:6f1c1f9e-2a7b-4a53-9d51-1f0a4b6f9c10" {
+		t.Errorf("expected the feed ID, got %q", doc.ID)
+	}
+	if doc.Updated != "2024-03-01T13:00:00Z" {
+		t.Errorf("expected the feed to be updated at 13:00, got %q", doc.Updated)
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(doc.Entries))
+	}
+	entry := doc.Entries[0]
+	if entry.ID != "urn:uuid:0d8e4b3a-6a09-4d4e-b7b4-9a3c2f6e1b22" {
+		t.Errorf("expected the entry ID, got %q", entry.ID)
+	}
+	if entry.Published != "2024-03-01T12:00:00Z" || entry.Updated != "2024-03-01T13:00:00Z" {
+		t.Errorf("expected published 12:00 and updated 13:00, got %q and %q", entry.Published, entry.Updated)
+	}
+	if entry.Content != "Fish & <chips> #dinner" {
+		t.Errorf("expected the content to round-trip, got %q", entry.Content)
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := testFeed().RSS()
+	if err != nil {
+		t.Fatalf("RSS: %v", err)
+	}
+	if !strings.Contains(string(body), `<atom:link rel="self" type="application/rss+xml" href="https://chirpy.example/users/ada/feed.atom">`) {
+		t.Errorf("expected a self link:\n%s", body)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				GUID struct {
+					IsPermaLink string `xml:"isPermaLink,attr"`
+					Value       string `xml:",chardata"`
+				} `xml:"guid"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, body)
+	}
+
+	if doc.Version != "2.0" {
+		t.Errorf("expected version 2.0, got %q", doc.Version)
+	}
+	if doc.Channel.LastBuildDate != "Fri, 01 Mar 2024 13:00:00 +0000" {
+		t.Errorf("expected lastBuildDate at 13:00, got %q", doc.Channel.LastBuildDate)
+	}
+	if len(doc.Channel.Items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(doc.Channel.Items))
+	}
+	item := doc.Channel.Items[0]
+	if item.GUID.Value != "urn:uuid:0d8e4b3a-6a09-4d4e-b7b4-9a3c2f6e1b22" || item.GUID.IsPermaLink != "false" {
+		t.Errorf("expected a guid that is not a permalink, got %q (isPermaLink=%q)", item.GUID.Value, item.GUID.IsPermaLink)
+	}
+	if item.PubDate != "Fri, 01 Mar 2024 12:00:00 +0000" {
+		t.Errorf("expected pubDate at 12:00, got %q", item.PubDate)
+	}
+	if item.Description != "Fish & <chips> #dinner" {
+		t.Errorf("expected the description to round-trip, got %q", item.Description)
+	}
+}
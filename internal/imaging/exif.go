@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"image"
+	"image/draw"
+)
+
+// orientationTag is the EXIF tag holding how a camera was rotated.
+const orientationTag = 0x0112
+
+// exifOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when it
+// has none. Only the first IFD is searched, which is where cameras put it.
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan or end of image: no more metadata follows.
+			return 1
+		}
+
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:]))
+		if end > len(data) {
+			return 1
+		}
+		if seg := data[i+4 : end]; marker == 0xE1 && len(seg) > 6 && string(seg[:6]) == "Exif\x00\x00" {
+			return tiffOrientation(seg[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 0 || ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for e := ifd + 2; e+12 <= len(tiff) && n > 0; e, n = e+12, n-1 {
+		if order.Uint16(tiff[e:]) != orientationTag {
+			continue
+		}
+		if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+			return o
+		}
+		return 1
+	}
+	return 1
+}
+
+// orient rotates and flips img so that it displays upright for the given EXIF
+// orientation. Re-encoding drops the EXIF data, so this has to happen first.
+func orient(img image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	// The source is read a row at a time rather than copied whole.
+	row := image.NewNRGBA(image.Rect(0, 0, w, 1))
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for sy := range h {
+		draw.Draw(row, row.Rect, img, image.Pt(b.Min.X, b.Min.Y+sy), draw.Src)
+		for sx := range w {
+			var x, y int
+			switch orientation {
+			case 2:
+				x, y = w-1-sx, sy
+			case 3:
+				x, y = w-1-sx, h-1-sy
+			case 4:
+				x, y = sx, h-1-sy
+			case 5:
+				x, y = sy, sx
+			case 6:
+				x, y = h-1-sy, sx
+			case 7:
+				x, y = h-1-sy, w-1-sx
+			case 8:
+				x, y = sy, w-1-sx
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:][:4], row.Pix[sx*4:][:4])
+		}
+	}
+	return dst
+}
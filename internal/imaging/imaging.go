@@ -0,0 +1,129 @@
+// Package imaging decodes uploaded images and produces resized copies of them.
+// Re-encoding is also what strips metadata such as EXIF from uploads, since
+// only pixels survive a decode; the EXIF orientation is applied to the pixels
+// first so photos keep displaying upright.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+// MaxPixels bounds the decoded size of an upload, so that a small file cannot
+// expand into an enormous bitmap.
+const MaxPixels = 25_000_000
+
+var ErrUnsupportedType = errors.New("unsupported image type")
+
+// ContentTypes are the image types accepted for upload.
+var ContentTypes = map[string]struct{}{
+	"image/jpeg": {},
+	"image/png":  {},
+	"image/gif":  {},
+}
+
+// Decode sniffs data, checks that it is an accepted image type of a sane size
+// and decodes it, rotating JPEGs upright. The returned content type is the
+// sniffed one.
+func Decode(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := ContentTypes[contentType]; !ok {
+		return nil, "", ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading image header: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, "", fmt.Errorf("image dimensions %dx%d are not allowed", cfg.Width, cfg.Height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding image: %w", err)
+	}
+	if contentType == "image/jpeg" {
+		img = orient(img, exifOrientation(data))
+	}
+	return img, contentType, nil
+}
+
+// Encode writes img in the format of contentType. GIFs are written as PNG, so
+// the returned content type is the one actually used.
+func Encode(w io.Writer, img image.Image, contentType string) (string, error) {
+	if contentType == "image/jpeg" {
+		return contentType, jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	}
+	return "image/png", png.Encode(w, img)
+}
+
+// Square crops the largest centered square out of img and scales it to
+// size x size.
+func Square(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	return resample(img, image.Rect(x0, y0, x0+side, y0+side), size, size)
+}
+
+// Fit scales img down, keeping its aspect ratio, so that neither side exceeds
+// maxSide. Images that already fit are returned as an unscaled copy.
+func Fit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSide || h > maxSide {
+		if w >= h {
+			w, h = maxSide, max(1, h*maxSide/w)
+		} else {
+			w, h = max(1, w*maxSide/h), maxSide
+		}
+	}
+	return resample(img, b, w, h)
+}
+
+// resample scales the src region of img to w x h. Each destination pixel is
+// the average of the source pixels it covers, which keeps downscaled images
+// smooth; when upscaling it degrades to nearest neighbour.
+func resample(img image.Image, src image.Rectangle, w, h int) *image.NRGBA {
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sw, sh := src.Dx(), src.Dy()
+
+	// Only the source rows covered by one destination row are converted at
+	// a time, so the source is never copied whole.
+	strip := image.NewNRGBA(image.Rect(0, 0, sw, (sh+h-1)/h))
+	for y := range h {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		draw.Draw(strip, image.Rect(0, 0, sw, y1-y0), img, image.Pt(src.Min.X, src.Min.Y+y0), draw.Src)
+
+		for x := range w {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+
+			var r, g, b, a, n uint64
+			for sy := range y1 - y0 {
+				row := strip.Pix[sy*strip.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r += uint64(p[0])
+					g += uint64(p[1])
+					b += uint64(p[2])
+					a += uint64(p[3])
+					n++
+				}
+			}
+
+			o := out.Pix[y*out.Stride+x*4:]
+			o[0], o[1], o[2], o[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return out
+}
@@ -0,0 +1,80 @@
+package imaging_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/imaging"
+)
+
+func TestSquareAndFit(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 300, 100))
+	for y := range 100 {
+		for x := range 300 {
+			src.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	square := imaging.Square(src, 40)
+	if got := square.Bounds().Size(); got != image.Pt(40, 40) {
+		t.Fatalf("Expected 40x40 square, got %v", got)
+	}
+	if got := color.NRGBAModel.Convert(square.At(20, 20)); got != (color.NRGBA{R: 200, G: 100, B: 50, A: 255}) {
+		t.Fatalf("Expected a uniform image to keep its color, got %v", got)
+	}
+
+	fitted := imaging.Fit(src, 150)
+	if got := fitted.Bounds().Size(); got != image.Pt(150, 50) {
+		t.Fatalf("Expected 150x50 after fitting, got %v", got)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	if _, contentType, err := imaging.Decode(buf.Bytes()); err != nil || contentType != "image/png" {
+		t.Fatalf("Expected PNG to decode, got %q, %v", contentType, err)
+	}
+
+	if _, _, err := imaging.Decode([]byte("<html>not an image</html>")); !errors.Is(err, imaging.ErrUnsupportedType) {
+		t.Fatalf("Expected ErrUnsupportedType, got %v", err)
+	}
+}
+
+func TestDecodeAppliesEXIFOrientation(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 30, 10))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("Failed to encode test image: %v", err)
+	}
+
+	// An APP1 segment holding a big-endian TIFF header and a single IFD entry:
+	// orientation 6, "rotate 90 degrees clockwise to display".
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8,
+		0, 1,
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, 6, 0, 0,
+		0, 0, 0, 0,
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := append([]byte{0xFF, 0xE1, 0, byte(len(payload) + 2)}, payload...)
+
+	data := append([]byte{0xFF, 0xD8}, app1...)
+	data = append(data, buf.Bytes()[2:]...)
+
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if got := img.Bounds().Size(); got != image.Pt(10, 30) {
+		t.Fatalf("Expected the rotated image to be 10x30, got %v", got)
+	}
+}
@@ -0,0 +1,262 @@
+// Package jobs is a durable background job queue kept in the jobs table.
+// Jobs are enqueued with the Queries of the surrounding transaction, so they
+// exist only if the transaction commits, and run by workers on any instance.
+// Failed jobs are retried with exponential backoff until their attempts run
+// out, after which they are kept as dead for inspection.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/retry"
+)
+
+// Job statuses, as stored in jobs.status.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+const (
+	// DefaultMaxAttempts applies when Options.MaxAttempts is zero.
+	DefaultMaxAttempts = 10
+
+	// lease is how long a worker holds a job. The handler gets a little less,
+	// so that it has given up before the job can be claimed again.
+	lease          = 5 * time.Minute
+	handlerTimeout = 4 * time.Minute
+
+	pollInterval = 2 * time.Second
+	// retention is how long succeeded jobs are kept.
+	retention      = 7 * 24 * time.Hour
+	pruneInterval  = time.Hour
+	maxErrorLength = 1000
+)
+
+// backoff spaces the attempts of a job: 10 seconds, doubling up to an hour.
+var backoff = retry.Backoff{First: 10 * time.Second, Max: time.Hour}
+
+// ErrDuplicate is returned by Enqueue when a pending or running job has the
+// same unique key.
+var ErrDuplicate = errors.New("jobs: a job with the same unique key is queued")
+
+// Job is a claimed job as passed to its handler.
+type Job struct {
+	ID      uuid.UUID
+	Kind    string
+	Payload json.RawMessage
+	// Attempt counts from 1.
+	Attempt int32
+}
+
+// Handler runs one job. A returned error fails the attempt, and the job is
+// retried unless the error is Permanent. Handlers must be idempotent: a job
+// whose worker dies part way runs again.
+type Handler func(ctx context.Context, job Job) error
+
+// Options adjust how a job is enqueued.
+type Options struct {
+	// UniqueKey skips the job while another pending or running job has the
+	// same key.
+	UniqueKey string
+	// RunAt delays the job until then.
+	RunAt time.Time
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int32
+}
+
+// Store is the part of database.Queries the queue uses.
+type Store interface {
+	EnqueueJob(ctx context.Context, arg database.EnqueueJobParams) (uuid.UUID, error)
+	ClaimJob(ctx context.Context, arg database.ClaimJobParams) (database.Job, error)
+	CompleteJob(ctx context.Context, arg database.CompleteJobParams) (int64, error)
+	RetryJob(ctx context.Context, arg database.RetryJobParams) (int64, error)
+	KillJob(ctx context.Context, arg database.KillJobParams) (int64, error)
+	DeleteFinishedJobs(ctx context.Context, retentionSeconds float64) (int64, error)
+}
+
+// Enqueue stores a job of kind with payload encoded as JSON. Pass the Queries
+// of a transaction to enqueue the job only if it commits.
+func Enqueue(ctx context.Context, db Store, kind string, payload any, opts Options) (uuid.UUID, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RunAt.IsZero() {
+		opts.RunAt = time.Now()
+	}
+
+	id, err := db.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        kind,
+		Payload:     string(body),
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt.UTC(),
+		UniqueKey:   sql.NullString{String: opts.UniqueKey, Valid: opts.UniqueKey != ""},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, ErrDuplicate
+	}
+	return id, err
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying cannot fix, such as a malformed
+// payload. The job is dead right away.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// Queue runs the jobs of the kinds registered on it.
+type Queue struct {
+	db       Store
+	handlers map[string]Handler
+}
+
+func NewQueue(db Store) *Queue {
+	return &Queue{db: db, handlers: make(map[string]Handler)}
+}
+
+// Register sets the handler for jobs of kind. It must be called before Start.
+func (q *Queue) Register(kind string, h Handler) {
+	q.handlers[kind] = h
+}
+
+// Start runs workers goroutines that run due jobs until ctx is done, and
+// prunes old succeeded jobs. Only registered kinds are claimed, so instances
+// may run different sets of jobs.
+func (q *Queue) Start(ctx context.Context, workers int) {
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	for range workers {
+		go retry.Poll(ctx, pollInterval, nil, func(ctx context.Context) bool {
+			return q.work(ctx, kinds)
+		})
+	}
+
+	go q.prune(ctx)
+}
+
+// work runs one due job and reports whether there was one.
+func (q *Queue) work(ctx context.Context, kinds []string) bool {
+	if len(kinds) == 0 {
+		return false
+	}
+
+	claimed, err := q.db.ClaimJob(ctx, database.ClaimJobParams{
+		LeaseSeconds: lease.Seconds(),
+		Kinds:        kinds,
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("jobs: failed to claim a job: %v", err)
+		}
+		return false
+	}
+
+	job := Job{
+		ID:      claimed.ID,
+		Kind:    claimed.Kind,
+		Payload: json.RawMessage(claimed.Payload),
+		Attempt: claimed.Attempts,
+	}
+
+	var runErr error
+	if claimed.Attempts > claimed.MaxAttempts {
+		// Only a job whose lease expired on its last attempt gets here.
+		runErr = Permanent(errors.New("lease expired on the last attempt"))
+	} else {
+		runErr = q.run(ctx, job)
+	}
+
+	if err := q.record(ctx, claimed, runErr); err != nil {
+		log.Printf("jobs: failed to record the outcome of %s job %s: %v", job.Kind, job.ID, err)
+	}
+	return true
+}
+
+// run calls the handler of job, turning panics into permanent errors.
+func (q *Queue) run(ctx context.Context, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, handlerTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return q.handlers[job.Kind](ctx, job)
+}
+
+// record stores the outcome of an attempt. A worker that lost its lease
+// changes nothing, since the job has been claimed again.
+func (q *Queue) record(ctx context.Context, claimed database.Job, runErr error) error {
+	if runErr == nil {
+		_, err := q.db.CompleteJob(ctx, database.CompleteJobParams{ID: claimed.ID, Attempts: claimed.Attempts})
+		return err
+	}
+
+	msg := runErr.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	lastError := sql.NullString{String: msg, Valid: true}
+
+	var permanent *permanentError
+	if errors.As(runErr, &permanent) || claimed.Attempts >= claimed.MaxAttempts {
+		log.Printf("jobs: %s job %s is dead after %d attempts: %v", claimed.Kind, claimed.ID, claimed.Attempts, runErr)
+		_, err := q.db.KillJob(ctx, database.KillJobParams{
+			LastError: lastError,
+			ID:        claimed.ID,
+			Attempts:  claimed.Attempts,
+		})
+		return err
+	}
+
+	_, err := q.db.RetryJob(ctx, database.RetryJobParams{
+		RetryInSeconds: backoff.Jittered(int(claimed.Attempts)).Seconds(),
+		LastError:      lastError,
+		ID:             claimed.ID,
+		Attempts:       claimed.Attempts,
+	})
+	return err
+}
+
+// prune deletes succeeded jobs past retention. Every instance prunes; the
+// deletes are idempotent.
+func (q *Queue) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := q.db.DeleteFinishedJobs(ctx, retention.Seconds()); err != nil {
+				log.Printf("jobs: failed to prune jobs: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package jobs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+)
+
+func TestPermanentWrapsError(t *testing.T) {
+	cause := errors.New("malformed payload")
+	err := jobs.Permanent(cause)
+	if !errors.Is(err, cause) {
+		t.Fatal("Expected the permanent error to wrap its cause")
+	}
+	if err.Error() != cause.Error() {
+		t.Fatalf("Expected %q, got %q", cause.Error(), err.Error())
+	}
+}
+
+// fakeStore hands out the jobs in claimable and records what the queue does
+// with them.
+type fakeStore struct {
+	mu        sync.Mutex
+	claimable []database.Job
+	enqueued  []database.EnqueueJobParams
+	duplicate bool
+
+	outcomes chan outcome
+}
+
+// outcome is a recorded attempt: "completed", "retried" or "killed".
+type outcome struct {
+	status  string
+	attempt int32
+	retryIn float64
+	err     string
+}
+
+func newFakeStore(claimable ...database.Job) *fakeStore {
+	return &fakeStore{claimable: claimable, outcomes: make(chan outcome, 10)}
+}
+
+func (s *fakeStore) EnqueueJob(_ context.Context, arg database.EnqueueJobParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.duplicate {
+		return uuid.Nil, sql.ErrNoRows
+	}
+	s.enqueued = append(s.enqueued, arg)
+	return uuid.New(), nil
+}
+
+func (s *fakeStore) ClaimJob(_ context.Context, arg database.ClaimJobParams) (database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.claimable) == 0 {
+		return database.Job{}, sql.ErrNoRows
+	}
+	job := s.claimable[0]
+	s.claimable = s.claimable[1:]
+	return job, nil
+}
+
+func (s *fakeStore) CompleteJob(_ context.Context, arg database.CompleteJobParams) (int64, error) {
+	s.outcomes <- outcome{status: "completed", attempt: arg.Attempts}
+	return 1, nil
+}
+
+func (s *fakeStore) RetryJob(_ context.Context, arg database.RetryJobParams) (int64, error) {
+	s.outcomes <- outcome{status: "retried", attempt: arg.Attempts, retryIn: arg.RetryInSeconds, err: arg.LastError.String}
+	return 1, nil
+}
+
+func (s *fakeStore) KillJob(_ context.Context, arg database.KillJobParams) (int64, error) {
+	s.outcomes <- outcome{status: "killed", attempt: arg.Attempts, err: arg.LastError.String}
+	return 1, nil
+}
+
+func (s *fakeStore) DeleteFinishedJobs(context.Context, float64) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) next(t *testing.T) outcome {
+	t.Helper()
+	select {
+	case o := <-s.outcomes:
+		return o
+	case <-time.After(time.Second):
+		t.Fatal("Expected the attempt to be recorded")
+		return outcome{}
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	store := newFakeStore()
+
+	if _, err := jobs.Enqueue(ctx, store, "test", map[string]int{"n": 1}, jobs.Options{UniqueKey: "k"}); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	got := store.enqueued[0]
+	if got.Payload != `{"n":1}` || got.MaxAttempts != jobs.DefaultMaxAttempts || got.UniqueKey.String != "k" || got.RunAt.IsZero() {
+		t.Fatalf("Unexpected job %+v", got)
+	}
+
+	store.duplicate = true
+	if _, err := jobs.Enqueue(ctx, store, "test", nil, jobs.Options{UniqueKey: "k"}); !errors.Is(err, jobs.ErrDuplicate) {
+		t.Fatalf("Expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestQueueRecordsOutcomes(t *testing.T) {
+	tests := []struct {
+		name        string
+		attempts    int32
+		maxAttempts int32
+		handler     jobs.Handler
+		want        string
+	}{
+		{"success", 1, 3, func(context.Context, jobs.Job) error { return nil }, "completed"},
+		{"retried failure", 1, 3, func(context.Context, jobs.Job) error { return errors.New("timeout") }, "retried"},
+		{"last attempt", 3, 3, func(context.Context, jobs.Job) error { return errors.New("timeout") }, "killed"},
+		{"permanent failure", 1, 3, func(context.Context, jobs.Job) error { return jobs.Permanent(errors.New("bad payload")) }, "killed"},
+		{"panic", 1, 3, func(context.Context, jobs.Job) error { panic("boom") }, "killed"},
+		{"lease expired on the last attempt", 4, 3, func(context.Context, jobs.Job) error {
+			panic("a job past its attempts must not run")
+		}, "killed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			store := newFakeStore(database.Job{
+				ID:          uuid.New(),
+				Kind:        "test",
+				Payload:     "{}",
+				Attempts:    tt.attempts,
+				MaxAttempts: tt.maxAttempts,
+			})
+			queue := jobs.NewQueue(store)
+			queue.Register("test", tt.handler)
+			queue.Start(ctx, 1)
+
+			got := store.next(t)
+			if got.status != tt.want || got.attempt != tt.attempts {
+				t.Fatalf("Expected attempt %d to be %s, got %+v", tt.attempts, tt.want, got)
+			}
+			if got.status == "retried" && (got.retryIn < 10 || got.retryIn > 12) {
+				t.Fatalf("Expected a retry in 10 to 12 seconds, got %v", got.retryIn)
+			}
+			if got.status != "completed" && got.err == "" {
+				t.Fatal("Expected the error to be recorded")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package jobs_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the migrated database at TEST_DATABASE_URL and
+// returns a job kind of the test's own, whose jobs are deleted afterwards.
+func openTestDB(t *testing.T) (*sql.DB, *database.Queries, string) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	kind := "test." + uuid.NewString()
+	t.Cleanup(func() {
+		if _, err := conn.Exec("DELETE FROM jobs WHERE kind = $1", kind); err != nil {
+			t.Errorf("Failed to delete the test jobs: %v", err)
+		}
+		conn.Close()
+	})
+	return conn, database.New(conn), kind
+}
+
+func TestClaimSkipsLockedJobs(t *testing.T) {
+	conn, db, kind := openTestDB(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for range 2 {
+		if _, err := jobs.Enqueue(ctx, db, kind, nil, jobs.Options{}); err != nil {
+			t.Fatalf("Enqueue failed: %v", err)
+		}
+	}
+	claim := database.ClaimJobParams{LeaseSeconds: 60, Kinds: []string{kind}}
+
+	// A worker that has claimed a job but not committed yet.
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	defer tx.Rollback()
+	first, err := db.WithTx(tx).ClaimJob(ctx, claim)
+	if err != nil {
+		t.Fatalf("First claim failed: %v", err)
+	}
+
+	second, err := db.ClaimJob(ctx, claim)
+	if err != nil {
+		t.Fatalf("Expected the second worker to skip the locked job, got %v", err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("Expected the two workers to claim different jobs")
+	}
+	if _, err := db.ClaimJob(ctx, claim); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected no claimable job while the rest are held, got %v", err)
+	}
+}
+
+func TestEnqueueUniqueKey(t *testing.T) {
+	_, db, kind := openTestDB(t)
+	ctx := context.Background()
+	opts := jobs.Options{UniqueKey: kind + ":key"}
+
+	if _, err := jobs.Enqueue(ctx, db, kind, nil, opts); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if _, err := jobs.Enqueue(ctx, db, kind, nil, opts); !errors.Is(err, jobs.ErrDuplicate) {
+		t.Fatalf("Expected ErrDuplicate while the job is pending, got %v", err)
+	}
+
+	claimed, err := db.ClaimJob(ctx, database.ClaimJobParams{LeaseSeconds: 60, Kinds: []string{kind}})
+	if err != nil {
+		t.Fatalf("Claim failed: %v", err)
+	}
+	if _, err := jobs.Enqueue(ctx, db, kind, nil, opts); !errors.Is(err, jobs.ErrDuplicate) {
+		t.Fatalf("Expected ErrDuplicate while the job is running, got %v", err)
+	}
+	if _, err := db.CompleteJob(ctx, database.CompleteJobParams{ID: claimed.ID, Attempts: claimed.Attempts}); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if _, err := jobs.Enqueue(ctx, db, kind, nil, opts); err != nil {
+		t.Fatalf("Expected the key to be free once the job finished, got %v", err)
+	}
+}
+
+func TestQueueRetriesAndKillsJobs(t *testing.T) {
+	_, db, kind := openTestDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	retried, err := jobs.Enqueue(ctx, db, kind, nil, jobs.Options{MaxAttempts: 2})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	killed, err := jobs.Enqueue(ctx, db, kind, nil, jobs.Options{MaxAttempts: 1})
+	if err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	queue := jobs.NewQueue(db)
+	queue.Register(kind, func(context.Context, jobs.Job) error { return errors.New("unavailable") })
+	queue.Start(ctx, 1)
+
+	wait := func(id uuid.UUID, done func(database.Job) bool) database.Job {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			job, err := db.GetJob(ctx, id)
+			if err != nil {
+				t.Fatalf("GetJob failed: %v", err)
+			}
+			if done(job) {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting on job %+v", job)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	job := wait(retried, func(j database.Job) bool { return j.Status == jobs.StatusPending && j.Attempts == 1 })
+	if !job.LastError.Valid || time.Until(job.RunAt) < 5*time.Second {
+		t.Fatalf("Expected the failure to be recorded and a later retry, got %+v", job)
+	}
+
+	job = wait(killed, func(j database.Job) bool { return j.Status == jobs.StatusDead })
+	if job.Attempts != 1 || job.LastError.String != "unavailable" || !job.FinishedAt.Valid {
+		t.Fatalf("Expected the job to be dead after its one attempt, got %+v", job)
+	}
+}
@@ -0,0 +1,130 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Config lists the filters of a pipeline in the order they run. It is read
+// from JSON such as:
+//
+//	{"filters": [
+//	  {"type": "wordlist", "name": "profanity", "action": "mask", "words": ["kerfuffle"]},
+//	  {"type": "regex", "name": "phone-numbers", "action": "flag", "pattern": "\\d{3}-\\d{4}"},
+//	  {"type": "links", "name": "malware", "action": "reject", "domains": ["bad.example"]}
+//	]}
+type Config struct {
+	Filters []FilterConfig `json:"filters"`
+}
+
+type FilterConfig struct {
+	// Type is "wordlist", "regex" or "links".
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Action Action `json:"action"`
+
+	Words   []string `json:"words,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+}
+
+// DefaultConfig masks the words chirps have always had masked.
+var DefaultConfig = Config{
+	Filters: []FilterConfig{{
+		Type:   "wordlist",
+		Name:   "profanity",
+		Action: Mask,
+		Words:  []string{"kerfuffle", "sharbert", "fornax"},
+	}},
+}
+
+// Build compiles cfg into filters.
+func (cfg Config) Build() ([]Filter, error) {
+	filters := make([]Filter, 0, len(cfg.Filters))
+	for i, f := range cfg.Filters {
+		name := f.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", f.Type, i)
+		}
+		if f.Action == Allow {
+			return nil, fmt.Errorf("filter %s: action is required", name)
+		}
+
+		switch f.Type {
+		case "wordlist":
+			filters = append(filters, NewWordlist(name, f.Action, f.Words))
+		case "regex":
+			re, err := regexp.Compile(f.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("filter %s: %w", name, err)
+			}
+			filters = append(filters, NewRegex(name, f.Action, re))
+		case "links":
+			filters = append(filters, NewLinks(name, f.Action, f.Domains))
+		default:
+			return nil, fmt.Errorf("filter %s: unknown type %q", name, f.Type)
+		}
+	}
+	return filters, nil
+}
+
+// LoadFile replaces the filters of p with the ones configured in the JSON file
+// at path. On error p is left unchanged.
+func (p *Pipeline) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	filters, err := cfg.Build()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	p.Replace(filters)
+	return nil
+}
+
+// WatchFile reloads the configuration at path whenever its modification time
+// changes, checking every interval until ctx is done. A file that fails to
+// load is logged and the previous filters stay in place.
+func (p *Pipeline) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	var modTime time.Time
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+
+			if err := p.LoadFile(path); err != nil {
+				log.Println("Failed to reload moderation config:", err)
+				continue
+			}
+			log.Println("Reloaded moderation config from", path)
+		}
+	}()
+}
@@ -0,0 +1,181 @@
+package moderation
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Wordlist matches whole words from a list, after folding both the list and
+// the text (see fold), so "K3rfuffle" and "kérfuffle" match "kerfuffle".
+type Wordlist struct {
+	name   string
+	action Action
+	// words holds the folded words by their loose key.
+	words map[string][]string
+}
+
+func NewWordlist(name string, action Action, words []string) *Wordlist {
+	w := &Wordlist{name: name, action: action, words: make(map[string][]string, len(words))}
+	for _, word := range words {
+		if word = fold(strings.TrimSpace(word)); word != "" {
+			key := loose(word)
+			w.words[key] = append(w.words[key], word)
+		}
+	}
+	return w
+}
+
+func (w *Wordlist) Find(text string) []Span {
+	var spans []Span
+	for _, t := range tokens(text) {
+		start, end, ok := w.match(text, t[0], t[1])
+		if !ok {
+			continue
+		}
+		spans = append(spans, Span{
+			Start: start,
+			End:   end,
+			Match: Match{Rule: w.name, Action: w.action, Text: text[start:end]},
+		})
+	}
+	return spans
+}
+
+// match checks the token text[start:end], and failing that the token without
+// the leetspeak symbols around it, so that "kerfuffle!" matches as well as
+// "$hit".
+func (w *Wordlist) match(text string, start, end int) (int, int, bool) {
+	if w.has(fold(text[start:end])) {
+		return start, end, true
+	}
+
+	for start < end {
+		r, size := utf8.DecodeRuneInString(text[start:end])
+		if !isLeetSymbol(r) {
+			break
+		}
+		start += size
+	}
+	for start < end {
+		r, size := utf8.DecodeLastRuneInString(text[start:end])
+		if !isLeetSymbol(r) {
+			break
+		}
+		end -= size
+	}
+	if start == end {
+		return 0, 0, false
+	}
+
+	return start, end, w.has(fold(text[start:end]))
+}
+
+// has reports whether the folded text is one of the words.
+func (w *Wordlist) has(folded string) bool {
+	for _, word := range w.words[loose(folded)] {
+		if foldedEqual(folded, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// tokens returns the byte spans of the words in text. Words are runs of
+// letters, digits, leetspeak symbols and invisible characters, so that
+// "f.o.o" is three words but "foo" with a zero-width space inside is one.
+func tokens(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		inWord := unicode.IsLetter(r) || unicode.IsDigit(r) || isLeetSymbol(r) || isInvisible(r)
+		switch {
+		case inWord && start < 0:
+			start = i
+		case !inWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// Regex matches a regular expression against the text as written.
+type Regex struct {
+	name   string
+	action Action
+	re     *regexp.Regexp
+}
+
+func NewRegex(name string, action Action, re *regexp.Regexp) *Regex {
+	return &Regex{name: name, action: action, re: re}
+}
+
+func (f *Regex) Find(text string) []Span {
+	var spans []Span
+	for _, loc := range f.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		spans = append(spans, Span{
+			Start: loc[0],
+			End:   loc[1],
+			Match: Match{Rule: f.name, Action: f.action, Text: text[loc[0]:loc[1]]},
+		})
+	}
+	return spans
+}
+
+// linkPattern finds links with or without a scheme, capturing the host.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://)?((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,})\b(?:[/:?#][^\s]*)?`)
+
+// Links matches links to blocked domains and their subdomains.
+type Links struct {
+	name    string
+	action  Action
+	domains map[string]struct{}
+}
+
+func NewLinks(name string, action Action, domains []string) *Links {
+	l := &Links{name: name, action: action, domains: make(map[string]struct{}, len(domains))}
+	for _, d := range domains {
+		d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), ".")
+		if d != "" {
+			l.domains[d] = struct{}{}
+		}
+	}
+	return l
+}
+
+func (l *Links) Find(text string) []Span {
+	var spans []Span
+	for _, loc := range linkPattern.FindAllStringSubmatchIndex(text, -1) {
+		if !l.blocked(strings.ToLower(text[loc[2]:loc[3]])) {
+			continue
+		}
+		spans = append(spans, Span{
+			Start: loc[0],
+			End:   loc[1],
+			Match: Match{Rule: l.name, Action: l.action, Text: text[loc[0]:loc[1]]},
+		})
+	}
+	return spans
+}
+
+// blocked reports whether host or any domain above it is blocked.
+func (l *Links) blocked(host string) bool {
+	for {
+		if _, ok := l.domains[host]; ok {
+			return true
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return false
+		}
+		host = host[i+1:]
+	}
+}
@@ -0,0 +1,143 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fold normalizes s for wordlist matching: case, accents, full-width forms,
+// look-alike letters from other scripts and leetspeak all fold to plain ASCII
+// letters, and invisible characters are dropped. "1" and "|" fold to
+// ambiguous, since leetspeak uses them for both "i" and "l".
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if isInvisible(r) {
+			continue
+		}
+
+		// Full-width ASCII variants, e.g. "ｋ".
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		r = unicode.ToLower(r)
+
+		if s, ok := foldMultiRune[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		if f, ok := foldRune[r]; ok {
+			r = f
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ambiguous is what "1" and "|" fold to. It matches both "i" and "l" in a
+// word (see foldedEqual).
+const ambiguous = '1'
+
+// loose folds "i" and "l" further into ambiguous, so that a folded word and
+// the folded text that may match it share a key.
+func loose(folded string) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'i' || r == 'l' {
+			return ambiguous
+		}
+		return r
+	}, folded)
+}
+
+// foldedEqual reports whether the folded text matches the folded word, which
+// has the same loose key, with ambiguous in text matching "i" or "l".
+func foldedEqual(text, word string) bool {
+	for i := range len(text) {
+		if text[i] != word[i] && (text[i] != ambiguous || word[i] != 'i' && word[i] != 'l') {
+			return false
+		}
+	}
+	return true
+}
+
+// isInvisible reports whether r renders as nothing: combining marks left over
+// from decomposed accents, zero-width characters and soft hyphens.
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u00AD', '\u200B', '\u200C', '\u200D', '\u2060', '\uFEFF':
+		return true
+	}
+	return unicode.Is(unicode.Mn, r)
+}
+
+// isLeetSymbol reports whether r is punctuation that leetspeak uses as a
+// letter.
+func isLeetSymbol(r rune) bool {
+	switch r {
+	case '@', '$', '!', '|', '+':
+		return true
+	}
+	return false
+}
+
+var foldMultiRune = map[rune]string{
+	'ß': "ss",
+	'æ': "ae",
+	'œ': "oe",
+}
+
+var foldRune = func() map[rune]rune {
+	m := map[rune]rune{}
+	add := func(from string, to rune) {
+		for _, r := range from {
+			m[r] = to
+		}
+	}
+
+	// Precomposed Latin letters with diacritics.
+	add("àáâãäåāăą", 'a')
+	add("çćĉċč", 'c')
+	add("ďđ", 'd')
+	add("èéêëēĕėęě", 'e')
+	add("ĝğġģ", 'g')
+	add("ĥħ", 'h')
+	add("ìíîïĩīĭįı", 'i')
+	add("ĵ", 'j')
+	add("ķ", 'k')
+	add("ĺļľŀł", 'l')
+	add("ñńņňŉ", 'n')
+	add("òóôõöøōŏő", 'o')
+	add("ŕŗř", 'r')
+	add("śŝşš", 's')
+	add("ţťŧ", 't')
+	add("ùúûüũūŭůűų", 'u')
+	add("ŵ", 'w')
+	add("ýÿŷ", 'y')
+	add("źżž", 'z')
+
+	// Cyrillic and Greek letters that look like Latin ones.
+	add("аα", 'a')
+	add("с", 'c')
+	add("еε", 'e')
+	add("іι", 'i')
+	add("ј", 'j')
+	add("кκ", 'k')
+	add("оο", 'o')
+	add("рρ", 'p')
+	add("ѕ", 's')
+	add("ν", 'v')
+	add("х", 'x')
+	add("у", 'y')
+
+	// Leetspeak.
+	add("4@", 'a')
+	add("8", 'b')
+	add("3", 'e')
+	add("9", 'g')
+	add("!", 'i')
+	add("1|", ambiguous)
+	add("0", 'o')
+	add("5$", 's')
+	add("7+", 't')
+	return m
+}()
@@ -0,0 +1,165 @@
+// Package moderation checks user-written text against an ordered pipeline of
+// filters. Each filter rule carries an action: matches are masked in the text,
+// flagged for review, or cause the whole text to be rejected.
+package moderation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// Action is what happens to text that matches a rule. Actions are ordered by
+// severity.
+type Action int
+
+const (
+	Allow Action = iota
+	Mask
+	Flag
+	Reject
+)
+
+// MaskText replaces masked spans.
+const MaskText = "****"
+
+var actionNames = map[Action]string{
+	Allow:  "allow",
+	Mask:   "mask",
+	Flag:   "flag",
+	Reject: "reject",
+}
+
+func (a Action) String() string {
+	if name, ok := actionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Action) UnmarshalText(text []byte) error {
+	for action, name := range actionNames {
+		if name == string(text) && action != Allow {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown moderation action %q", text)
+}
+
+// Match is a rule that matched.
+type Match struct {
+	// Rule is the name of the filter that matched.
+	Rule   string
+	Action Action
+	// Text is the matched text as written.
+	Text string
+}
+
+// Span is a match at bytes [Start, End) of the text a filter was given.
+type Span struct {
+	Start, End int
+	Match
+}
+
+// Filter finds rule matches in text.
+type Filter interface {
+	Find(text string) []Span
+}
+
+// Result is the outcome of moderating a text.
+type Result struct {
+	// Text is the input with every masked span replaced by MaskText.
+	Text string
+	// Action is the most severe action of all matches, Allow if none.
+	Action Action
+	// Matches lists every match in pipeline order.
+	Matches []Match
+}
+
+// Rejection returns the first match that rejected the text.
+func (r Result) Rejection() (Match, bool) {
+	for _, m := range r.Matches {
+		if m.Action == Reject {
+			return m, true
+		}
+	}
+	return Match{}, false
+}
+
+// Flags returns the matches that flag the text for review.
+func (r Result) Flags() []Match {
+	var flags []Match
+	for _, m := range r.Matches {
+		if m.Action == Flag {
+			flags = append(flags, m)
+		}
+	}
+	return flags
+}
+
+// Pipeline runs filters in order. Each filter sees the text as masked by the
+// filters before it. The filters can be replaced while the pipeline is in use.
+type Pipeline struct {
+	filters atomic.Pointer[[]Filter]
+}
+
+func New(filters ...Filter) *Pipeline {
+	p := &Pipeline{}
+	p.Replace(filters)
+	return p
+}
+
+// Replace swaps in a new set of filters.
+func (p *Pipeline) Replace(filters []Filter) {
+	p.filters.Store(&filters)
+}
+
+func (p *Pipeline) Moderate(text string) Result {
+	result := Result{Text: text}
+	for _, f := range *p.filters.Load() {
+		spans := f.Find(result.Text)
+		for _, s := range spans {
+			result.Matches = append(result.Matches, s.Match)
+			result.Action = max(result.Action, s.Action)
+		}
+		if result.Action == Reject {
+			return result
+		}
+		result.Text = mask(result.Text, spans)
+	}
+	return result
+}
+
+// mask replaces the masking spans of text, skipping any that overlap an
+// earlier one.
+func mask(text string, spans []Span) string {
+	var masked []Span
+	for _, s := range spans {
+		if s.Action == Mask {
+			masked = append(masked, s)
+		}
+	}
+	if len(masked) == 0 {
+		return text
+	}
+	sort.Slice(masked, func(i, j int) bool { return masked[i].Start < masked[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, s := range masked {
+		if s.Start < last {
+			continue
+		}
+		b.WriteString(text[last:s.Start])
+		b.WriteString(MaskText)
+		last = s.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
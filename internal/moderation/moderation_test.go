@@ -0,0 +1,120 @@
+package moderation_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/moderation"
+)
+
+func TestModerate(t *testing.T) {
+	pipeline := moderation.New(
+		moderation.NewWordlist("profanity", moderation.Mask, []string{"kerfuffle", "fornax"}),
+		moderation.NewRegex("phone", moderation.Flag, regexp.MustCompile(`\b\d{3}-\d{4}\b`)),
+		moderation.NewLinks("malware", moderation.Reject, []string{"bad.example"}),
+	)
+
+	tests := []struct {
+		name       string
+		text       string
+		wantText   string
+		wantAction moderation.Action
+	}{
+		{"clean", "hello world", "hello world", moderation.Allow},
+		{"plain word", "what a kerfuffle", "what a ****", moderation.Mask},
+		{"punctuation", "kerfuffle! fornax.", "****! ****.", moderation.Mask},
+		{"case", "KerFuffle", "****", moderation.Mask},
+		{"leetspeak", "k3rfuff1e and f0rn4x", "**** and ****", moderation.Mask},
+		{"leet symbol inside", "f0rn@x", "****", moderation.Mask},
+		{"accents", "kérfüffle", "****", moderation.Mask},
+		{"full width", "ｋｅｒｆｕｆｆｌｅ", "****", moderation.Mask},
+		{"cyrillic look-alikes", "k\u0435rfuffle", "****", moderation.Mask},
+		{"zero-width space", "ker\u200bfuffle", "****", moderation.Mask},
+		{"combining accent", "ke\u0301rfuffle", "****", moderation.Mask},
+		{"substring is fine", "kerfuffles", "kerfuffles", moderation.Allow},
+		{"regex flag", "call 555-1234", "call 555-1234", moderation.Flag},
+		{"blocked link", "see https://bad.example/x", "see https://bad.example/x", moderation.Reject},
+		{"blocked subdomain", "www.cdn.bad.example", "www.cdn.bad.example", moderation.Reject},
+		{"lookalike domain", "notbad.example.com", "notbad.example.com", moderation.Allow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pipeline.Moderate(tt.text)
+			if got.Action != tt.wantAction {
+				t.Errorf("Expected action %v, got %v (%+v)", tt.wantAction, got.Action, got.Matches)
+			}
+			if got.Action != moderation.Reject && got.Text != tt.wantText {
+				t.Errorf("Expected text %q, got %q", tt.wantText, got.Text)
+			}
+		})
+	}
+}
+
+func TestWordlistKeepsLAndIApart(t *testing.T) {
+	pipeline := moderation.New(moderation.NewWordlist("profanity", moderation.Mask, []string{"fail"}))
+
+	tests := []struct {
+		text       string
+		wantAction moderation.Action
+	}{
+		{"fall", moderation.Allow},
+		{"fałl", moderation.Allow},
+		{"fail", moderation.Mask},
+		{"fa1l", moderation.Mask},
+		{"fa!l", moderation.Mask},
+		{"fai|", moderation.Mask},
+	}
+
+	for _, tt := range tests {
+		if got := pipeline.Moderate(tt.text); got.Action != tt.wantAction {
+			t.Errorf("Expected action %v for %q, got %v (%+v)", tt.wantAction, tt.text, got.Action, got.Matches)
+		}
+	}
+}
+
+func TestMaskedTextIsNotMatchedAgain(t *testing.T) {
+	pipeline := moderation.New(
+		moderation.NewWordlist("profanity", moderation.Mask, []string{"fornax"}),
+		moderation.NewRegex("fornax", moderation.Reject, regexp.MustCompile(`fornax`)),
+	)
+
+	if got := pipeline.Moderate("fornax"); got.Action != moderation.Mask || got.Text != "****" {
+		t.Fatalf("Expected the later filter to see masked text, got %+v", got)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "moderation.json")
+	pipeline := moderation.New()
+
+	write := func(config string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	write(`{"filters": [{"type": "wordlist", "name": "words", "action": "reject", "words": ["sharbert"]}]}`)
+	if err := pipeline.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if got := pipeline.Moderate("sharbert"); got.Action != moderation.Reject {
+		t.Fatalf("Expected the loaded wordlist to reject, got %v", got.Action)
+	}
+
+	write(`{"filters": [{"type": "regex", "name": "broken", "action": "flag", "pattern": "("}]}`)
+	if err := pipeline.LoadFile(path); err == nil {
+		t.Fatal("Expected an invalid pattern to fail")
+	}
+	if got := pipeline.Moderate("sharbert"); got.Action != moderation.Reject {
+		t.Fatalf("Expected a failed load to keep the old filters, got %v", got.Action)
+	}
+
+	write(`{"filters": [{"type": "wordlist", "name": "words", "action": "bogus", "words": []}]}`)
+	if err := pipeline.LoadFile(path); err == nil {
+		t.Fatal("Expected an unknown action to fail")
+	}
+}
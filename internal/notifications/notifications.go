@@ -0,0 +1,190 @@
+// Package notifications turns events from the bus into grouped notifications
+// for the users they concern.
+package notifications
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// Notification kinds, as stored in notifications.kind.
+const (
+	KindLike    = "like"
+	KindRechirp = "rechirp"
+	KindQuote   = "quote"
+	KindMention = "mention"
+	KindFollow  = "follow"
+)
+
+// consumer identifies the recorder among the consumers of events.
+const consumer = "notifications"
+
+// Recorder stores a notification for every event that concerns another user
+// and announces it with a NotificationCreated event.
+type Recorder struct {
+	conn *sql.DB
+	db   *database.Queries
+	bus  *events.Bus
+}
+
+func NewRecorder(conn *sql.DB, db *database.Queries) *Recorder {
+	return &Recorder{conn: conn, db: db}
+}
+
+// Subscribe registers the recorder on bus.
+func (r *Recorder) Subscribe(bus *events.Bus) {
+	r.bus = bus
+	bus.Subscribe(consumer, r.handle, events.ChirpCreated, events.ChirpLiked, events.UserFollowed)
+}
+
+// handle records the notifications of e once, however often e is delivered,
+// and announces them after they are committed.
+func (r *Recorder) handle(ctx context.Context, e events.Event) error {
+	var b batch
+	err := outbox.Consume(ctx, r.conn, r.db, consumer, e, func(qtx *database.Queries) error {
+		b = batch{db: qtx}
+		switch e.Type {
+		case events.ChirpLiked:
+			return b.record(ctx, e.UserID, KindLike, e.ChirpID, e.ActorID)
+		case events.UserFollowed:
+			return b.record(ctx, e.UserID, KindFollow, uuid.Nil, e.ActorID)
+		case events.ChirpCreated:
+			return b.chirpCreated(ctx, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("notifications: %w", err)
+	}
+
+	for _, created := range b.created {
+		r.bus.Publish(created)
+	}
+	return nil
+}
+
+// batch records the notifications of one event in a transaction.
+type batch struct {
+	db      *database.Queries
+	created []events.Event
+}
+
+// chirpCreated notifies the author of a rechirped or quoted chirp and the
+// users mentioned in the new chirp, as long as they may see it.
+func (b *batch) chirpCreated(ctx context.Context, e events.Event) error {
+	chirp, err := b.db.GetChirpByID(ctx, e.ChirpID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted before its event was handled.
+			return nil
+		}
+		return err
+	}
+
+	if chirp.ReferenceID.Valid && (chirp.ReferenceKind.String == KindRechirp || chirp.ReferenceKind.String == KindQuote) {
+		original, err := b.db.GetChirpByID(ctx, chirp.ReferenceID.UUID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			if err := b.recordIfVisible(ctx, original.UserID, chirp.ReferenceKind.String, original.ID, chirp); err != nil {
+				return err
+			}
+		}
+	}
+
+	mentions, err := b.db.GetMentionsByChirpIDs(ctx, []uuid.UUID{chirp.ID})
+	if err != nil {
+		return err
+	}
+	notified := make(map[uuid.UUID]bool, len(mentions))
+	for _, m := range mentions {
+		if notified[m.UserID] {
+			continue
+		}
+		notified[m.UserID] = true
+		if err := b.recordIfVisible(ctx, m.UserID, KindMention, chirp.ID, chirp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordIfVisible records a notification about chirp for userID unless the
+// chirp's audience, a moderator, a block or a mute hides it from them, so
+// that a notification never reveals a chirp its recipient cannot open.
+func (b *batch) recordIfVisible(ctx context.Context, userID uuid.UUID, kind string, chirpID uuid.UUID, chirp database.Chirp) error {
+	visible, err := visibility.CanSee(ctx, b.db, userID, chirp)
+	if err != nil || !visible {
+		return err
+	}
+	return b.record(ctx, userID, kind, chirpID, chirp.UserID)
+}
+
+func (b *batch) record(ctx context.Context, userID uuid.UUID, kind string, chirpID, actorID uuid.UUID) error {
+	if userID == uuid.Nil || userID == actorID {
+		return nil
+	}
+
+	recorded, err := b.db.RecordNotification(ctx, database.RecordNotificationParams{
+		UserID:  userID,
+		Kind:    kind,
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+		ActorID: actorID,
+	})
+	if err != nil || recorded == 0 {
+		return err
+	}
+
+	b.created = append(b.created, events.Event{
+		Type:    events.NotificationCreated,
+		ActorID: actorID,
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	return nil
+}
+
+// Summary describes a grouped notification, such as "alice and 4 others
+// liked your chirp". names holds the most recent actors, newest first, and
+// total counts all of them.
+func Summary(kind string, names []string, total int64) string {
+	var who string
+	switch {
+	case len(names) == 0:
+		who = "Someone"
+	case total <= 1:
+		who = names[0]
+	case total == 2 && len(names) >= 2:
+		who = names[0] + " and " + names[1]
+	case total == 2:
+		who = names[0] + " and 1 other"
+	default:
+		who = fmt.Sprintf("%s and %d others", names[0], total-1)
+	}
+
+	var what string
+	switch kind {
+	case KindLike:
+		what = "liked your chirp"
+	case KindRechirp:
+		what = "rechirped your chirp"
+	case KindQuote:
+		what = "quoted your chirp"
+	case KindMention:
+		what = "mentioned you"
+	case KindFollow:
+		what = "followed you"
+	default:
+		what = kind
+	}
+	return who + " " + what
+}
@@ -0,0 +1,31 @@
+package notifications_test
+
+import (
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/notifications"
+)
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  string
+		names []string
+		total int64
+		want  string
+	}{
+		{"single actor", notifications.KindFollow, []string{"alice"}, 1, "alice followed you"},
+		{"two actors", notifications.KindLike, []string{"alice", "bob"}, 2, "alice and bob liked your chirp"},
+		{"grouped", notifications.KindLike, []string{"alice", "bob", "carol"}, 5, "alice and 4 others liked your chirp"},
+		{"deleted actors", notifications.KindRechirp, nil, 3, "Someone rechirped your chirp"},
+		{"mention", notifications.KindMention, []string{"dave"}, 1, "dave mentioned you"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := notifications.Summary(tt.kind, tt.names, tt.total); got != tt.want {
+				t.Fatalf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package outbox makes domain events as durable as the changes they describe.
+// Handlers write events to the outbox_events table in the transaction of the
+// change, and the relay dispatches them on the event bus until every
+// subscriber has handled them, retrying only the subscribers that failed.
+// Events are delivered at least once; consumers with lasting effects handle
+// them through Consume to take effect once. Events that keep failing are set
+// aside after MaxAttempts until a moderator retries them.
+package outbox
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/retry"
+)
+
+const (
+	batchSize = 50
+	// lease must outlast dispatching a batch, or its events would be claimed
+	// again while still being handled.
+	lease        = 2 * time.Minute
+	pollInterval = time.Second
+
+	maxErrorLength = 1000
+
+	// retention is how long published events are kept. Consumers remember
+	// events for longer than any event is retried.
+	retention          = 7 * 24 * time.Hour
+	processedRetention = 30 * 24 * time.Hour
+	pruneInterval      = time.Hour
+)
+
+// MaxAttempts is how often the relay tries an event before setting it aside.
+// With backoff, the attempts span about half a day.
+const MaxAttempts = 20
+
+// backoff spaces the attempts of an event: 5 seconds, doubling up to an hour.
+var backoff = retry.Backoff{First: 5 * time.Second, Max: time.Hour}
+
+// Write stores e in the outbox. Pass the Queries of the transaction that makes
+// the change e describes, so that the event exists exactly when the change
+// does, and call Relay.Wake once it commits.
+func Write(ctx context.Context, db *database.Queries, e events.Event) error {
+	e = events.Stamp(e)
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return db.CreateOutboxEvent(ctx, database.CreateOutboxEventParams{
+		EventID:   e.ID,
+		EventType: string(e.Type),
+		Payload:   string(payload),
+	})
+}
+
+// Consume runs fn in a transaction and records there that consumer has
+// handled e. Events consumer has handled already are skipped, so fn takes
+// effect once however often e is delivered.
+func Consume(ctx context.Context, conn *sql.DB, db *database.Queries, consumer string, e events.Event, fn func(qtx *database.Queries) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	qtx := db.WithTx(tx)
+	first, err := qtx.MarkEventProcessed(ctx, database.MarkEventProcessedParams{Consumer: consumer, EventID: e.ID})
+	if err != nil {
+		return err
+	}
+	if first == 0 {
+		return nil
+	}
+
+	if err := fn(qtx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Store is the part of *database.Queries the relay uses.
+type Store interface {
+	ClaimOutboxEvents(ctx context.Context, arg database.ClaimOutboxEventsParams) ([]database.OutboxEvent, error)
+	GetEventConsumers(ctx context.Context, eventID uuid.UUID) ([]string, error)
+	MarkEventProcessed(ctx context.Context, arg database.MarkEventProcessedParams) (int64, error)
+	MarkOutboxEventPublished(ctx context.Context, id int64) error
+	ScheduleOutboxRetry(ctx context.Context, arg database.ScheduleOutboxRetryParams) error
+	MarkOutboxEventFailed(ctx context.Context, arg database.MarkOutboxEventFailedParams) error
+	DeletePublishedOutboxEvents(ctx context.Context, retentionSeconds float64) (int64, error)
+	DeleteProcessedEvents(ctx context.Context, retentionSeconds float64) (int64, error)
+}
+
+// Relay dispatches outbox events on the bus. Every instance may run one;
+// each event is claimed by one of them at a time.
+type Relay struct {
+	db   Store
+	bus  *events.Bus
+	wake chan struct{}
+}
+
+func NewRelay(db Store, bus *events.Bus) *Relay {
+	return &Relay{db: db, bus: bus, wake: make(chan struct{}, 1)}
+}
+
+// Wake tells the relay that events were committed, so that it dispatches them
+// now instead of at its next poll.
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start dispatches events in the background until ctx is done, and prunes
+// old ones.
+func (r *Relay) Start(ctx context.Context) {
+	go retry.Poll(ctx, pollInterval, r.wake, r.relay)
+	go r.prune(ctx)
+}
+
+// relay dispatches one batch of due events and reports whether it was full.
+func (r *Relay) relay(ctx context.Context) bool {
+	claimed, err := r.db.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{
+		LeaseSeconds: lease.Seconds(),
+		Limit:        batchSize,
+	})
+	if err != nil {
+		log.Printf("outbox: failed to claim events: %v", err)
+		return false
+	}
+	// Dispatch in the order the events were written.
+	slices.SortFunc(claimed, func(a, b database.OutboxEvent) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	for _, row := range claimed {
+		if err := r.dispatch(ctx, row); err != nil {
+			log.Printf("outbox: failed to record the outcome of event %s: %v", row.EventID, err)
+		}
+	}
+	return len(claimed) == batchSize
+}
+
+// dispatch delivers row to the subscribers that have not handled it yet.
+// When some fail, the others are recorded as consumers of the event, so that
+// its retries only run the failed ones.
+func (r *Relay) dispatch(ctx context.Context, row database.OutboxEvent) error {
+	var e events.Event
+	if err := json.Unmarshal([]byte(row.Payload), &e); err != nil {
+		return r.fail(ctx, row, err)
+	}
+
+	// Consumers are loaded on every attempt, as a moderator's retry starts
+	// the attempts over.
+	consumers, err := r.db.GetEventConsumers(ctx, row.EventID)
+	if err != nil {
+		return err
+	}
+	done := make(map[string]bool, len(consumers))
+	for _, c := range consumers {
+		done[c] = true
+	}
+
+	handled, err := r.bus.DispatchExcept(ctx, e, done)
+	if err == nil {
+		return r.db.MarkOutboxEventPublished(ctx, row.ID)
+	}
+
+	for _, name := range handled {
+		if _, err := r.db.MarkEventProcessed(ctx, database.MarkEventProcessedParams{Consumer: name, EventID: row.EventID}); err != nil {
+			log.Printf("outbox: failed to record %s as a consumer of event %s: %v", name, row.EventID, err)
+		}
+	}
+	return r.fail(ctx, row, err)
+}
+
+// fail schedules the next attempt at row after err, or sets row aside once
+// its attempts ran out.
+func (r *Relay) fail(ctx context.Context, row database.OutboxEvent, err error) error {
+	log.Printf("outbox: attempt %d at %s event %s failed: %v", row.Attempts, row.EventType, row.EventID, err)
+	msg := err.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	lastError := sql.NullString{String: msg, Valid: true}
+
+	if row.Attempts >= MaxAttempts {
+		log.Printf("outbox: giving up on %s event %s after %d attempts", row.EventType, row.EventID, row.Attempts)
+		return r.db.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{LastError: lastError, ID: row.ID})
+	}
+	return r.db.ScheduleOutboxRetry(ctx, database.ScheduleOutboxRetryParams{
+		RetryInSeconds: backoff.Jittered(int(row.Attempts)).Seconds(),
+		LastError:      lastError,
+		ID:             row.ID,
+	})
+}
+
+// prune deletes published events and consumer records past retention. Every
+// instance prunes; the deletes are idempotent.
+func (r *Relay) prune(ctx context.Context) {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.db.DeletePublishedOutboxEvents(ctx, retention.Seconds()); err != nil {
+				log.Printf("outbox: failed to prune events: %v", err)
+			}
+			if _, err := r.db.DeleteProcessedEvents(ctx, processedRetention.Seconds()); err != nil {
+				log.Printf("outbox: failed to prune processed events: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package outbox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+)
+
+// fakeStore hands out the events in claimable and records what the relay
+// does with them.
+type fakeStore struct {
+	mu        sync.Mutex
+	claimable []database.OutboxEvent
+	consumers map[uuid.UUID][]string
+
+	outcomes chan string
+}
+
+func newFakeStore(claimable ...database.OutboxEvent) *fakeStore {
+	return &fakeStore{claimable: claimable, consumers: map[uuid.UUID][]string{}, outcomes: make(chan string, 10)}
+}
+
+func (s *fakeStore) ClaimOutboxEvents(context.Context, database.ClaimOutboxEventsParams) ([]database.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := s.claimable
+	s.claimable = nil
+	return claimed, nil
+}
+
+func (s *fakeStore) GetEventConsumers(_ context.Context, eventID uuid.UUID) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.consumers[eventID], nil
+}
+
+func (s *fakeStore) MarkEventProcessed(_ context.Context, arg database.MarkEventProcessedParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.consumers[arg.EventID] = append(s.consumers[arg.EventID], arg.Consumer)
+	return 1, nil
+}
+
+func (s *fakeStore) MarkOutboxEventPublished(context.Context, int64) error {
+	s.outcomes <- "published"
+	return nil
+}
+
+func (s *fakeStore) ScheduleOutboxRetry(context.Context, database.ScheduleOutboxRetryParams) error {
+	s.outcomes <- "retried"
+	return nil
+}
+
+func (s *fakeStore) MarkOutboxEventFailed(context.Context, database.MarkOutboxEventFailedParams) error {
+	s.outcomes <- "failed"
+	return nil
+}
+
+func (s *fakeStore) DeletePublishedOutboxEvents(context.Context, float64) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) DeleteProcessedEvents(context.Context, float64) (int64, error) {
+	return 0, nil
+}
+
+func (s *fakeStore) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case o := <-s.outcomes:
+		return o
+	case <-time.After(time.Second):
+		t.Fatal("Expected the attempt to be recorded")
+		return ""
+	}
+}
+
+func outboxEvent(t *testing.T, attempts int32) database.OutboxEvent {
+	t.Helper()
+	e := events.Stamp(events.Event{Type: events.UserFollowed, ActorID: uuid.New()})
+	payload, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("Failed to encode event: %v", err)
+	}
+	return database.OutboxEvent{
+		ID:        1,
+		EventID:   e.ID,
+		EventType: string(e.Type),
+		Payload:   string(payload),
+		Attempts:  attempts,
+	}
+}
+
+func TestRelayRecordsOutcomes(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int32
+		err      error
+		want     string
+	}{
+		{"success", 1, nil, "published"},
+		{"failure", 1, errors.New("unavailable"), "retried"},
+		{"last attempt", outbox.MaxAttempts, errors.New("unavailable"), "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			bus := events.NewBus(10)
+			bus.Subscribe("test", func(context.Context, events.Event) error { return tt.err })
+			store := newFakeStore(outboxEvent(t, tt.attempts))
+			outbox.NewRelay(store, bus).Start(ctx)
+
+			if got := store.next(t); got != tt.want {
+				t.Fatalf("Expected the event to be %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRelayRetriesOnlyFailedSubscribers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var ran []string
+	failing := errors.New("unavailable")
+	bus := events.NewBus(10)
+	for _, name := range []string{"first", "second"} {
+		bus.Subscribe(name, func(context.Context, events.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+			if name == "second" && failing != nil {
+				return failing
+			}
+			return nil
+		})
+	}
+
+	row := outboxEvent(t, 1)
+	store := newFakeStore(row)
+	relay := outbox.NewRelay(store, bus)
+	relay.Start(ctx)
+	if got := store.next(t); got != "retried" {
+		t.Fatalf("Expected the event to be retried, got %s", got)
+	}
+
+	// The retry, after the failing subscriber recovered.
+	mu.Lock()
+	failing = nil
+	mu.Unlock()
+	row.Attempts = 2
+	store.mu.Lock()
+	store.claimable = []database.OutboxEvent{row}
+	store.mu.Unlock()
+	relay.Wake()
+	if got := store.next(t); got != "published" {
+		t.Fatalf("Expected the event to be published, got %s", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 3 || ran[0] != "first" || ran[1] != "second" || ran[2] != "second" {
+		t.Fatalf("Expected the retry to run only the failed subscriber, got %v", ran)
+	}
+}
+
+func TestRelaySkipsConsumersOfRetriedEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var ran []string
+	bus := events.NewBus(10)
+	for _, name := range []string{"first", "second"} {
+		bus.Subscribe(name, func(context.Context, events.Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, name)
+			return nil
+		})
+	}
+
+	// A failed event a moderator retried, which starts its attempts over.
+	row := outboxEvent(t, 1)
+	store := newFakeStore(row)
+	store.consumers[row.EventID] = []string{"first"}
+	outbox.NewRelay(store, bus).Start(ctx)
+	if got := store.next(t); got != "published" {
+		t.Fatalf("Expected the event to be published, got %s", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(ran) != 1 || ran[0] != "second" {
+		t.Fatalf("Expected only the subscriber that had not handled the event to run, got %v", ran)
+	}
+}
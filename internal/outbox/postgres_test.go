@@ -0,0 +1,133 @@
+package outbox_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the migrated database at TEST_DATABASE_URL and
+// returns an event of the test's own, whose rows are deleted afterwards.
+func openTestDB(t *testing.T) (*sql.DB, *database.Queries, events.Event) {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("Failed to open the database: %v", err)
+	}
+	e := events.Stamp(events.Event{Type: events.UserFollowed, ActorID: uuid.New()})
+	t.Cleanup(func() {
+		for _, table := range []string{"outbox_events", "processed_events"} {
+			if _, err := conn.Exec("DELETE FROM "+table+" WHERE event_id = $1", e.ID); err != nil {
+				t.Errorf("Failed to delete the test rows: %v", err)
+			}
+		}
+		conn.Close()
+	})
+	return conn, database.New(conn), e
+}
+
+func TestConsumeRunsOnce(t *testing.T) {
+	conn, db, e := openTestDB(t)
+	ctx := context.Background()
+
+	runs := 0
+	failure := errors.New("unavailable")
+	consume := func(err error) error {
+		return outbox.Consume(ctx, conn, db, "test", e, func(*database.Queries) error {
+			runs++
+			return err
+		})
+	}
+
+	if err := consume(failure); !errors.Is(err, failure) {
+		t.Fatalf("Expected the failure to be returned, got %v", err)
+	}
+	// The failed attempt was rolled back, so the event is handled again.
+	if err := consume(nil); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if err := consume(nil); err != nil {
+		t.Fatalf("Consume failed: %v", err)
+	}
+	if runs != 2 {
+		t.Fatalf("Expected the event to be handled once after the failure, ran %d times", runs)
+	}
+
+	consumers, err := db.GetEventConsumers(ctx, e.ID)
+	if err != nil {
+		t.Fatalf("GetEventConsumers failed: %v", err)
+	}
+	if len(consumers) != 1 || consumers[0] != "test" {
+		t.Fatalf("Expected the consumer to be recorded, got %v", consumers)
+	}
+}
+
+func TestClaimLeasesEvents(t *testing.T) {
+	conn, db, e := openTestDB(t)
+	ctx := context.Background()
+
+	if err := outbox.Write(ctx, db, e); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	claim := func() (database.OutboxEvent, bool) {
+		t.Helper()
+		claimed, err := db.ClaimOutboxEvents(ctx, database.ClaimOutboxEventsParams{LeaseSeconds: 60, Limit: 1000})
+		if err != nil {
+			t.Fatalf("Claim failed: %v", err)
+		}
+		for _, row := range claimed {
+			if row.EventID == e.ID {
+				return row, true
+			}
+		}
+		return database.OutboxEvent{}, false
+	}
+
+	row, ok := claim()
+	if !ok || row.Attempts != 1 {
+		t.Fatalf("Expected the event to be claimed for its first attempt, got %+v", row)
+	}
+	if _, ok := claim(); ok {
+		t.Fatal("Expected the leased event not to be claimed again")
+	}
+
+	// The relay that claimed it stopped before recording an outcome.
+	if _, err := conn.Exec("UPDATE outbox_events SET next_attempt_at = NOW() - INTERVAL '1 second' WHERE id = $1", row.ID); err != nil {
+		t.Fatalf("Failed to expire the lease: %v", err)
+	}
+	row, ok = claim()
+	if !ok || row.Attempts != 2 {
+		t.Fatalf("Expected the event to be reclaimed for its second attempt, got %+v", row)
+	}
+
+	if err := db.MarkOutboxEventFailed(ctx, database.MarkOutboxEventFailedParams{ID: row.ID}); err != nil {
+		t.Fatalf("MarkOutboxEventFailed failed: %v", err)
+	}
+	if _, err := conn.Exec("UPDATE outbox_events SET next_attempt_at = NOW() - INTERVAL '1 second' WHERE id = $1", row.ID); err != nil {
+		t.Fatalf("Failed to expire the lease: %v", err)
+	}
+	if _, ok := claim(); ok {
+		t.Fatal("Expected the failed event not to be claimed")
+	}
+
+	if _, err := db.RetryOutboxEvent(ctx, e.ID); err != nil {
+		t.Fatalf("RetryOutboxEvent failed: %v", err)
+	}
+	row, ok = claim()
+	if !ok || row.Attempts != 1 {
+		t.Fatalf("Expected the retried event to be claimed with fresh attempts, got %+v", row)
+	}
+}
@@ -0,0 +1,171 @@
+package realtime
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/broker"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// brokerChannel carries hub messages between instances.
+const brokerChannel = "realtime"
+
+// consumer identifies the bridge among the consumers of events.
+const consumer = "realtime"
+
+// Message types published by the bridge.
+const (
+	TypeChirp        = "chirp"
+	TypeChirpDeleted = "chirp.deleted"
+	TypeNotification = "notification"
+	TypeMessage      = "message"
+	// TypeRelationship tells the connections of a user to reload who they
+	// follow, block and mute. It is not forwarded to clients.
+	TypeRelationship = "relationship"
+)
+
+// TopicPublic carries every new public chirp.
+const TopicPublic = "public"
+
+// UserTopic carries the chirps of one author, whatever their visibility.
+// Subscribers filter them per viewer.
+func UserTopic(userID uuid.UUID) string {
+	return "user:" + userID.String()
+}
+
+// NotificationTopic carries a signal whenever userID gets a notification.
+func NotificationTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+// RelationshipTopic carries a signal whenever the follows, blocks or mutes
+// that decide what userID sees change.
+func RelationshipTopic(userID uuid.UUID) string {
+	return "relationships:" + userID.String()
+}
+
+// ThreadTopic carries the rechirps and quotes of a chirp, which are the
+// replies of this API.
+func ThreadTopic(chirpID uuid.UUID) string {
+	return "thread:" + chirpID.String()
+}
+
+// ConversationTopic carries the messages of a conversation. Subscribers must
+// check membership before subscribing.
+func ConversationTopic(conversationID uuid.UUID) string {
+	return "conversation:" + conversationID.String()
+}
+
+// ChirpPayload is the payload of chirp and chirp.deleted messages. Messages
+// only carry IDs so that every subscriber renders what its viewer may see.
+type ChirpPayload struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// MessagePayload is the payload of message messages.
+type MessagePayload struct {
+	ConversationID uuid.UUID `json:"conversation_id"`
+	MessageID      uuid.UUID `json:"message_id"`
+}
+
+// Bridge publishes chirp, relationship, notification and message events from bus on the
+// hub of every instance. Events are turned into hub messages where they
+// happen and sent through b, whose subscription here publishes them on hub.
+func Bridge(bus *events.Bus, b broker.Broker, hub *Hub, db *database.Queries) {
+	b.Subscribe(brokerChannel, func(_ context.Context, bm broker.Message) {
+		if bm.Lost {
+			hub.Forget()
+			return
+		}
+
+		var msg Message
+		if err := json.Unmarshal(bm.Payload, &msg); err != nil {
+			log.Printf("realtime: invalid broker message: %v", err)
+			return
+		}
+		msg.ID = uint64(bm.ID)
+		hub.Publish(msg)
+	})
+
+	bus.Subscribe(consumer, func(ctx context.Context, e events.Event) error {
+		publish := func(topic, typ string, payload any) error {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			msg, err := json.Marshal(Message{Topic: topic, Type: typ, Payload: data})
+			if err != nil {
+				return err
+			}
+			return b.Publish(ctx, brokerChannel, msg)
+		}
+
+		if err := bridgeEvent(ctx, publish, db, e); err != nil {
+			return fmt.Errorf("realtime: %w", err)
+		}
+		return nil
+	}, events.ChirpCreated, events.ChirpDeleted, events.UserFollowed, events.RelationshipChanged,
+		events.NotificationCreated, events.MessageCreated)
+}
+
+func bridgeEvent(ctx context.Context, publish func(topic, typ string, payload any) error, db *database.Queries, e events.Event) error {
+	switch e.Type {
+	case events.ChirpCreated:
+		chirp, err := db.GetChirpByID(ctx, e.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted before its event was handled.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := ChirpPayload{ChirpID: chirp.ID}
+		if err := publish(UserTopic(chirp.UserID), TypeChirp, payload); err != nil {
+			return err
+		}
+		if chirp.ReferenceID.Valid {
+			if err := publish(ThreadTopic(chirp.ReferenceID.UUID), TypeChirp, payload); err != nil {
+				return err
+			}
+		}
+		if visibility.IsPublic(chirp) {
+			return publish(TopicPublic, TypeChirp, payload)
+		}
+
+	case events.ChirpDeleted:
+		payload := ChirpPayload{ChirpID: e.ChirpID}
+		if err := publish(UserTopic(e.ActorID), TypeChirpDeleted, payload); err != nil {
+			return err
+		}
+		return publish(TopicPublic, TypeChirpDeleted, payload)
+
+	case events.UserFollowed:
+		return publish(RelationshipTopic(e.ActorID), TypeRelationship, struct{}{})
+
+	case events.RelationshipChanged:
+		// Blocks hide the two users from each other, so both reload.
+		if err := publish(RelationshipTopic(e.ActorID), TypeRelationship, struct{}{}); err != nil {
+			return err
+		}
+		return publish(RelationshipTopic(e.UserID), TypeRelationship, struct{}{})
+
+	case events.NotificationCreated:
+		return publish(NotificationTopic(e.UserID), TypeNotification, struct{}{})
+
+	case events.MessageCreated:
+		return publish(ConversationTopic(e.ConversationID), TypeMessage, MessagePayload{
+			ConversationID: e.ConversationID,
+			MessageID:      e.MessageID,
+		})
+	}
+	return nil
+}
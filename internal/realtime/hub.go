@@ -0,0 +1,157 @@
+// Package realtime pushes live updates to connected clients. A Hub routes
+// messages to subscribers by topic and keeps a short history so that clients
+// can resume after reconnecting.
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message is one update on a topic. Its ID is the ID of the broker message
+// that carried it, which is the same on every instance, so a client can
+// resume on another instance from the last ID it saw.
+type Message struct {
+	ID      uint64          `json:"id"`
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Subscription receives the messages of a set of topics on C. A subscriber
+// that falls more than its buffer behind is dropped and C is closed; it is
+// expected to resubscribe from the last ID it saw.
+type Subscription struct {
+	C      <-chan Message
+	c      chan Message
+	topics map[string]bool
+	hub    *Hub
+	closed bool
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+type Hub struct {
+	mu     sync.Mutex
+	lastID uint64
+	// Messages up to forgotten may be missing from the history. known is
+	// false until a message arrives after the hub starts or after Forget;
+	// until then nothing is known about the messages that came before.
+	forgotten   uint64
+	known       bool
+	history     []Message
+	historySize int
+	bufferSize  int
+	subs        map[*Subscription]struct{}
+}
+
+// NewHub returns a hub that remembers the last historySize messages and gives
+// every subscriber a buffer of bufferSize messages.
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subs:        make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends msg to every subscriber of its topic.
+func (h *Hub) Publish(msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.known {
+		h.forgotten = max(h.forgotten, msg.ID-1)
+		h.known = true
+	}
+	h.lastID = max(h.lastID, msg.ID)
+	h.history = append(h.history, msg)
+	if len(h.history) > h.historySize {
+		for _, old := range h.history[:len(h.history)-h.historySize] {
+			h.forgotten = max(h.forgotten, old.ID)
+		}
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	for s := range h.subs {
+		if !s.topics[msg.Topic] {
+			continue
+		}
+		select {
+		case s.c <- msg:
+		default:
+			// Never block publishers on a slow client.
+			h.remove(s)
+		}
+	}
+}
+
+// Forget records that messages may have been lost on the way to the hub. Every
+// subscriber is dropped, so that it resubscribes and is told it missed some.
+func (h *Hub) Forget() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.known = false
+	for s := range h.subs {
+		h.remove(s)
+	}
+}
+
+// Subscribe starts receiving messages on topics. Messages after lastID that
+// are still in the history are returned for the caller to replay first;
+// complete is false when some of them have already been forgotten. A lastID
+// of 0 replays nothing.
+func (h *Hub) Subscribe(topics []string, lastID uint64) (sub *Subscription, missed []Message, complete bool) {
+	c := make(chan Message, h.bufferSize)
+	sub = &Subscription{C: c, c: c, topics: make(map[string]bool, len(topics)), hub: h}
+	for _, t := range topics {
+		sub.topics[t] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete = true
+	if lastID > 0 {
+		// An ID beyond the last message comes from before a restart or from
+		// an instance that is ahead of this one.
+		complete = h.known && lastID >= h.forgotten && lastID <= h.lastID
+		for _, msg := range h.history {
+			if msg.ID > lastID && sub.topics[msg.Topic] {
+				missed = append(missed, msg)
+			}
+		}
+	}
+
+	h.subs[sub] = struct{}{}
+	return sub, missed, complete
+}
+
+// SetTopics replaces the topics of s, such as when the accounts behind a
+// timeline change. Messages already queued on C are still delivered.
+func (s *Subscription) SetTopics(topics []string) {
+	set := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		set[t] = true
+	}
+
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.topics = set
+}
+
+// remove drops s. h.mu must be held.
+func (h *Hub) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(h.subs, s)
+	close(s.c)
+}
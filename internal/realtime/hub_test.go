@@ -0,0 +1,159 @@
+package realtime_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/realtime"
+)
+
+func TestHubRoutesByTopic(t *testing.T) {
+	hub := realtime.NewHub(10, 10)
+	sub, _, _ := hub.Subscribe([]string{"public"}, 0)
+	defer sub.Close()
+
+	hub.Publish(realtime.Message{ID: 1, Topic: "user:a", Type: "chirp"})
+	hub.Publish(realtime.Message{ID: 2, Topic: "public", Type: "chirp", Payload: json.RawMessage(`{"chirp_id":"x"}`)})
+
+	msg := <-sub.C
+	if msg.Topic != "public" || msg.ID != 2 || string(msg.Payload) != `{"chirp_id":"x"}` {
+		t.Fatalf("Expected the public message with ID 2, got %+v", msg)
+	}
+	if len(sub.C) != 0 {
+		t.Fatalf("Expected no other messages, got %d", len(sub.C))
+	}
+}
+
+func TestHubReplaysHistory(t *testing.T) {
+	hub := realtime.NewHub(3, 10)
+	for id := range uint64(5) {
+		hub.Publish(realtime.Message{ID: id + 1, Topic: "public", Type: "chirp"})
+	}
+
+	tests := []struct {
+		name         string
+		lastID       uint64
+		wantIDs      []uint64
+		wantComplete bool
+	}{
+		{"fresh", 0, nil, true},
+		{"up to date", 5, nil, true},
+		{"within history", 3, []uint64{4, 5}, true},
+		{"just before history", 2, []uint64{3, 4, 5}, true},
+		{"beyond history", 1, []uint64{3, 4, 5}, false},
+		{"from before a restart", 9, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed, complete := hub.Subscribe([]string{"public"}, tt.lastID)
+			defer sub.Close()
+
+			var ids []uint64
+			for _, msg := range missed {
+				ids = append(ids, msg.ID)
+			}
+			if len(ids) != len(tt.wantIDs) || complete != tt.wantComplete {
+				t.Fatalf("Expected %v (complete %v), got %v (complete %v)", tt.wantIDs, tt.wantComplete, ids, complete)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("Expected %v, got %v", tt.wantIDs, ids)
+				}
+			}
+		})
+	}
+}
+
+func TestHubDropsSlowSubscribers(t *testing.T) {
+	hub := realtime.NewHub(10, 2)
+	sub, _, _ := hub.Subscribe([]string{"public"}, 0)
+
+	for id := range uint64(3) {
+		hub.Publish(realtime.Message{ID: id + 1, Topic: "public", Type: "chirp"})
+	}
+
+	var received int
+	for range sub.C {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("Expected the buffered messages before the channel closed, got %d", received)
+	}
+
+	// Closing a dropped subscription must not panic.
+	sub.Close()
+}
+
+func TestSubscriptionSetTopics(t *testing.T) {
+	hub := realtime.NewHub(10, 10)
+	sub, _, _ := hub.Subscribe([]string{"user:a"}, 0)
+	defer sub.Close()
+
+	sub.SetTopics([]string{"user:a", "user:b"})
+	hub.Publish(realtime.Message{ID: 1, Topic: "user:b", Type: "chirp"})
+	sub.SetTopics([]string{"user:a"})
+	hub.Publish(realtime.Message{ID: 2, Topic: "user:b", Type: "chirp"})
+
+	msg := <-sub.C
+	if msg.Topic != "user:b" || msg.ID != 1 {
+		t.Fatalf("Expected the first user:b message, got %+v", msg)
+	}
+	if len(sub.C) != 0 {
+		t.Fatalf("Expected no messages after the topic was removed, got %d", len(sub.C))
+	}
+}
+
+func TestHubResumesAcrossIDGaps(t *testing.T) {
+	hub := realtime.NewHub(2, 10)
+	// Broker IDs are shared by every channel, so the hub sees gaps.
+	for _, id := range []uint64{10, 14, 20} {
+		hub.Publish(realtime.Message{ID: id, Topic: "public", Type: "chirp"})
+	}
+
+	tests := []struct {
+		name         string
+		lastID       uint64
+		wantComplete bool
+	}{
+		{"within history", 14, true},
+		{"last forgotten", 10, true},
+		{"before the hub started", 8, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, _, complete := hub.Subscribe([]string{"public"}, tt.lastID)
+			defer sub.Close()
+			if complete != tt.wantComplete {
+				t.Fatalf("Expected complete %v, got %v", tt.wantComplete, complete)
+			}
+		})
+	}
+}
+
+func TestHubForget(t *testing.T) {
+	hub := realtime.NewHub(10, 10)
+	hub.Publish(realtime.Message{ID: 1, Topic: "public", Type: "chirp"})
+	sub, _, _ := hub.Subscribe([]string{"public"}, 0)
+
+	hub.Forget()
+	if _, ok := <-sub.C; ok {
+		t.Fatal("Expected subscribers to be dropped")
+	}
+
+	resumed, _, complete := hub.Subscribe([]string{"public"}, 1)
+	resumed.Close()
+	if complete {
+		t.Fatal("Expected resuming before the next message to be incomplete")
+	}
+
+	hub.Publish(realtime.Message{ID: 5, Topic: "public", Type: "chirp"})
+	for lastID, want := range map[uint64]bool{1: false, 4: true} {
+		resumed, _, complete := hub.Subscribe([]string{"public"}, lastID)
+		resumed.Close()
+		if complete != want {
+			t.Fatalf("Expected complete %v resuming from %d, got %v", want, lastID, complete)
+		}
+	}
+}
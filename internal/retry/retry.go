@@ -0,0 +1,56 @@
+// Package retry holds what the queues kept in the database share: the backoff
+// between failed attempts and the loop that polls for due work.
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff is an exponential schedule: First after the first failed attempt,
+// doubling with every attempt up to Max.
+type Backoff struct {
+	First time.Duration
+	Max   time.Duration
+}
+
+// Delay returns how long to wait after the given failed attempt, counting
+// from 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.First
+	for range attempt - 1 {
+		delay *= 2
+		if delay >= b.Max {
+			return b.Max
+		}
+	}
+	return delay
+}
+
+// Jittered adds up to a fifth to Delay, so that work failing together does
+// not retry together.
+func (b Backoff) Jittered(attempt int) time.Duration {
+	delay := b.Delay(attempt)
+	return delay + rand.N(delay/5+1)
+}
+
+// Poll calls work until ctx is done. While work reports that more is due it
+// is called again right away; otherwise Poll waits for interval to pass or
+// for a signal on wake, which may be nil.
+func Poll(ctx context.Context, interval time.Duration, wake <-chan struct{}, work func(ctx context.Context) bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil && work(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package retry_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/retry"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := retry.Backoff{First: 10 * time.Second, Max: time.Hour}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{6, 320 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{1000, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := b.Delay(tt.attempt); got != tt.want {
+			t.Errorf("Delay(%d): expected %v, got %v", tt.attempt, tt.want, got)
+		}
+	}
+}
+
+func TestBackoffJittered(t *testing.T) {
+	b := retry.Backoff{First: 5 * time.Second, Max: time.Hour}
+	for range 100 {
+		if got := b.Jittered(2); got < 10*time.Second || got > 12*time.Second {
+			t.Fatalf("Expected between 10s and 12s, got %v", got)
+		}
+	}
+}
+
+func TestPollRunsWhileWorkIsDue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wake := make(chan struct{})
+	calls := make(chan int, 10)
+
+	var n int
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		retry.Poll(ctx, time.Hour, wake, func(context.Context) bool {
+			n++
+			calls <- n
+			// The first three calls find a backlog.
+			return n < 3
+		})
+	}()
+
+	for want := 1; want <= 3; want++ {
+		if got := <-calls; got != want {
+			t.Fatalf("Expected call %d, got %d", want, got)
+		}
+	}
+	select {
+	case got := <-calls:
+		t.Fatalf("Expected Poll to wait once the backlog is done, got call %d", got)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	wake <- struct{}{}
+	if got := <-calls; got != 4 {
+		t.Fatalf("Expected a call after the wake-up, got %d", got)
+	}
+
+	cancel()
+	<-done
+}
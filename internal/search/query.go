@@ -0,0 +1,53 @@
+// Package search turns user search input into Postgres full-text queries.
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptyQuery is returned when the input has no searchable terms.
+var ErrEmptyQuery = errors.New("search query has no searchable terms")
+
+// ToTSQuery converts user input into to_tsquery syntax. All terms must match.
+// "Quoted text" matches as a phrase and a trailing * makes a term a prefix
+// match. Every other tsquery operator in the input is treated as a separator,
+// so the result is always a valid query.
+func ToTSQuery(input string) (string, error) {
+	var clauses []string
+
+	for i, part := range strings.Split(input, `"`) {
+		// Odd parts sit between a pair of quotes.
+		if i%2 == 1 {
+			if words := terms(part); len(words) > 0 {
+				clauses = append(clauses, "("+strings.Join(words, " <-> ")+")")
+			}
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			prefix := strings.HasSuffix(field, "*")
+			words := terms(field)
+			if len(words) == 0 {
+				continue
+			}
+			if prefix {
+				words[len(words)-1] += ":*"
+			}
+			clauses = append(clauses, words...)
+		}
+	}
+
+	if len(clauses) == 0 {
+		return "", ErrEmptyQuery
+	}
+	return strings.Join(clauses, " & "), nil
+}
+
+// terms splits s into lowercase runs of letters and digits.
+func terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,48 @@
+package search_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/search"
+)
+
+func TestToTSQuery(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		expected  string
+		expectErr error
+	}{
+		{
+			name:     "Plain terms",
+			input:    "Hello World",
+			expected: "hello & world",
+		},
+		{
+			name:     "Phrase and prefix",
+			input:    `"go web" serv*`,
+			expected: "(go <-> web) & serv:*",
+		},
+		{
+			name:     "Operators are stripped",
+			input:    "a|b & !c:*",
+			expected: "a & b & c:*",
+		},
+		{
+			name:      "Nothing searchable",
+			input:     `"" !& *`,
+			expectErr: search.ErrEmptyQuery,
+		},
+	}
+
+	for _, tc := range testCases {
+		got, err := search.ToTSQuery(tc.input)
+		if !errors.Is(err, tc.expectErr) {
+			t.Fatalf("Test case '%s': expected error %v, got %v", tc.name, tc.expectErr, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("Test case '%s': expected %q, got %q", tc.name, tc.expected, got)
+		}
+	}
+}
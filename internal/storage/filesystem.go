@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem stores blobs as files below a root directory that is served by a
+// static file server at baseURL.
+type Filesystem struct {
+	root    string
+	baseURL string
+}
+
+func NewFilesystem(root, baseURL string) *Filesystem {
+	return &Filesystem{root: root, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (f *Filesystem) Put(_ context.Context, key string, r io.Reader, _ string) error {
+	name, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial blob.
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (f *Filesystem) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	name, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (f *Filesystem) Delete(_ context.Context, key string) error {
+	name, err := f.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *Filesystem) URL(key string) string {
+	return f.baseURL + "/" + key
+}
+
+// path maps key to a file below root, rejecting keys that would escape it.
+func (f *Filesystem) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(f.root, filepath.FromSlash(key)), nil
+}
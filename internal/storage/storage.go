@@ -0,0 +1,24 @@
+// Package storage stores uploaded files as blobs addressed by key.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Storage is a blob store. Keys are slash-separated paths such as
+// "avatars/<user id>/<name>.png".
+type Storage interface {
+	// Put stores the contents of r under key, replacing any existing blob.
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the blob stored under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob stored under key. Deleting a missing key is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address the blob under key is served from.
+	URL(key string) string
+}
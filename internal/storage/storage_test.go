@@ -0,0 +1,144 @@
+package storage_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jacosy/go-web-server/internal/storage"
+)
+
+func TestFilesystem(t *testing.T) {
+	testStorage(t, storage.NewFilesystem(t.TempDir(), "/app/uploads"))
+}
+
+func TestS3(t *testing.T) {
+	stub := newS3Stub(t, "media", "us-east-1", "test-key")
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	store := storage.NewS3(storage.S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "media",
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+	testStorage(t, store)
+
+	if got, want := store.URL("a/b c.png"), server.URL+"/media/a/b%20c.png"; got != want {
+		t.Fatalf("Expected URL %q, got %q", want, got)
+	}
+	if got := stub.contentTypes["/media/media/1.png"]; got != "image/png" {
+		t.Fatalf("Expected the content type to be stored, got %q", got)
+	}
+}
+
+func testStorage(t *testing.T, store storage.Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "media/1.png", strings.NewReader("first"), "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put(ctx, "media/1.png", strings.NewReader("second"), "image/png"); err != nil {
+		t.Fatalf("Overwriting Put failed: %v", err)
+	}
+
+	r, err := store.Get(ctx, "media/1.png")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "second" {
+		t.Fatalf("Expected %q, got %q, %v", "second", data, err)
+	}
+
+	if err := store.Delete(ctx, "media/1.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete(ctx, "media/1.png"); err != nil {
+		t.Fatalf("Deleting a missing blob should succeed, got %v", err)
+	}
+	if _, err := store.Get(ctx, "media/1.png"); !errors.Is(err, storage.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+// s3Stub is an in-memory stand-in for an S3 bucket. It checks that requests
+// carry SigV4 credentials for the expected key and a payload hash matching
+// the body, but does not recompute signatures.
+type s3Stub struct {
+	t        *testing.T
+	prefix   string
+	wantCred string
+
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+}
+
+func newS3Stub(t *testing.T, bucket, region, accessKey string) *s3Stub {
+	return &s3Stub{
+		t:            t,
+		prefix:       "/" + bucket + "/",
+		wantCred:     "AWS4-HMAC-SHA256 Credential=" + accessKey + "/",
+		objects:      map[string][]byte{},
+		contentTypes: map[string]string{},
+	}
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, s.wantCred) || !strings.Contains(auth, "/us-east-1/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "Signature=") {
+		s.t.Errorf("Unexpected Authorization header %q", auth)
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	if r.Header.Get("X-Amz-Date") == "" {
+		s.t.Errorf("Missing X-Amz-Date header")
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if got := r.Header.Get("X-Amz-Content-Sha256"); got != hex.EncodeToString(sum[:]) {
+		s.t.Errorf("Payload hash %q does not match the body", got)
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.EscapedPath(), s.prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := r.URL.EscapedPath()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[key] = body
+		s.contentTypes[key] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := s.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
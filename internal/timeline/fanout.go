@@ -0,0 +1,357 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/broker"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+)
+
+// JobFanout is the kind of the jobs that keep timelines in sync.
+const JobFanout = "timeline.fanout"
+
+// brokerChannel carries fan-out jobs between instances.
+const brokerChannel = "timeline"
+
+// backfillSize is how many recent chirps are copied into a timeline when it is
+// rebuilt or when its owner follows somebody new.
+const backfillSize = 200
+
+type jobKind int
+
+const (
+	jobChirpCreated jobKind = iota
+	jobChirpDeleted
+	jobFollowed
+	jobUnfollowed
+)
+
+// job is the payload of a fan-out job, and of the broker message that runs it
+// on the other instances.
+type job struct {
+	Kind   jobKind   `json:"kind"`
+	Entry  Entry     `json:"entry"`
+	UserID uuid.UUID `json:"user_id"`
+	// Origin is the instance that ran the job, which skips it on the broker.
+	Origin uuid.UUID `json:"origin,omitempty"`
+}
+
+// Fanout keeps a Store in sync with chirps and follows through the job queue
+// and serves merged timeline reads.
+type Fanout struct {
+	db    *database.Queries
+	store Store
+	// celebrityThreshold is the follower count from which an author's chirps
+	// are pulled at read time instead of being pushed to every follower.
+	celebrityThreshold int64
+	broker             broker.Broker
+	// instance tells the jobs this instance ran apart on the broker.
+	instance uuid.UUID
+
+	mu sync.Mutex
+	// built marks the timelines this instance has materialized, so that one
+	// which is really empty is not rebuilt on every read.
+	built map[uuid.UUID]struct{}
+}
+
+func NewFanout(db *database.Queries, store Store, celebrityThreshold int64) *Fanout {
+	return &Fanout{
+		db:                 db,
+		store:              store,
+		celebrityThreshold: celebrityThreshold,
+		instance:           uuid.New(),
+		built:              make(map[uuid.UUID]struct{}),
+	}
+}
+
+// Distribute runs the jobs run on any instance on this one too, through b.
+// Stores that live in process memory need it once there is more than one
+// instance; a shared store must not be written by every instance.
+func (f *Fanout) Distribute(b broker.Broker) {
+	f.broker = b
+	b.Subscribe(brokerChannel, func(ctx context.Context, msg broker.Message) {
+		if msg.Lost {
+			f.lost()
+			return
+		}
+
+		var j job
+		if err := json.Unmarshal(msg.Payload, &j); err != nil {
+			log.Printf("timeline: invalid broker message: %v", err)
+			return
+		}
+		if j.Origin == f.instance {
+			return
+		}
+		if err := f.process(ctx, j); err != nil {
+			log.Printf("timeline: fan-out job %d from another instance failed: %v", j.Kind, err)
+		}
+	})
+}
+
+// RegisterJobs registers the handler of fan-out jobs on queue.
+func (f *Fanout) RegisterJobs(queue *jobs.Queue) {
+	queue.Register(JobFanout, f.run)
+}
+
+// run processes a fan-out job, then hands it to the other instances. A job
+// that fails here is retried, and processed again, before it is handed on.
+func (f *Fanout) run(ctx context.Context, qj jobs.Job) error {
+	var j job
+	if err := json.Unmarshal(qj.Payload, &j); err != nil {
+		return jobs.Permanent(err)
+	}
+	if err := f.process(ctx, j); err != nil {
+		return err
+	}
+	if f.broker == nil {
+		return nil
+	}
+
+	j.Origin = f.instance
+	payload, err := json.Marshal(j)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	return f.broker.Publish(ctx, brokerChannel, payload)
+}
+
+// ChirpCreated queues a chirp to be pushed to its author's followers. Pass
+// the Queries of the transaction that creates the chirp.
+func (f *Fanout) ChirpCreated(ctx context.Context, db jobs.Store, chirp database.Chirp) error {
+	return enqueue(ctx, db, job{Kind: jobChirpCreated, Entry: entryFromChirp(chirp)})
+}
+
+// ChirpDeleted queues a chirp to be removed from every timeline. Pass the
+// Queries of the transaction that deletes the chirp.
+func (f *Fanout) ChirpDeleted(ctx context.Context, db jobs.Store, chirpID uuid.UUID) error {
+	return enqueue(ctx, db, job{Kind: jobChirpDeleted, Entry: Entry{ChirpID: chirpID}})
+}
+
+// Followed queues a backfill of followeeID's recent chirps into followerID's
+// timeline. Pass the Queries of the transaction that creates the follow.
+func (f *Fanout) Followed(ctx context.Context, db jobs.Store, followerID, followeeID uuid.UUID) error {
+	return enqueue(ctx, db, job{Kind: jobFollowed, UserID: followerID, Entry: Entry{AuthorID: followeeID}})
+}
+
+// Unfollowed queues removal of followeeID's chirps from followerID's
+// timeline. Pass the Queries of the transaction that deletes the follow.
+func (f *Fanout) Unfollowed(ctx context.Context, db jobs.Store, followerID, followeeID uuid.UUID) error {
+	return enqueue(ctx, db, job{Kind: jobUnfollowed, UserID: followerID, Entry: Entry{AuthorID: followeeID}})
+}
+
+func enqueue(ctx context.Context, db jobs.Store, j job) error {
+	_, err := jobs.Enqueue(ctx, db, JobFanout, j, jobs.Options{})
+	return err
+}
+
+func (f *Fanout) process(ctx context.Context, j job) error {
+	switch j.Kind {
+	case jobChirpCreated:
+		celebrity, err := f.isCelebrity(ctx, j.Entry.AuthorID)
+		if err != nil || celebrity {
+			return err
+		}
+
+		followerIDs, err := f.db.GetFollowerIDs(ctx, j.Entry.AuthorID)
+		if err != nil {
+			return err
+		}
+		return f.store.Push(ctx, j.Entry, followerIDs...)
+
+	case jobChirpDeleted:
+		return f.store.RemoveChirp(ctx, j.Entry.ChirpID)
+
+	case jobFollowed:
+		celebrity, err := f.isCelebrity(ctx, j.Entry.AuthorID)
+		if err != nil || celebrity {
+			return err
+		}
+
+		chirps, err := f.db.GetRecentChirpsByUser(ctx, database.GetRecentChirpsByUserParams{
+			UserID: j.Entry.AuthorID,
+			Limit:  backfillSize,
+		})
+		if err != nil {
+			return err
+		}
+		return f.backfill(ctx, j.UserID, chirps)
+
+	case jobUnfollowed:
+		return f.store.RemoveAuthor(ctx, j.UserID, j.Entry.AuthorID)
+	}
+
+	return nil
+}
+
+func (f *Fanout) isCelebrity(ctx context.Context, userID uuid.UUID) (bool, error) {
+	count, err := f.db.CountFollowers(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return count >= f.celebrityThreshold, nil
+}
+
+// Read returns up to limit entries of userID's home timeline after cursor,
+// merging the materialized timeline with chirps pulled from followed
+// celebrity accounts. Pages reaching past the materialized window, or past
+// the horizon of a backfill, are pulled from the follow graph instead.
+func (f *Fanout) Read(ctx context.Context, userID uuid.UUID, cursor *Entry, limit int) ([]Entry, error) {
+	pushed, err := f.store.Range(ctx, userID, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	// An empty first page of a timeline this instance has not built means it
+	// was never materialized, e.g. the in-memory store after a restart.
+	if cursor == nil && len(pushed) == 0 && f.markBuilt(userID) {
+		if err := f.rebuild(ctx, userID); err != nil {
+			f.unmarkBuilt(userID)
+			return nil, err
+		}
+		if pushed, err = f.store.Range(ctx, userID, cursor, limit); err != nil {
+			return nil, err
+		}
+	}
+
+	var beforeCreatedAt sql.NullTime
+	var beforeID uuid.NullUUID
+	if cursor != nil {
+		beforeCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		beforeID = uuid.NullUUID{UUID: cursor.ChirpID, Valid: true}
+	}
+
+	horizon, err := f.store.Horizon(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var pulled []database.Chirp
+	if len(pushed) < limit || !horizon.IsZero() && !pushed[len(pushed)-1].CreatedAt.After(horizon) {
+		// The store holds only a window of the timeline, bounded by its
+		// capacity and by the backfills, so a short page, or one reaching
+		// past the horizon left by a backfill, may be missing older chirps.
+		// Pulling from every followed account covers those, and the
+		// celebrity chirps with them.
+		pulled, err = f.db.GetTimeline(ctx, database.GetTimelineParams{
+			UserID:          userID,
+			BeforeCreatedAt: beforeCreatedAt,
+			BeforeID:        beforeID,
+			Limit:           int32(limit),
+		})
+	} else {
+		pulled, err = f.db.GetCelebrityTimeline(ctx, database.GetCelebrityTimelineParams{
+			UserID:          userID,
+			MinFollowers:    f.celebrityThreshold,
+			BeforeCreatedAt: beforeCreatedAt,
+			BeforeID:        beforeID,
+			Limit:           int32(limit),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pulledEntries := make([]Entry, 0, len(pulled))
+	for _, chirp := range pulled {
+		pulledEntries = append(pulledEntries, entryFromChirp(chirp))
+	}
+
+	return merge(pushed, pulledEntries, limit), nil
+}
+
+// markBuilt records that userID's timeline has been materialized, reporting
+// whether it had not been before.
+func (f *Fanout) markBuilt(userID uuid.UUID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.built[userID]; ok {
+		return false
+	}
+	f.built[userID] = struct{}{}
+	return true
+}
+
+func (f *Fanout) unmarkBuilt(userID uuid.UUID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.built, userID)
+}
+
+// lost drops the timelines of an in-memory store after jobs from other
+// instances may have been missed, so that they are rebuilt from the follow
+// graph instead of staying incomplete.
+func (f *Fanout) lost() {
+	memory, ok := f.store.(*MemoryStore)
+	if !ok {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	memory.Clear()
+	clear(f.built)
+}
+
+func (f *Fanout) rebuild(ctx context.Context, userID uuid.UUID) error {
+	chirps, err := f.db.GetTimeline(ctx, database.GetTimelineParams{
+		UserID: userID,
+		Limit:  backfillSize,
+	})
+	if err != nil {
+		return err
+	}
+	return f.backfill(ctx, userID, chirps)
+}
+
+// backfill pushes up to backfillSize newest-first chirps into userID's
+// timeline. When there may be older ones, the oldest chirp pushed becomes the
+// horizon of the timeline, past which Read pulls instead.
+func (f *Fanout) backfill(ctx context.Context, userID uuid.UUID, chirps []database.Chirp) error {
+	for _, chirp := range chirps {
+		if err := f.store.Push(ctx, entryFromChirp(chirp), userID); err != nil {
+			return err
+		}
+	}
+
+	if len(chirps) < backfillSize {
+		return nil
+	}
+	return f.store.RaiseHorizon(ctx, userID, chirps[len(chirps)-1].CreatedAt.Time)
+}
+
+// merge combines two newest-first entry lists into one of at most limit
+// entries, dropping duplicates.
+func merge(a, b []Entry, limit int) []Entry {
+	merged := make([]Entry, 0, min(len(a)+len(b), limit))
+	seen := make(map[uuid.UUID]struct{}, cap(merged))
+	for len(merged) < limit && (len(a) > 0 || len(b) > 0) {
+		var next Entry
+		if len(b) == 0 || (len(a) > 0 && compare(a[0], b[0]) <= 0) {
+			next, a = a[0], a[1:]
+		} else {
+			next, b = b[0], b[1:]
+		}
+
+		if _, dup := seen[next.ChirpID]; dup {
+			continue
+		}
+		seen[next.ChirpID] = struct{}{}
+		merged = append(merged, next)
+	}
+	return merged
+}
+
+func entryFromChirp(chirp database.Chirp) Entry {
+	return Entry{
+		ChirpID:   chirp.ID,
+		AuthorID:  chirp.UserID,
+		CreatedAt: chirp.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,114 @@
+package timeline_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/broker"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	"github.com/jacosy/go-web-server/internal/timeline"
+)
+
+// jobStore hands the jobs enqueued on it to the queue claiming from it.
+type jobStore struct {
+	mu     sync.Mutex
+	queued []database.Job
+	done   chan string
+}
+
+func (s *jobStore) EnqueueJob(_ context.Context, arg database.EnqueueJobParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := database.Job{ID: uuid.New(), Kind: arg.Kind, Payload: arg.Payload, MaxAttempts: arg.MaxAttempts}
+	s.queued = append(s.queued, job)
+	return job.ID, nil
+}
+
+func (s *jobStore) ClaimJob(context.Context, database.ClaimJobParams) (database.Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queued) == 0 {
+		return database.Job{}, sql.ErrNoRows
+	}
+	job := s.queued[0]
+	s.queued = s.queued[1:]
+	job.Attempts++
+	return job, nil
+}
+
+func (s *jobStore) CompleteJob(context.Context, database.CompleteJobParams) (int64, error) {
+	s.done <- "completed"
+	return 1, nil
+}
+
+func (s *jobStore) RetryJob(_ context.Context, arg database.RetryJobParams) (int64, error) {
+	s.done <- "retried: " + arg.LastError.String
+	return 1, nil
+}
+
+func (s *jobStore) KillJob(_ context.Context, arg database.KillJobParams) (int64, error) {
+	s.done <- "killed: " + arg.LastError.String
+	return 1, nil
+}
+
+func (s *jobStore) DeleteFinishedJobs(context.Context, float64) (int64, error) {
+	return 0, nil
+}
+
+func TestFanoutDistributesJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Two instances with their own stores, joined by one broker. Only the
+	// first runs the job queue.
+	b := broker.NewMemory()
+	reader := uuid.New()
+	entry := timeline.Entry{ChirpID: uuid.New(), AuthorID: uuid.New(), CreatedAt: time.Now()}
+	db := &jobStore{done: make(chan string, 1)}
+	queue := jobs.NewQueue(db)
+
+	var stores []*timeline.MemoryStore
+	var fanouts []*timeline.Fanout
+	for i := range 2 {
+		store := timeline.NewMemoryStore(10)
+		if err := store.Push(ctx, entry, reader); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+		fanout := timeline.NewFanout(nil, store, 10)
+		fanout.Distribute(b)
+		if i == 0 {
+			fanout.RegisterJobs(queue)
+		}
+		stores = append(stores, store)
+		fanouts = append(fanouts, fanout)
+	}
+
+	if err := fanouts[1].ChirpDeleted(ctx, db, entry.ChirpID); err != nil {
+		t.Fatalf("ChirpDeleted failed: %v", err)
+	}
+	queue.Start(ctx, 1)
+
+	select {
+	case outcome := <-db.done:
+		if outcome != "completed" {
+			t.Fatalf("Expected the job to complete, got %s", outcome)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the job to run")
+	}
+
+	for i, store := range stores {
+		got, err := store.Range(ctx, reader, nil, 10)
+		if err != nil {
+			t.Fatalf("Range failed: %v", err)
+		}
+		if len(got) != 0 {
+			t.Fatalf("Expected the chirp to be removed from store %d, got %v", i, got)
+		}
+	}
+}
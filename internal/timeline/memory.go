@@ -0,0 +1,141 @@
+package timeline
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryStore keeps the newest entries of each timeline in process memory.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	capacity int
+	lines    map[uuid.UUID][]Entry
+	// owners indexes which timelines a chirp was pushed to.
+	owners   map[uuid.UUID]map[uuid.UUID]struct{}
+	horizons map[uuid.UUID]time.Time
+}
+
+// NewMemoryStore returns a MemoryStore that keeps at most capacity entries
+// per timeline. Fanout.Read pulls the pages beyond them from the database.
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		lines:    make(map[uuid.UUID][]Entry),
+		owners:   make(map[uuid.UUID]map[uuid.UUID]struct{}),
+		horizons: make(map[uuid.UUID]time.Time),
+	}
+}
+
+// Clear drops every timeline, so that each is rebuilt when next read.
+func (s *MemoryStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.lines)
+	clear(s.owners)
+	clear(s.horizons)
+}
+
+func (s *MemoryStore) Push(_ context.Context, entry Entry, userIDs ...uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, userID := range userIDs {
+		line := s.lines[userID]
+		i, found := slices.BinarySearchFunc(line, entry, compare)
+		if found {
+			continue
+		}
+
+		line = slices.Insert(line, i, entry)
+		if len(line) > s.capacity {
+			for _, dropped := range line[s.capacity:] {
+				s.forget(dropped.ChirpID, userID)
+			}
+			line = line[:s.capacity]
+		}
+		s.lines[userID] = line
+
+		if i < s.capacity {
+			if s.owners[entry.ChirpID] == nil {
+				s.owners[entry.ChirpID] = make(map[uuid.UUID]struct{})
+			}
+			s.owners[entry.ChirpID][userID] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) Range(_ context.Context, userID uuid.UUID, cursor *Entry, limit int) ([]Entry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	line := s.lines[userID]
+	start := 0
+	if cursor != nil {
+		i, found := slices.BinarySearchFunc(line, *cursor, compare)
+		if found {
+			i++
+		}
+		start = i
+	}
+
+	end := min(start+limit, len(line))
+	return slices.Clone(line[start:end]), nil
+}
+
+func (s *MemoryStore) RemoveChirp(_ context.Context, chirpID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for userID := range s.owners[chirpID] {
+		s.lines[userID] = slices.DeleteFunc(s.lines[userID], func(e Entry) bool {
+			return e.ChirpID == chirpID
+		})
+	}
+	delete(s.owners, chirpID)
+
+	return nil
+}
+
+func (s *MemoryStore) RemoveAuthor(_ context.Context, userID, authorID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lines[userID] = slices.DeleteFunc(s.lines[userID], func(e Entry) bool {
+		if e.AuthorID != authorID {
+			return false
+		}
+		s.forget(e.ChirpID, userID)
+		return true
+	})
+
+	return nil
+}
+
+func (s *MemoryStore) RaiseHorizon(_ context.Context, userID uuid.UUID, horizon time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if horizon.After(s.horizons[userID]) {
+		s.horizons[userID] = horizon
+	}
+	return nil
+}
+
+func (s *MemoryStore) Horizon(_ context.Context, userID uuid.UUID) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.horizons[userID], nil
+}
+
+func (s *MemoryStore) forget(chirpID, userID uuid.UUID) {
+	delete(s.owners[chirpID], userID)
+	if len(s.owners[chirpID]) == 0 {
+		delete(s.owners, chirpID)
+	}
+}
@@ -0,0 +1,80 @@
+package timeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/timeline"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := timeline.NewMemoryStore(3)
+	reader, author := uuid.New(), uuid.New()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var entries []timeline.Entry
+	for i := range 4 {
+		entry := timeline.Entry{ChirpID: uuid.New(), AuthorID: author, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		entries = append(entries, entry)
+		if err := store.Push(ctx, entry, reader); err != nil {
+			t.Fatalf("Push failed: %v", err)
+		}
+	}
+
+	got, err := store.Range(ctx, reader, nil, 10)
+	if err != nil {
+		t.Fatalf("Range failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected capacity to cap timeline at 3 entries, got %d", len(got))
+	}
+	if got[0] != entries[3] || got[2] != entries[1] {
+		t.Fatalf("Expected newest-first order, got %v", got)
+	}
+
+	page, err := store.Range(ctx, reader, &got[0], 1)
+	if err != nil {
+		t.Fatalf("Range with cursor failed: %v", err)
+	}
+	if len(page) != 1 || page[0] != entries[2] {
+		t.Fatalf("Expected entry after cursor to be %v, got %v", entries[2], page)
+	}
+
+	if err := store.RemoveChirp(ctx, entries[3].ChirpID); err != nil {
+		t.Fatalf("RemoveChirp failed: %v", err)
+	}
+	if got, _ := store.Range(ctx, reader, nil, 10); len(got) != 2 || got[0] != entries[2] {
+		t.Fatalf("Expected deleted chirp to be gone, got %v", got)
+	}
+
+	if err := store.RemoveAuthor(ctx, reader, author); err != nil {
+		t.Fatalf("RemoveAuthor failed: %v", err)
+	}
+	if got, _ := store.Range(ctx, reader, nil, 10); len(got) != 0 {
+		t.Fatalf("Expected timeline to be empty after unfollow, got %v", got)
+	}
+}
+
+func TestMemoryStoreHorizon(t *testing.T) {
+	ctx := context.Background()
+	store := timeline.NewMemoryStore(3)
+	reader := uuid.New()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, horizon := range []time.Time{base.Add(time.Hour), base} {
+		if err := store.RaiseHorizon(ctx, reader, horizon); err != nil {
+			t.Fatalf("RaiseHorizon failed: %v", err)
+		}
+	}
+	if got, _ := store.Horizon(ctx, reader); !got.Equal(base.Add(time.Hour)) {
+		t.Fatalf("Expected the later horizon to be kept, got %v", got)
+	}
+
+	store.Clear()
+	if got, _ := store.Horizon(ctx, reader); !got.IsZero() {
+		t.Fatalf("Expected no horizon after Clear, got %v", got)
+	}
+}
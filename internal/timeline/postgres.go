@@ -0,0 +1,85 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+)
+
+// PostgresStore keeps timelines in the timeline_entries table.
+type PostgresStore struct {
+	db *database.Queries
+}
+
+func NewPostgresStore(db *database.Queries) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Push(ctx context.Context, entry Entry, userIDs ...uuid.UUID) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	return s.db.InsertTimelineEntries(ctx, database.InsertTimelineEntriesParams{
+		UserIds:   userIDs,
+		ChirpID:   entry.ChirpID,
+		AuthorID:  entry.AuthorID,
+		CreatedAt: entry.CreatedAt,
+	})
+}
+
+func (s *PostgresStore) Range(ctx context.Context, userID uuid.UUID, cursor *Entry, limit int) ([]Entry, error) {
+	params := database.GetTimelineEntriesParams{
+		UserID: userID,
+		Limit:  int32(limit),
+	}
+	if cursor != nil {
+		params.BeforeCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.BeforeID = uuid.NullUUID{UUID: cursor.ChirpID, Valid: true}
+	}
+
+	rows, err := s.db.GetTimelineEntries(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, Entry{
+			ChirpID:   row.ChirpID,
+			AuthorID:  row.AuthorID,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return entries, nil
+}
+
+func (s *PostgresStore) RemoveChirp(ctx context.Context, chirpID uuid.UUID) error {
+	return s.db.DeleteTimelineEntriesByChirp(ctx, chirpID)
+}
+
+func (s *PostgresStore) RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error {
+	return s.db.DeleteTimelineEntriesByAuthor(ctx, database.DeleteTimelineEntriesByAuthorParams{
+		UserID:   userID,
+		AuthorID: authorID,
+	})
+}
+
+func (s *PostgresStore) RaiseHorizon(ctx context.Context, userID uuid.UUID, horizon time.Time) error {
+	return s.db.RaiseTimelineHorizon(ctx, database.RaiseTimelineHorizonParams{
+		UserID:  userID,
+		Horizon: horizon,
+	})
+}
+
+func (s *PostgresStore) Horizon(ctx context.Context, userID uuid.UUID) (time.Time, error) {
+	horizon, err := s.db.GetTimelineHorizon(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	return horizon, err
+}
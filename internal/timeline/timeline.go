@@ -0,0 +1,48 @@
+// Package timeline materializes home timelines by pushing new chirps into
+// per-follower stores when they are written (fan-out-on-write). Chirps from
+// accounts with very large followings are not pushed; they are merged in when
+// the timeline is read instead (fan-out-on-read).
+package timeline
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entry is a reference to a chirp in somebody's timeline.
+type Entry struct {
+	ChirpID   uuid.UUID
+	AuthorID  uuid.UUID
+	CreatedAt time.Time
+}
+
+// compare orders entries newest first, breaking ties on the chirp ID the same
+// way Postgres orders UUIDs.
+func compare(a, b Entry) int {
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(b.ChirpID[:], a.ChirpID[:])
+}
+
+// Store holds materialized timelines. Entries are returned newest first.
+type Store interface {
+	// Push adds entry to the timeline of every user in userIDs.
+	Push(ctx context.Context, entry Entry, userIDs ...uuid.UUID) error
+	// Range returns up to limit entries of userID's timeline that sort after
+	// the cursor, or from the top when cursor is nil.
+	Range(ctx context.Context, userID uuid.UUID, cursor *Entry, limit int) ([]Entry, error)
+	// RemoveChirp drops a chirp from every timeline it was pushed to.
+	RemoveChirp(ctx context.Context, chirpID uuid.UUID) error
+	// RemoveAuthor drops every chirp by authorID from userID's timeline.
+	RemoveAuthor(ctx context.Context, userID, authorID uuid.UUID) error
+	// RaiseHorizon records that userID's timeline may be missing chirps
+	// older than horizon, unless it already was from a later time.
+	RaiseHorizon(ctx context.Context, userID uuid.UUID, horizon time.Time) error
+	// Horizon returns the time before which userID's timeline may be
+	// missing chirps, or the zero time if it is not missing any.
+	Horizon(ctx context.Context, userID uuid.UUID) (time.Time, error)
+}
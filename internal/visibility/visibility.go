@@ -0,0 +1,179 @@
+// Package visibility decides which chirps a user may see. Every path that
+// shows chirps, or acts on them for someone, goes through a Filter, so that
+// moderation, audiences, blocks and mutes are enforced the same way
+// everywhere.
+package visibility
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+)
+
+// Audiences a chirp can be posted to. Unlisted chirps are readable like
+// public ones by signed-in users but are left out of the global listing,
+// hashtags and search.
+const (
+	Public    = "public"
+	Followers = "followers"
+	Unlisted  = "unlisted"
+)
+
+// Parse validates the visibility of a chirp request, defaulting to public.
+func Parse(v string) (string, error) {
+	switch v {
+	case "":
+		return Public, nil
+	case Public, Followers, Unlisted:
+		return v, nil
+	}
+	return "", fmt.Errorf("visibility must be one of %q, %q or %q", Public, Followers, Unlisted)
+}
+
+// Store is the part of *database.Queries a Filter reads.
+type Store interface {
+	GetHiddenAuthorIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	GetFollowedAmong(ctx context.Context, arg database.GetFollowedAmongParams) ([]uuid.UUID, error)
+}
+
+// Filter decides which chirps a viewer may see.
+type Filter struct {
+	viewerID uuid.UUID
+	// hiddenAuthors holds the users the viewer has blocked or muted and the
+	// users who have blocked the viewer.
+	hiddenAuthors map[uuid.UUID]struct{}
+	// follows records, for the authors passed to LoadFollows or SetFollowed,
+	// whether the viewer follows them.
+	follows map[uuid.UUID]bool
+}
+
+// Load reads the relationships that hide chirps from viewerID, which is
+// uuid.Nil for anonymous viewers.
+func Load(ctx context.Context, db Store, viewerID uuid.UUID) (Filter, error) {
+	f := Filter{viewerID: viewerID}
+	if viewerID == uuid.Nil {
+		return f, nil
+	}
+
+	ids, err := db.GetHiddenAuthorIDs(ctx, viewerID)
+	if err != nil {
+		return Filter{}, err
+	}
+	f.hiddenAuthors = make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		f.hiddenAuthors[id] = struct{}{}
+	}
+	return f, nil
+}
+
+// ViewerID returns the viewer the filter is for.
+func (f Filter) ViewerID() uuid.UUID {
+	return f.viewerID
+}
+
+// SetFollowed records that the viewer follows the users in ids, for callers
+// that have read the viewer's follows already. Other authors are still
+// looked up by LoadFollows.
+func (f *Filter) SetFollowed(ids []uuid.UUID) {
+	if f.follows == nil {
+		f.follows = make(map[uuid.UUID]bool, len(ids))
+	}
+	for _, id := range ids {
+		f.follows[id] = true
+	}
+}
+
+// LoadFollows looks up whether the viewer follows the authors of the
+// followers-only chirps among chirps, which CanSee needs to decide on them.
+func (f *Filter) LoadFollows(ctx context.Context, db Store, chirps []database.Chirp) error {
+	if f.viewerID == uuid.Nil {
+		return nil
+	}
+
+	var authors []uuid.UUID
+	for _, chirp := range chirps {
+		if chirp.Visibility != Followers || chirp.UserID == f.viewerID {
+			continue
+		}
+		if _, ok := f.follows[chirp.UserID]; ok {
+			continue
+		}
+		if f.follows == nil {
+			f.follows = make(map[uuid.UUID]bool)
+		}
+		f.follows[chirp.UserID] = false
+		authors = append(authors, chirp.UserID)
+	}
+	if len(authors) == 0 {
+		return nil
+	}
+
+	followed, err := db.GetFollowedAmong(ctx, database.GetFollowedAmongParams{
+		FollowerID: f.viewerID,
+		UserIds:    authors,
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range followed {
+		f.follows[id] = true
+	}
+	return nil
+}
+
+// HidesAuthor reports whether chirps by userID are hidden from the viewer
+// because of a block or mute.
+func (f Filter) HidesAuthor(userID uuid.UUID) bool {
+	_, ok := f.hiddenAuthors[userID]
+	return ok
+}
+
+// CanSee reports whether the viewer may see chirp. Authors always see their
+// own chirps, including ones hidden by a moderator. Anonymous viewers only
+// see public chirps. Followers-only chirps must have been passed to
+// LoadFollows first.
+func (f Filter) CanSee(chirp database.Chirp) bool {
+	if f.viewerID != uuid.Nil && chirp.UserID == f.viewerID {
+		return true
+	}
+	if chirp.HiddenAt.Valid || f.HidesAuthor(chirp.UserID) {
+		return false
+	}
+
+	switch chirp.Visibility {
+	case Public:
+		return true
+	case Unlisted:
+		return f.viewerID != uuid.Nil
+	case Followers:
+		return f.follows[chirp.UserID]
+	}
+	return false
+}
+
+// CanSee is the single-chirp form of Filter, for callers that act on a chirp
+// for viewerID.
+func CanSee(ctx context.Context, db Store, viewerID uuid.UUID, chirp database.Chirp) (bool, error) {
+	f, err := Load(ctx, db, viewerID)
+	if err != nil {
+		return false, err
+	}
+	if err := f.LoadFollows(ctx, db, []database.Chirp{chirp}); err != nil {
+		return false, err
+	}
+	return f.CanSee(chirp), nil
+}
+
+// IsPublic reports whether anyone may see chirp, signed in or not, such as
+// on the public stream or from a shared cache.
+func IsPublic(chirp database.Chirp) bool {
+	return Filter{}.CanSee(chirp)
+}
+
+// Federated reports whether chirp may be served to other servers, which
+// keep unlisted chirps off their public timelines themselves.
+func Federated(chirp database.Chirp) bool {
+	return !chirp.HiddenAt.Valid && (chirp.Visibility == Public || chirp.Visibility == Unlisted)
+}
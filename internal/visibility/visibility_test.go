@@ -0,0 +1,71 @@
+package visibility_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/visibility"
+)
+
+// fakeStore holds the relationships of one viewer.
+type fakeStore struct {
+	hidden   []uuid.UUID
+	followed []uuid.UUID
+}
+
+func (s fakeStore) GetHiddenAuthorIDs(context.Context, uuid.UUID) ([]uuid.UUID, error) {
+	return s.hidden, nil
+}
+
+func (s fakeStore) GetFollowedAmong(_ context.Context, arg database.GetFollowedAmongParams) ([]uuid.UUID, error) {
+	var followed []uuid.UUID
+	for _, id := range arg.UserIds {
+		if slices.Contains(s.followed, id) {
+			followed = append(followed, id)
+		}
+	}
+	return followed, nil
+}
+
+func TestCanSee(t *testing.T) {
+	viewer, followee, stranger, blocked := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	store := fakeStore{hidden: []uuid.UUID{blocked}, followed: []uuid.UUID{followee}}
+	hidden := sql.NullTime{Time: time.Now(), Valid: true}
+
+	tests := []struct {
+		name     string
+		viewerID uuid.UUID
+		chirp    database.Chirp
+		want     bool
+		public   bool
+	}{
+		{"public", viewer, database.Chirp{UserID: stranger, Visibility: visibility.Public}, true, true},
+		{"unlisted", viewer, database.Chirp{UserID: stranger, Visibility: visibility.Unlisted}, true, false},
+		{"unlisted to anonymous", uuid.Nil, database.Chirp{UserID: stranger, Visibility: visibility.Unlisted}, false, false},
+		{"followers of a followee", viewer, database.Chirp{UserID: followee, Visibility: visibility.Followers}, true, false},
+		{"followers of a stranger", viewer, database.Chirp{UserID: stranger, Visibility: visibility.Followers}, false, false},
+		{"blocked author", viewer, database.Chirp{UserID: blocked, Visibility: visibility.Public}, false, true},
+		{"hidden by a moderator", viewer, database.Chirp{UserID: stranger, Visibility: visibility.Public, HiddenAt: hidden}, false, false},
+		{"own hidden chirp", viewer, database.Chirp{UserID: viewer, Visibility: visibility.Followers, HiddenAt: hidden}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := visibility.CanSee(context.Background(), store, tt.viewerID, tt.chirp)
+			if err != nil {
+				t.Fatalf("CanSee failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected CanSee to be %v, got %v", tt.want, got)
+			}
+			if got := visibility.IsPublic(tt.chirp); got != tt.public {
+				t.Errorf("Expected IsPublic to be %v, got %v", tt.public, got)
+			}
+		})
+	}
+}
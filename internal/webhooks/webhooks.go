@@ -0,0 +1,326 @@
+// Package webhooks delivers events from the bus to the URLs users subscribed.
+// Deliveries are queued in the webhook_deliveries table, so they survive
+// restarts and are shared by every instance, and failed ones are retried with
+// exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/retry"
+)
+
+// Events lists the event types webhooks can subscribe to.
+var Events = []events.Type{
+	events.ChirpCreated,
+	events.ChirpDeleted,
+	events.ChirpLiked,
+	events.UserFollowed,
+}
+
+const (
+	// MaxAttempts is how often a delivery is attempted before it fails for
+	// good. With backoff that spans about a day.
+	MaxAttempts = 12
+	// DisableAfter is how many attempts in a row may fail before the webhook
+	// is disabled.
+	DisableAfter = 25
+
+	batchSize      = 5
+	requestTimeout = 10 * time.Second
+	// lease must outlast a batch of requests, or its deliveries would be
+	// claimed again while still in flight.
+	lease        = 2 * time.Minute
+	pollInterval = 5 * time.Second
+	// maxErrorLength bounds the error stored with a failed attempt.
+	maxErrorLength = 500
+)
+
+// backoff spaces the attempts of a delivery: 30 seconds, doubling up to 6
+// hours.
+var backoff = retry.Backoff{First: 30 * time.Second, Max: 6 * time.Hour}
+
+// Request headers sent with every delivery.
+const (
+	HeaderEvent     = "X-Chirpy-Event"
+	HeaderDelivery  = "X-Chirpy-Delivery"
+	HeaderTimestamp = "X-Chirpy-Timestamp"
+	HeaderSignature = "X-Chirpy-Signature"
+)
+
+// Payload is the JSON body of a delivery. ID identifies the event and is the
+// same for every delivery of it, redeliveries included, so receivers can use
+// it to handle each event once.
+type Payload struct {
+	ID        uuid.UUID   `json:"id"`
+	Type      events.Type `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      Data        `json:"data"`
+}
+
+type Data struct {
+	ActorID uuid.UUID  `json:"actor_id"`
+	UserID  *uuid.UUID `json:"user_id,omitempty"`
+	ChirpID *uuid.UUID `json:"chirp_id,omitempty"`
+	// Chirp is set for chirp.created.
+	Chirp *Chirp `json:"chirp,omitempty"`
+}
+
+type Chirp struct {
+	ID            uuid.UUID  `json:"id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	Body          string     `json:"body"`
+	Visibility    string     `json:"visibility"`
+	ReferenceID   *uuid.UUID `json:"reference_id,omitempty"`
+	ReferenceKind string     `json:"reference_kind,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// consumer identifies the dispatcher among the consumers of events.
+const consumer = "webhooks"
+
+// Dispatcher queues a delivery for every webhook subscribed to an event and
+// sends the queued deliveries.
+type Dispatcher struct {
+	conn   *sql.DB
+	db     *database.Queries
+	client *http.Client
+	// wake tells an idle worker that deliveries were queued.
+	wake chan struct{}
+}
+
+// NewDispatcher returns a dispatcher that sends requests with client, which
+// should come from NewClient.
+func NewDispatcher(conn *sql.DB, db *database.Queries, client *http.Client) *Dispatcher {
+	return &Dispatcher{conn: conn, db: db, client: client, wake: make(chan struct{}, 1)}
+}
+
+// Subscribe registers the dispatcher on bus.
+func (d *Dispatcher) Subscribe(bus *events.Bus) {
+	bus.Subscribe(consumer, d.handle, Events...)
+}
+
+// handle queues deliveries of e once, however often e is delivered.
+func (d *Dispatcher) handle(ctx context.Context, e events.Event) error {
+	err := outbox.Consume(ctx, d.conn, d.db, consumer, e, func(qtx *database.Queries) error {
+		return d.enqueue(ctx, qtx, e)
+	})
+	if err != nil {
+		return fmt.Errorf("webhooks: %w", err)
+	}
+	d.Wake()
+	return nil
+}
+
+// enqueue queues deliveries of e for the webhooks of the users it concerns:
+// the actor, and the followed user or the author of a liked chirp.
+func (d *Dispatcher) enqueue(ctx context.Context, db *database.Queries, e events.Event) error {
+	userIDs := []uuid.UUID{e.ActorID}
+	if e.UserID != uuid.Nil && e.UserID != e.ActorID {
+		userIDs = append(userIDs, e.UserID)
+	}
+
+	hooks, err := db.GetWebhooksForEvent(ctx, database.GetWebhooksForEventParams{
+		UserIds:   userIDs,
+		EventType: string(e.Type),
+	})
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	payload, err := buildPayload(ctx, db, e)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		_, err := db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			WebhookID: hook.ID,
+			EventID:   payload.ID,
+			EventType: string(e.Type),
+			Payload:   string(body),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildPayload(ctx context.Context, db *database.Queries, e events.Event) (Payload, error) {
+	p := Payload{
+		ID:        e.ID,
+		Type:      e.Type,
+		CreatedAt: e.OccurredAt.UTC(),
+		Data:      Data{ActorID: e.ActorID},
+	}
+	if e.UserID != uuid.Nil {
+		p.Data.UserID = &e.UserID
+	}
+	if e.ChirpID != uuid.Nil {
+		p.Data.ChirpID = &e.ChirpID
+	}
+
+	if e.Type == events.ChirpCreated {
+		chirp, err := db.GetChirpByID(ctx, e.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted since; its chirp.deleted event follows.
+			return p, nil
+		}
+		if err != nil {
+			return Payload{}, err
+		}
+		p.Data.Chirp = &Chirp{
+			ID:            chirp.ID,
+			UserID:        chirp.UserID,
+			Body:          chirp.Body,
+			Visibility:    chirp.Visibility,
+			ReferenceKind: chirp.ReferenceKind.String,
+			CreatedAt:     chirp.CreatedAt.Time,
+		}
+		if chirp.ReferenceID.Valid {
+			p.Data.Chirp.ReferenceID = &chirp.ReferenceID.UUID
+		}
+	}
+	return p, nil
+}
+
+// Wake tells an idle worker to look for due deliveries now instead of at its
+// next poll.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs workers goroutines that send due deliveries until ctx is done.
+// Instances sharing the database share the queue.
+func (d *Dispatcher) Start(ctx context.Context, workers int) {
+	for range workers {
+		go retry.Poll(ctx, pollInterval, d.wake, d.work)
+	}
+}
+
+// work sends one batch of due deliveries and reports whether it was full.
+func (d *Dispatcher) work(ctx context.Context) bool {
+	deliveries, err := d.db.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: lease.Seconds(),
+		Limit:        batchSize,
+	})
+	if err != nil {
+		log.Printf("webhooks: failed to claim deliveries: %v", err)
+		return false
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, err := d.send(ctx, delivery)
+		if err := d.record(ctx, delivery, statusCode, err); err != nil {
+			log.Printf("webhooks: failed to record delivery %s: %v", delivery.ID, err)
+		}
+	}
+	return len(deliveries) == batchSize
+}
+
+// send makes one delivery attempt. Any status but 2xx is an error.
+func (d *Dispatcher) send(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(delivery.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Draining a little lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record stores the outcome of an attempt and schedules the next one.
+func (d *Dispatcher) record(ctx context.Context, delivery database.ClaimWebhookDeliveriesRow, statusCode int, sendErr error) error {
+	code := sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0}
+
+	if sendErr == nil {
+		if err := d.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+			ID:             delivery.ID,
+			LastStatusCode: code,
+		}); err != nil {
+			return err
+		}
+		return d.db.RecordWebhookSuccess(ctx, delivery.WebhookID)
+	}
+
+	msg := sendErr.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	lastError := sql.NullString{String: msg, Valid: true}
+
+	if delivery.Attempts >= MaxAttempts {
+		err := d.db.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			LastStatusCode: code,
+			LastError:      lastError,
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		err := d.db.ScheduleWebhookRetry(ctx, database.ScheduleWebhookRetryParams{
+			RetryInSeconds: backoff.Jittered(int(delivery.Attempts)).Seconds(),
+			LastStatusCode: code,
+			LastError:      lastError,
+			ID:             delivery.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	disabled, err := d.db.RecordWebhookFailure(ctx, database.RecordWebhookFailureParams{
+		MaxFailures: DisableAfter,
+		ID:          delivery.WebhookID,
+	})
+	if err != nil {
+		return err
+	}
+	if disabled {
+		log.Printf("webhooks: disabled webhook %s after %d failed attempts in a row", delivery.WebhookID, DisableAfter)
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455): the opening handshake, framing, control frames and the closing
+// handshake. Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Opcodes of the messages and control frames.
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Close status codes from RFC 6455 section 7.4.1. Applications may use
+// 4000-4999 for their own.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+	CloseTryAgainLater   = 1013
+)
+
+// acceptGUID is appended to the client's key to compute Sec-WebSocket-Accept.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxControlPayload is the largest payload a control frame may carry.
+const maxControlPayload = 125
+
+var (
+	ErrBadHandshake   = errors.New("websocket: bad handshake")
+	ErrMessageTooBig  = errors.New("websocket: message exceeds read limit")
+	ErrCloseSent      = errors.New("websocket: close frame already sent")
+	errProtocol       = errors.New("websocket: protocol error")
+	errInvalidPayload = errors.New("websocket: text message is not valid UTF-8")
+)
+
+// CloseError is returned by ReadMessage once the peer has closed the
+// connection. The close frame has already been answered.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed by peer with code %d %s", e.Code, e.Reason)
+}
+
+// Conn is an upgraded connection. ReadMessage must only be called from one
+// goroutine at a time; the write methods are safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	readLimit    int64
+	writeTimeout time.Duration
+	pongHandler  func([]byte)
+
+	writeMu   sync.Mutex
+	closeSent bool
+}
+
+// Upgrade performs the opening handshake for r and takes over the connection.
+// On failure it has already replied with an HTTP error. header is added to the
+// 101 response.
+func Upgrade(w http.ResponseWriter, r *http.Request, header http.Header) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "WebSocket upgrades must use GET", http.StatusMethodNotAllowed)
+		return nil, ErrBadHandshake
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "Expected a WebSocket upgrade", http.StatusUpgradeRequired)
+		return nil, ErrBadHandshake
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, ErrBadHandshake
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Invalid Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrBadHandshake
+	}
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket upgrades are not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	// The server's deadlines were meant for the HTTP request.
+	netConn.SetDeadline(time.Time{})
+
+	var resp strings.Builder
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n")
+	for k, vs := range header {
+		for _, v := range vs {
+			resp.WriteString(k + ": " + v + "\r\n")
+		}
+	}
+	resp.WriteString("\r\n")
+	if _, err := io.WriteString(netConn, resp.String()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: netConn, br: brw.Reader, readLimit: 1 << 20}, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept value for a client's
+// Sec-WebSocket-Key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetReadLimit caps the size of a reassembled message. Larger messages close
+// the connection with CloseMessageTooBig. The default is 1 MiB.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetWriteTimeout bounds every frame write. Zero means no timeout.
+func (c *Conn) SetWriteTimeout(d time.Duration) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.writeTimeout = d
+}
+
+// SetPongHandler sets a function called from ReadMessage with the payload of
+// every pong received.
+func (c *Conn) SetPongHandler(h func(payload []byte)) {
+	c.pongHandler = h
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes the underlying connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered and
+// pongs passed to the pong handler along the way. Once the peer closes, the
+// close is echoed and a *CloseError returned. Protocol violations close the
+// connection with the matching status code and return an error.
+func (c *Conn) ReadMessage() (opcode int, data []byte, err error) {
+	var message []byte
+	opcode = -1
+
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch op {
+		case PingMessage:
+			if err := c.WriteControl(PongMessage, payload); err != nil && !errors.Is(err, ErrCloseSent) {
+				return 0, nil, err
+			}
+			continue
+
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler(payload)
+			}
+			continue
+
+		case CloseMessage:
+			closeErr := &CloseError{Code: CloseNoStatus}
+			switch {
+			case len(payload) == 1:
+				return 0, nil, c.fail(errProtocol)
+			case len(payload) >= 2:
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+				if !utf8.ValidString(closeErr.Reason) {
+					return 0, nil, c.fail(errInvalidPayload)
+				}
+			}
+			code := closeErr.Code
+			if code == CloseNoStatus {
+				code = CloseNormal
+			}
+			c.WriteClose(code, "")
+			return 0, nil, closeErr
+
+		case TextMessage, BinaryMessage:
+			if opcode != -1 {
+				// A new message started before the previous one finished.
+				return 0, nil, c.fail(errProtocol)
+			}
+			opcode = op
+
+		case continuationFrame:
+			if opcode == -1 {
+				return 0, nil, c.fail(errProtocol)
+			}
+
+		default:
+			return 0, nil, c.fail(errProtocol)
+		}
+
+		if int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(ErrMessageTooBig)
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if opcode == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(errInvalidPayload)
+			}
+			return opcode, message, nil
+		}
+	}
+}
+
+// readFrame reads and unmasks one frame.
+func (c *Conn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	if header[0]&0x70 != 0 {
+		// Reserved bits are only set by extensions.
+		return false, 0, nil, errProtocol
+	}
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	if !masked {
+		// Clients must mask every frame they send.
+		return false, 0, nil, errProtocol
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= CloseMessage && (!fin || length > maxControlPayload) {
+		return false, 0, nil, errProtocol
+	}
+	if length > uint64(c.readLimit) {
+		return false, 0, nil, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail sends the close frame matching err, if any, and returns err.
+func (c *Conn) fail(err error) error {
+	switch {
+	case errors.Is(err, errProtocol):
+		c.WriteClose(CloseProtocolError, "")
+	case errors.Is(err, errInvalidPayload):
+		c.WriteClose(CloseInvalidPayload, "")
+	case errors.Is(err, ErrMessageTooBig):
+		c.WriteClose(CloseMessageTooBig, "")
+	}
+	return err
+}
+
+// WriteMessage sends data as one text or binary message.
+func (c *Conn) WriteMessage(opcode int, data []byte) error {
+	if opcode != TextMessage && opcode != BinaryMessage {
+		return fmt.Errorf("websocket: invalid message opcode %d", opcode)
+	}
+	return c.writeFrame(opcode, data)
+}
+
+// WriteControl sends a ping or pong.
+func (c *Conn) WriteControl(opcode int, payload []byte) error {
+	if opcode != PingMessage && opcode != PongMessage {
+		return fmt.Errorf("websocket: invalid control opcode %d", opcode)
+	}
+	if len(payload) > maxControlPayload {
+		return errors.New("websocket: control payload too long")
+	}
+	return c.writeFrame(opcode, payload)
+}
+
+// WriteClose starts or completes the closing handshake. Nothing can be sent
+// after it; the caller keeps reading until the peer's close arrives and then
+// closes the connection.
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > maxControlPayload {
+		payload = payload[:maxControlPayload]
+	}
+	return c.writeFrame(CloseMessage, payload)
+}
+
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if c.closeSent {
+		return ErrCloseSent
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	header := []byte{0x80 | byte(opcode)}
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(c.conn)
+	return err
+}
@@ -0,0 +1,241 @@
+package websocket_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacosy/go-web-server/internal/websocket"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455 section 1.3.
+	got := websocket.AcceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	if want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="; got != want {
+		t.Fatalf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		websocket.Upgrade(w, r, nil)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("Expected status %d, got %d", http.StatusUpgradeRequired, resp.StatusCode)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	c := dial(t, echoServer(t, 1024))
+
+	c.writeFrame(t, true, websocket.TextMessage, []byte("hello"))
+	if fin, op, payload := c.readFrame(t); !fin || op != websocket.TextMessage || string(payload) != "hello" {
+		t.Fatalf("Expected the text message echoed, got fin=%v op=%d %q", fin, op, payload)
+	}
+
+	// Fragmented, with a ping in between.
+	c.writeFrame(t, false, websocket.BinaryMessage, []byte("ab"))
+	c.writeFrame(t, true, websocket.PingMessage, []byte("p"))
+	c.writeFrame(t, true, 0, []byte("cd"))
+	if _, op, payload := c.readFrame(t); op != websocket.PongMessage || string(payload) != "p" {
+		t.Fatalf("Expected a pong, got op=%d %q", op, payload)
+	}
+	if _, op, payload := c.readFrame(t); op != websocket.BinaryMessage || string(payload) != "abcd" {
+		t.Fatalf("Expected the reassembled message, got op=%d %q", op, payload)
+	}
+
+	// A message long enough for the 16-bit length.
+	long := []byte(strings.Repeat("x", 300))
+	c.writeFrame(t, true, websocket.TextMessage, long)
+	if _, _, payload := c.readFrame(t); string(payload) != string(long) {
+		t.Fatalf("Expected %d bytes echoed, got %d", len(long), len(payload))
+	}
+
+	c.writeFrame(t, true, websocket.CloseMessage, binary.BigEndian.AppendUint16(nil, websocket.CloseNormal))
+	if code := c.readClose(t); code != websocket.CloseNormal {
+		t.Fatalf("Expected the close echoed with %d, got %d", websocket.CloseNormal, code)
+	}
+}
+
+func TestProtocolViolations(t *testing.T) {
+	tests := []struct {
+		name     string
+		send     func(t *testing.T, c *client)
+		wantCode int
+	}{
+		{"unmasked frame", func(t *testing.T, c *client) {
+			c.write(t, []byte{0x81, 0x01, 'x'})
+		}, websocket.CloseProtocolError},
+		{"unknown opcode", func(t *testing.T, c *client) {
+			c.writeFrame(t, true, 3, nil)
+		}, websocket.CloseProtocolError},
+		{"continuation without a message", func(t *testing.T, c *client) {
+			c.writeFrame(t, true, 0, []byte("x"))
+		}, websocket.CloseProtocolError},
+		{"fragmented ping", func(t *testing.T, c *client) {
+			c.writeFrame(t, false, websocket.PingMessage, nil)
+		}, websocket.CloseProtocolError},
+		{"invalid UTF-8", func(t *testing.T, c *client) {
+			c.writeFrame(t, true, websocket.TextMessage, []byte{0xff, 0xfe})
+		}, websocket.CloseInvalidPayload},
+		{"message too big", func(t *testing.T, c *client) {
+			c.writeFrame(t, false, websocket.TextMessage, []byte(strings.Repeat("x", 10)))
+			c.writeFrame(t, true, 0, []byte(strings.Repeat("x", 10)))
+		}, websocket.CloseMessageTooBig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dial(t, echoServer(t, 16))
+			tt.send(t, c)
+			if code := c.readClose(t); code != tt.wantCode {
+				t.Fatalf("Expected close code %d, got %d", tt.wantCode, code)
+			}
+		})
+	}
+}
+
+// echoServer upgrades every request and echoes messages until the
+// connection closes.
+func echoServer(t *testing.T, readLimit int64) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(readLimit)
+
+		for {
+			op, data, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					return
+				}
+				// Read until the client hangs up, so that closing does not
+				// reset the connection before it has read the close frame.
+				conn.SetReadDeadline(time.Now().Add(time.Second))
+				for range 10 {
+					_, _, err := conn.ReadMessage()
+					if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, os.ErrDeadlineExceeded) {
+						return
+					}
+				}
+				return
+			}
+			conn.WriteMessage(op, data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type client struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dial(t *testing.T, srv *httptest.Server) *client {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, req); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != websocket.AcceptKey(key) {
+		t.Fatalf("Expected a valid 101 response, got %d %v", resp.StatusCode, resp.Header)
+	}
+	return &client{conn: conn, br: br}
+}
+
+func (c *client) write(t *testing.T, b []byte) {
+	t.Helper()
+	if _, err := c.conn.Write(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeFrame sends a masked frame, as clients must.
+func (c *client) writeFrame(t *testing.T, fin bool, opcode int, payload []byte) {
+	t.Helper()
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+	if n := len(payload); n <= 125 {
+		frame = append(frame, 0x80|byte(n))
+	} else {
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	c.write(t, frame)
+}
+
+func (c *client) readFrame(t *testing.T) (fin bool, opcode int, payload []byte) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	if header[1]&0x80 != 0 {
+		t.Fatal("Expected an unmasked frame from the server")
+	}
+	n := int(header[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			t.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatal(err)
+	}
+	return header[0]&0x80 != 0, int(header[0] & 0x0f), payload
+}
+
+func (c *client) readClose(t *testing.T) int {
+	t.Helper()
+	_, op, payload := c.readFrame(t)
+	if op != websocket.CloseMessage || len(payload) < 2 {
+		t.Fatalf("Expected a close frame, got op=%d %q", op, payload)
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
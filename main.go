@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // Import PostgreSQL driver
 
 	"github.com/jacosy/go-web-server/handler"
+	"github.com/jacosy/go-web-server/internal/activitypub"
+	"github.com/jacosy/go-web-server/internal/broker"
 	"github.com/jacosy/go-web-server/internal/database"
+	"github.com/jacosy/go-web-server/internal/events"
+	"github.com/jacosy/go-web-server/internal/jobs"
+	"github.com/jacosy/go-web-server/internal/moderation"
+	"github.com/jacosy/go-web-server/internal/notifications"
+	"github.com/jacosy/go-web-server/internal/outbox"
+	"github.com/jacosy/go-web-server/internal/realtime"
+	"github.com/jacosy/go-web-server/internal/storage"
+	"github.com/jacosy/go-web-server/internal/timeline"
+	"github.com/jacosy/go-web-server/internal/webhooks"
 )
 
 func main() {
@@ -24,8 +44,140 @@ func main() {
 
 	dbQueries := database.New(db)
 	secretKey := os.Getenv("SECRET_KEY")
+
+	// With more than one instance, BROKER=postgres is needed for live updates
+	// and in-memory timelines to see the writes of the other instances.
+	var messageBroker broker.Broker
+	if os.Getenv("BROKER") == "postgres" {
+		messageBroker = broker.NewPostgres(dbQueries, dbURL)
+	} else {
+		messageBroker = broker.NewMemory()
+	}
+
+	var timelineStore timeline.Store
+	sharedTimelines := os.Getenv("TIMELINE_STORE") == "postgres"
+	if sharedTimelines {
+		timelineStore = timeline.NewPostgresStore(dbQueries)
+	} else {
+		timelineStore = timeline.NewMemoryStore(800)
+	}
+
+	celebrityThreshold := int64(10000)
+	if v := os.Getenv("TIMELINE_CELEBRITY_THRESHOLD"); v != "" {
+		celebrityThreshold, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid TIMELINE_CELEBRITY_THRESHOLD: %v", err)
+		}
+	}
+
+	fanout := timeline.NewFanout(dbQueries, timelineStore, celebrityThreshold)
+	if !sharedTimelines {
+		fanout.Distribute(messageBroker)
+	}
+
+	// Domain events are written to the outbox with the changes they describe
+	// and dispatched on the bus by the relay. Only events that may be lost,
+	// such as new messages and notifications, are published on it directly.
+	bus := events.NewBus(1024)
+	relay := outbox.NewRelay(dbQueries, bus)
+	notifications.NewRecorder(db, dbQueries).Subscribe(bus)
+
+	// WEBHOOKS_ALLOW_PRIVATE lets webhooks reach internal addresses, which is
+	// only meant for local development.
+	dispatcher := webhooks.NewDispatcher(db, dbQueries, webhooks.NewClient(os.Getenv("WEBHOOKS_ALLOW_PRIVATE") == "true"))
+	dispatcher.Subscribe(bus)
+	dispatcher.Start(context.Background(), 4)
+
+	// Federation needs PUBLIC_URL, which the IDs of actors and objects are
+	// built from. Remote servers are reached with the client webhooks use,
+	// which keeps them off internal addresses unless
+	// FEDERATION_ALLOW_PRIVATE is set for local development.
+	var federation *activitypub.Service
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		client := webhooks.NewClient(os.Getenv("FEDERATION_ALLOW_PRIVATE") == "true")
+		federation, err = activitypub.NewService(publicURL, dbQueries, client, activitypub.NewJobQueue(dbQueries))
+		if err != nil {
+			log.Fatalf("Invalid PUBLIC_URL: %v", err)
+		}
+		federation.Subscribe(bus)
+	} else {
+		log.Println("Federation is disabled: PUBLIC_URL is not set")
+	}
+
+	hub := realtime.NewHub(1024, 64)
+	realtime.Bridge(bus, messageBroker, hub, dbQueries)
+	bus.Start(context.Background(), 2)
+
+	if err := messageBroker.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start the message broker: %v", err)
+	}
+	relay.Start(context.Background())
+
+	var blobStore storage.Storage
+	// serveAvatars is set for backends without public URLs of their own.
+	var serveAvatars bool
+	if os.Getenv("STORAGE_BACKEND") == "s3" {
+		// Avatars link to the bucket directly, so S3_PUBLIC_URL (or the bucket
+		// itself) must be publicly readable. Media is proxied through the API.
+		blobStore = storage.NewS3(storage.S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			PublicURL:       os.Getenv("S3_PUBLIC_URL"),
+		})
+	} else {
+		// Uploads must live outside the working directory, which the /app/
+		// file server exposes. Avatars are served under /uploads/ and media
+		// only through the media handler.
+		uploadsDir := os.Getenv("UPLOADS_DIR")
+		if uploadsDir == "" {
+			uploadsDir = filepath.Join(os.TempDir(), "chirpy-uploads")
+			log.Printf("UPLOADS_DIR is not set, storing uploads in %s", uploadsDir)
+		}
+		if err := checkOutsideStaticRoot(uploadsDir); err != nil {
+			log.Fatalf("Invalid UPLOADS_DIR: %v", err)
+		}
+		blobStore = storage.NewFilesystem(uploadsDir, "/uploads")
+		serveAvatars = true
+	}
+
+	jobQueue := jobs.NewQueue(dbQueries)
+	handler.RegisterJobs(jobQueue, blobStore)
+	fanout.RegisterJobs(jobQueue)
+	if federation != nil {
+		federation.RegisterJobs(jobQueue)
+	}
+
+	mediaQuota := int64(100 << 20)
+	if v := os.Getenv("MEDIA_QUOTA_BYTES"); v != "" {
+		mediaQuota, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			log.Fatalf("Invalid MEDIA_QUOTA_BYTES: %v", err)
+		}
+	}
+
+	// Without MODERATION_CONFIG only the built-in wordlist applies. A
+	// configured file is reloaded whenever it changes.
+	moderator := moderation.New()
+	if path := os.Getenv("MODERATION_CONFIG"); path != "" {
+		if err := moderator.LoadFile(path); err != nil {
+			log.Fatalf("Failed to load moderation config: %v", err)
+		}
+		moderator.WatchFile(context.Background(), path, 10*time.Second)
+	} else {
+		filters, err := moderation.DefaultConfig.Build()
+		if err != nil {
+			log.Fatalf("Invalid default moderation config: %v", err)
+		}
+		moderator.Replace(filters)
+	}
+
 	apiCfg := &apiConfig{
+		conn:      db,
 		db:        dbQueries,
+		relay:     relay,
 		env:       os.Getenv("PLATFORM"),
 		secretKey: secretKey,
 	}
@@ -44,19 +196,221 @@ func main() {
 	serveMux.HandleFunc("GET /admin/metrics", apiCfg.MetricsHandler)
 	serveMux.HandleFunc("POST /admin/reset", apiCfg.ResetMetricsHandler)
 
+	jobHandler := handler.NewJobHandler(dbQueries, secretKey)
+	serveMux.HandleFunc("GET /admin/jobs", jobHandler.GetJobs)
+	serveMux.HandleFunc("GET /admin/jobs/counts", jobHandler.GetJobCounts)
+	serveMux.HandleFunc("GET /admin/jobs/{id}", jobHandler.GetJob)
+	serveMux.HandleFunc("POST /admin/jobs/{id}/retry", jobHandler.RetryJob)
+	serveMux.HandleFunc("DELETE /admin/jobs/{id}", jobHandler.DiscardJob)
+
+	outboxHandler := handler.NewOutboxHandler(dbQueries, secretKey, relay)
+	serveMux.HandleFunc("GET /admin/outbox/failed", outboxHandler.GetFailedEvents)
+	serveMux.HandleFunc("POST /admin/outbox/{id}/retry", outboxHandler.RetryEvent)
+
 	serveMux.HandleFunc("POST /api/users", apiCfg.CreateUser)
 	serveMux.HandleFunc("POST /api/login", apiCfg.LoginUser)
 
-	chirpHandler := handler.NewChirpHandler(dbQueries, secretKey)
+	chirpHandler := handler.NewChirpHandler(db, dbQueries, secretKey, fanout, relay, moderator)
 	serveMux.HandleFunc("POST /api/chirps", chirpHandler.CreateChirp)
 	serveMux.HandleFunc("GET /api/chirps", chirpHandler.GetChirps)
 	serveMux.HandleFunc("GET /api/chirps/{id}", chirpHandler.GetChirpByID)
+	serveMux.HandleFunc("PUT /api/chirps/{id}", chirpHandler.UpdateChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{id}", chirpHandler.DeleteChirp)
+	serveMux.HandleFunc("POST /api/chirps/{id}/rechirp", chirpHandler.Rechirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{id}/rechirp", chirpHandler.UndoRechirp)
+	serveMux.HandleFunc("GET /api/hashtags/{tag}/chirps", chirpHandler.GetChirpsByHashtag)
+
+	draftHandler := handler.NewDraftHandler(db, dbQueries, secretKey, fanout, relay, moderator)
+	draftHandler.RegisterJobs(jobQueue)
+	serveMux.HandleFunc("POST /api/drafts", draftHandler.CreateDraft)
+	serveMux.HandleFunc("GET /api/drafts", draftHandler.GetDrafts)
+	serveMux.HandleFunc("GET /api/drafts/{id}", draftHandler.GetDraft)
+	serveMux.HandleFunc("PUT /api/drafts/{id}", draftHandler.UpdateDraft)
+	serveMux.HandleFunc("DELETE /api/drafts/{id}", draftHandler.DeleteDraft)
+	serveMux.HandleFunc("POST /api/drafts/{id}/publish", draftHandler.PublishDraft)
+
+	pollHandler := handler.NewPollHandler(dbQueries, secretKey)
+	serveMux.HandleFunc("POST /api/chirps/{id}/vote", pollHandler.Vote)
+
+	likeHandler := handler.NewLikeHandler(db, dbQueries, secretKey, relay)
+	serveMux.HandleFunc("POST /api/chirps/{id}/like", likeHandler.LikeChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{id}/like", likeHandler.UnlikeChirp)
+	serveMux.HandleFunc("GET /api/chirps/{id}/likers", likeHandler.GetLikers)
+
+	mediaHandler := handler.NewMediaHandler(db, dbQueries, secretKey, blobStore, mediaQuota)
+	serveMux.HandleFunc("POST /api/media", mediaHandler.UploadMedia)
+	serveMux.HandleFunc("GET /api/media/usage", mediaHandler.GetUsage)
+	serveMux.HandleFunc("GET /api/media/{id}", mediaHandler.GetMedia)
+	serveMux.HandleFunc("GET /api/media/{id}/thumbnail", mediaHandler.GetThumbnail)
+	serveMux.HandleFunc("PUT /api/media/{id}", mediaHandler.UpdateMedia)
+	serveMux.HandleFunc("DELETE /api/media/{id}", mediaHandler.DeleteMedia)
+
+	userHandler := handler.NewUserHandler(dbQueries, secretKey, blobStore)
+	serveMux.HandleFunc("GET /api/users/search", userHandler.SearchUsers)
+	serveMux.HandleFunc("PUT /api/users/profile", userHandler.UpdateProfile)
+	serveMux.HandleFunc("POST /api/users/avatar", userHandler.UploadAvatar)
+	serveMux.HandleFunc("DELETE /api/users/avatar", userHandler.DeleteAvatar)
+	serveMux.HandleFunc("GET /api/users/{id}", userHandler.GetUserByID)
+	if serveAvatars {
+		serveMux.HandleFunc("GET /uploads/avatars/{user}/{file}", userHandler.GetAvatar)
+	}
+
+	followHandler := handler.NewFollowHandler(db, dbQueries, secretKey, fanout, relay)
+	serveMux.HandleFunc("POST /api/users/{id}/follow", followHandler.FollowUser)
+	serveMux.HandleFunc("DELETE /api/users/{id}/follow", followHandler.UnfollowUser)
+
+	blockHandler := handler.NewBlockHandler(db, dbQueries, secretKey, fanout, relay)
+	serveMux.HandleFunc("POST /api/users/{id}/block", blockHandler.BlockUser)
+	serveMux.HandleFunc("DELETE /api/users/{id}/block", blockHandler.UnblockUser)
+	serveMux.HandleFunc("POST /api/users/{id}/mute", blockHandler.MuteUser)
+	serveMux.HandleFunc("DELETE /api/users/{id}/mute", blockHandler.UnmuteUser)
+	serveMux.HandleFunc("GET /api/blocks", blockHandler.GetBlocks)
+	serveMux.HandleFunc("GET /api/mutes", blockHandler.GetMutes)
+
+	// GET /api/users/by-username/{username} has the same shape as the
+	// GET /api/users/{id}/... routes, which the mux rejects as ambiguous, so
+	// they share one pattern and are dispatched here.
+	userSubroutes := map[string]http.HandlerFunc{
+		"followers": followHandler.GetFollowers,
+		"following": followHandler.GetFollowing,
+		"mentions":  chirpHandler.GetMentions,
+	}
+	serveMux.HandleFunc("GET /api/users/{id}/{sub}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "by-username" {
+			r.SetPathValue("username", r.PathValue("sub"))
+			userHandler.GetUserByUsername(w, r)
+			return
+		}
+
+		subroute, ok := userSubroutes[r.PathValue("sub")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		subroute(w, r)
+	})
+
+	reportHandler := handler.NewReportHandler(db, dbQueries, secretKey)
+	serveMux.HandleFunc("POST /api/reports", reportHandler.CreateReport)
+	serveMux.HandleFunc("GET /api/warnings", reportHandler.GetWarnings)
+	serveMux.HandleFunc("GET /api/moderation/reports", reportHandler.GetReports)
+	serveMux.HandleFunc("GET /api/moderation/reports/{id}", reportHandler.GetReport)
+	serveMux.HandleFunc("POST /api/moderation/reports/{id}/claim", reportHandler.ClaimReport)
+	serveMux.HandleFunc("POST /api/moderation/reports/{id}/resolve", reportHandler.ResolveReport)
+	serveMux.HandleFunc("GET /api/moderation/users/{id}/actions", reportHandler.GetUserActions)
+
+	conversationHandler := handler.NewConversationHandler(db, dbQueries, secretKey, bus)
+	serveMux.HandleFunc("POST /api/conversations", conversationHandler.CreateConversation)
+	serveMux.HandleFunc("GET /api/conversations", conversationHandler.GetConversations)
+	serveMux.HandleFunc("GET /api/conversations/{id}/messages", conversationHandler.GetMessages)
+	serveMux.HandleFunc("POST /api/conversations/{id}/messages", conversationHandler.SendMessage)
+	serveMux.HandleFunc("POST /api/conversations/{id}/read", conversationHandler.MarkRead)
+
+	notificationHandler := handler.NewNotificationHandler(dbQueries, secretKey)
+	serveMux.HandleFunc("GET /api/notifications", notificationHandler.GetNotifications)
+	serveMux.HandleFunc("POST /api/notifications/read", notificationHandler.MarkAllRead)
+	serveMux.HandleFunc("POST /api/notifications/{id}/read", notificationHandler.MarkRead)
+
+	streamHandler := handler.NewStreamHandler(dbQueries, secretKey, hub)
+	serveMux.HandleFunc("GET /api/stream", streamHandler.GetStream)
+
+	socketHandler := handler.NewSocketHandler(dbQueries, secretKey, hub, bus)
+	serveMux.HandleFunc("GET /api/ws", socketHandler.Connect)
+
+	webhookHandler := handler.NewWebhookHandler(dbQueries, secretKey, dispatcher)
+	serveMux.HandleFunc("POST /api/webhooks", webhookHandler.CreateWebhook)
+	serveMux.HandleFunc("GET /api/webhooks", webhookHandler.GetWebhooks)
+	serveMux.HandleFunc("GET /api/webhooks/{id}", webhookHandler.GetWebhook)
+	serveMux.HandleFunc("PUT /api/webhooks/{id}", webhookHandler.UpdateWebhook)
+	serveMux.HandleFunc("DELETE /api/webhooks/{id}", webhookHandler.DeleteWebhook)
+	serveMux.HandleFunc("GET /api/webhooks/{id}/deliveries", webhookHandler.GetDeliveries)
+	serveMux.HandleFunc("POST /api/webhooks/{id}/deliveries/{deliveryID}/redeliver", webhookHandler.Redeliver)
+
+	searchHandler := handler.NewSearchHandler(dbQueries, secretKey)
+	serveMux.HandleFunc("GET /api/search/chirps", searchHandler.SearchChirps)
+
+	timelineHandler := handler.NewTimelineHandler(dbQueries, secretKey, fanout)
+	serveMux.HandleFunc("GET /api/timeline", timelineHandler.GetTimeline)
+
+	// PUBLIC_URL is the URL the server is reached at, such as
+	// https://chirpy.example. Without it links in feeds are built from the
+	// Host of each request.
+	feedHandler := handler.NewFeedHandler(dbQueries, os.Getenv("PUBLIC_URL"))
+	serveMux.HandleFunc("GET /users/{username}/feed.atom", feedHandler.GetUserFeed)
+	serveMux.HandleFunc("GET /users/{username}/feed.rss", feedHandler.GetUserFeed)
+	serveMux.HandleFunc("GET /hashtags/{tag}/feed.atom", feedHandler.GetHashtagFeed)
+	serveMux.HandleFunc("GET /hashtags/{tag}/feed.rss", feedHandler.GetHashtagFeed)
+
+	if federation != nil {
+		federationHandler := handler.NewFederationHandler(dbQueries, secretKey, federation)
+		serveMux.HandleFunc("GET /.well-known/webfinger", federationHandler.WebFinger)
+		serveMux.HandleFunc("GET /users/{username}", federationHandler.GetActor)
+		serveMux.HandleFunc("GET /users/{username}/outbox", federationHandler.GetOutbox)
+		serveMux.HandleFunc("GET /users/{username}/followers", federationHandler.GetFollowers)
+		serveMux.HandleFunc("GET /users/{username}/following", federationHandler.GetFollowing)
+		serveMux.HandleFunc("POST /users/{username}/inbox", federationHandler.PostInbox)
+		serveMux.HandleFunc("POST /inbox", federationHandler.PostInbox)
+		serveMux.HandleFunc("GET /chirps/{id}", federationHandler.GetObject)
+		serveMux.HandleFunc("POST /api/federation/follows", federationHandler.FollowRemote)
+		serveMux.HandleFunc("GET /api/federation/follows", federationHandler.GetRemoteFollowing)
+		serveMux.HandleFunc("DELETE /api/federation/follows/{id}", federationHandler.UnfollowRemote)
+		serveMux.HandleFunc("GET /api/federation/notes", federationHandler.GetRemoteNotes)
+	}
+
+	// Every handler has registered its jobs by now. Most of the workers go
+	// to timeline fan-out.
+	jobQueue.Start(context.Background(), 6)
 
 	server := http.Server{
 		Addr:    ":8080",
-		Handler: serveMux,
+		Handler: handler.RejectSuspended(dbQueries, secretKey, serveMux),
+	}
+	// Open streams would otherwise hold up Shutdown until it times out.
+	server.RegisterOnShutdown(streamHandler.Drain)
+
+	shutdown, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Serving on port: %s\n", server.Addr)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-shutdown.Done()
+	log.Println("Shutting down")
+
+	// Shutdown stops accepting connections and waits for requests in flight.
+	// WebSockets are hijacked, so they are drained separately.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Failed to shut down cleanly: %v", err)
+	}
+	if err := socketHandler.Drain(ctx); err != nil {
+		log.Printf("Failed to drain WebSocket connections: %v", err)
+	}
+}
+
+// checkOutsideStaticRoot reports an error if dir is inside the working
+// directory, where the /app/ file server would serve its files to anyone.
+func checkOutsideStaticRoot(dir string) error {
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Serving on port: %s\n", server.Addr)
-	log.Fatal(server.ListenAndServe())
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil
+	}
+	if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is inside the directory served under /app/", dir)
+	}
+	return nil
 }
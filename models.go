@@ -14,7 +14,9 @@ type UserRequest struct {
 
 type UserResponse struct {
 	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name,omitempty"`
+	Username     string    `json:"username"`
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Email        string    `json:"email"`
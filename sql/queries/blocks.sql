@@ -0,0 +1,59 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT u.id, u.username, b.created_at AS blocked_at
+FROM blocks b
+JOIN users u ON u.id = b.blocked_id
+WHERE b.blocker_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_blocked_at')::timestamp IS NULL
+    OR (b.created_at, u.id) < (sqlc.narg('before_blocked_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY b.created_at DESC, u.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetMutedUsers :many
+SELECT u.id, u.username, m.created_at AS muted_at
+FROM mutes m
+JOIN users u ON u.id = m.muted_id
+WHERE m.muter_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_muted_at')::timestamp IS NULL
+    OR (m.created_at, u.id) < (sqlc.narg('before_muted_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY m.created_at DESC, u.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetHiddenAuthorIDs :many
+-- Users whose chirps the viewer does not see: those blocked by the viewer,
+-- those who blocked the viewer, and those the viewer muted.
+SELECT blocked_id AS user_id FROM blocks WHERE blocks.blocker_id = sqlc.arg('viewer_id')
+UNION
+SELECT blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg('viewer_id')
+UNION
+SELECT muted_id FROM mutes WHERE mutes.muter_id = sqlc.arg('viewer_id');
+
+-- name: GetBlockedAmong :many
+-- Returns the users in user_ids that have a block with user_id, in either
+-- direction.
+SELECT blocked_id AS user_id FROM blocks
+WHERE blocker_id = sqlc.arg('user_id') AND blocked_id = ANY(sqlc.arg('user_ids')::uuid[])
+UNION
+SELECT blocker_id FROM blocks
+WHERE blocked_id = sqlc.arg('user_id') AND blocker_id = ANY(sqlc.arg('user_ids')::uuid[]);
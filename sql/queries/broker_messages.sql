@@ -0,0 +1,20 @@
+-- name: CreateBrokerMessage :exec
+INSERT INTO broker_messages (channel, payload, created_at)
+VALUES ($1, $2, NOW());
+
+-- name: GetBrokerMessage :one
+SELECT * FROM broker_messages
+WHERE id = $1;
+
+-- name: GetBrokerMessagesAfter :many
+SELECT * FROM broker_messages
+WHERE id > $1
+ORDER BY id;
+
+-- name: GetLatestBrokerMessageID :one
+SELECT COALESCE(MAX(id), 0)::bigint AS latest_id
+FROM broker_messages;
+
+-- name: DeleteExpiredBrokerMessages :execrows
+DELETE FROM broker_messages
+WHERE created_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision);
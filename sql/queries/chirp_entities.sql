@@ -0,0 +1,54 @@
+-- name: InsertChirpHashtags :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, start_offset, end_offset)
+SELECT sqlc.arg('chirp_id')::uuid, unnest(sqlc.arg('tags')::text[]), unnest(sqlc.arg('start_offsets')::int[]), unnest(sqlc.arg('end_offsets')::int[]);
+
+-- name: InsertChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id, username, start_offset, end_offset)
+SELECT sqlc.arg('chirp_id')::uuid, unnest(sqlc.arg('user_ids')::uuid[]), unnest(sqlc.arg('usernames')::text[]), unnest(sqlc.arg('start_offsets')::int[]), unnest(sqlc.arg('end_offsets')::int[]);
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: GetHashtagsByChirpIDs :many
+SELECT chirp_id, tag, start_offset, end_offset
+FROM chirp_hashtags
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetMentionsByChirpIDs :many
+SELECT chirp_id, user_id, username, start_offset, end_offset
+FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetChirpsByHashtag :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.search_document, c.hidden_at, c.visibility
+FROM chirps c
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags h WHERE h.chirp_id = c.id AND h.tag = sqlc.arg('tag')
+  )
+  AND c.visibility <> 'unlisted'
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetChirpsMentioningUser :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.search_document, c.hidden_at, c.visibility
+FROM chirps c
+WHERE EXISTS (
+    SELECT 1 FROM chirp_mentions m WHERE m.chirp_id = c.id AND m.user_id = sqlc.arg('user_id')
+  )
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
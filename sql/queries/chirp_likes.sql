@@ -0,0 +1,41 @@
+-- name: LikeChirp :execrows
+-- Likes and like_count are changed in one statement so the counter can never
+-- drift from the rows, and the row lock taken by the UPDATE serializes
+-- concurrent likes of the same chirp.
+WITH inserted AS (
+    INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id IN (SELECT chirp_id FROM inserted);
+
+-- name: UnlikeChirp :execrows
+WITH deleted AS (
+    DELETE FROM chirp_likes
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id IN (SELECT chirp_id FROM deleted);
+
+-- name: GetChirpLikers :many
+SELECT u.id, u.username, l.created_at AS liked_at
+FROM chirp_likes l
+JOIN users u ON u.id = l.user_id
+WHERE l.chirp_id = sqlc.arg('chirp_id')
+  AND (
+    sqlc.narg('before_liked_at')::timestamp IS NULL
+    OR (l.created_at, u.id) < (sqlc.narg('before_liked_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY l.created_at DESC, u.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetLikedChirpIDs :many
+SELECT chirp_id
+FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id')
+  AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
@@ -1,17 +1,89 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, user_id, body, created_at, updated_at)
+-- A rechirp or quote bumps the referenced chirp's counter in the same
+-- statement, so the insert and the count succeed or fail together.
+WITH referenced AS (
+    UPDATE chirps
+    SET rechirp_count = rechirp_count + (sqlc.narg('reference_kind')::text = 'rechirp')::int,
+        quote_count = quote_count + (sqlc.narg('reference_kind')::text = 'quote')::int
+    WHERE id = sqlc.narg('reference_id')::uuid
+)
+INSERT INTO chirps (id, user_id, body, created_at, updated_at, reference_id, reference_kind, visibility)
 VALUES (
-    gen_random_uuid(), $1, $2, NOW(), NOW()
+    gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('body'), NOW(), NOW(), sqlc.narg('reference_id'), sqlc.narg('reference_kind'), sqlc.arg('visibility')
 )
-RETURNING id, user_id, body, created_at, updated_at;
-
+RETURNING id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility;
 
 -- name: GetAllChirps :many
-SELECT id, user_id, body, created_at, updated_at
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
 FROM chirps
+WHERE visibility <> 'unlisted'
 ORDER BY created_at;
 
 -- name: GetChirpByID :one
-SELECT id, user_id, body, created_at, updated_at
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
 FROM chirps
 WHERE id = $1;
+
+-- name: GetChirpsByIDs :many
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetRecentChirpsByUser :many
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at DESC, id DESC
+LIMIT $2;
+
+-- name: GetCelebrityTimeline :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.search_document, c.hidden_at, c.visibility
+FROM chirps c
+WHERE c.user_id IN (
+    SELECT f.followee_id
+    FROM follows f
+    JOIN users u ON u.id = f.followee_id
+    WHERE f.follower_id = sqlc.arg('user_id')
+      AND u.follower_count >= sqlc.arg('min_followers')::bigint
+  )
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: DeleteChirp :exec
+WITH deleted AS (
+    DELETE FROM chirps
+    WHERE chirps.id = $1 AND chirps.user_id = $2
+    RETURNING reference_id, reference_kind
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count - (d.reference_kind = 'rechirp')::int,
+    quote_count = quote_count - (d.reference_kind = 'quote')::int
+FROM deleted d
+WHERE chirps.id = d.reference_id;
+
+-- name: GetRechirp :one
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE user_id = $1 AND reference_id = $2 AND reference_kind = 'rechirp';
+
+-- name: GetRechirpedChirpIDs :many
+SELECT reference_id
+FROM chirps
+WHERE user_id = sqlc.arg('user_id')
+  AND reference_kind = 'rechirp'
+  AND reference_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
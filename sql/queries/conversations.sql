@@ -0,0 +1,82 @@
+-- name: CreateConversation :one
+-- Creating a one-to-one conversation that already exists returns the
+-- existing one.
+INSERT INTO conversations (id, created_by, direct_key, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), sqlc.arg('created_by'), sqlc.narg('direct_key'), NOW(), NOW()
+)
+ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+RETURNING *;
+
+-- name: AddConversationMembers :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+SELECT sqlc.arg('conversation_id'), unnest(sqlc.arg('user_ids')::uuid[]), NOW()
+ON CONFLICT (conversation_id, user_id) DO NOTHING;
+
+-- name: GetConversationMembers :many
+SELECT m.conversation_id, u.id, u.username
+FROM conversation_members m
+JOIN users u ON u.id = m.user_id
+WHERE m.conversation_id = ANY(sqlc.arg('conversation_ids')::uuid[])
+ORDER BY m.joined_at, u.username;
+
+-- name: ListConversations :many
+-- Lists the conversations of user_id, most recently active first, with the
+-- number of messages from others that arrived after the user last read the
+-- conversation. Passing conversation_id restricts the list to that one.
+SELECT c.id, c.direct_key, c.created_at, c.updated_at, m.last_read_at,
+    (
+        SELECT COUNT(*)
+        FROM messages msg
+        WHERE msg.conversation_id = c.id
+          AND msg.sender_id <> m.user_id
+          AND (m.last_read_at IS NULL OR msg.created_at > m.last_read_at)
+    ) AS unread_count
+FROM conversations c
+JOIN conversation_members m ON m.conversation_id = c.id
+WHERE m.user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('conversation_id')::uuid IS NULL OR c.id = sqlc.narg('conversation_id')::uuid)
+  AND (
+    sqlc.narg('before_updated_at')::timestamp IS NULL
+    OR (c.updated_at, c.id) < (sqlc.narg('before_updated_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY c.updated_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetLatestMessages :many
+SELECT DISTINCT ON (conversation_id) *
+FROM messages
+WHERE conversation_id = ANY(sqlc.arg('conversation_ids')::uuid[])
+ORDER BY conversation_id, created_at DESC, id DESC;
+
+-- name: CreateMessage :one
+-- Bumps the conversation so that it moves to the top of its members' lists.
+WITH touched AS (
+    UPDATE conversations
+    SET updated_at = NOW()
+    WHERE conversations.id = sqlc.arg('conversation_id')
+)
+INSERT INTO messages (id, conversation_id, sender_id, body, created_at)
+VALUES (
+    gen_random_uuid(), sqlc.arg('conversation_id'), sqlc.arg('sender_id'), sqlc.arg('body'), NOW()
+)
+RETURNING *;
+
+-- name: GetMessageByID :one
+SELECT * FROM messages
+WHERE id = $1;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: MarkConversationRead :execrows
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
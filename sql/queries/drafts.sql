@@ -0,0 +1,45 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, user_id, body, quoted_chirp_id, media_ids, publish_at, visibility, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('body'), sqlc.narg('quoted_chirp_id'), sqlc.arg('media_ids')::uuid[], sqlc.narg('publish_at'), sqlc.arg('visibility'), NOW(), NOW()
+)
+RETURNING *;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: ListDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = sqlc.arg('body'),
+    quoted_chirp_id = sqlc.narg('quoted_chirp_id'),
+    media_ids = sqlc.arg('media_ids')::uuid[],
+    publish_at = sqlc.narg('publish_at'),
+    visibility = sqlc.arg('visibility'),
+    last_error = '',
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id')
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: LockDraft :one
+-- Locks the draft for publishing, waiting for a job that holds it.
+SELECT * FROM drafts
+WHERE id = $1 AND user_id = $2
+FOR UPDATE;
+
+-- name: MarkDraftFailed :exec
+-- Unschedules a draft that failed to publish, unless it was edited since it
+-- was claimed.
+UPDATE drafts
+SET publish_at = NULL,
+    last_error = sqlc.arg('last_error')
+WHERE id = sqlc.arg('id') AND updated_at = sqlc.arg('updated_at');
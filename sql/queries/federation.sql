@@ -0,0 +1,174 @@
+-- name: GetActorKey :one
+SELECT * FROM actor_keys
+WHERE user_id = $1;
+
+-- name: CreateActorKey :exec
+-- Does nothing when the user has a key already, so that concurrent requests
+-- agree on one key.
+INSERT INTO actor_keys (user_id, public_key_pem, private_key_pem, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id) DO NOTHING;
+
+-- name: GetRemoteActorByURI :one
+SELECT * FROM remote_actors
+WHERE uri = $1;
+
+-- name: UpsertRemoteActor :one
+INSERT INTO remote_actors (
+    id, uri, username, domain, display_name, summary, url, inbox, shared_inbox,
+    public_key_id, public_key_pem, followers, fetched_at, created_at, updated_at
+)
+VALUES (
+    gen_random_uuid(), sqlc.arg('uri'), sqlc.arg('username'), sqlc.arg('domain'), sqlc.arg('display_name'),
+    sqlc.arg('summary'), sqlc.arg('url'), sqlc.arg('inbox'), sqlc.arg('shared_inbox'),
+    sqlc.arg('public_key_id'), sqlc.arg('public_key_pem'), sqlc.arg('followers'), NOW(), NOW(), NOW()
+)
+ON CONFLICT (uri) DO UPDATE
+SET username = EXCLUDED.username,
+    domain = EXCLUDED.domain,
+    display_name = EXCLUDED.display_name,
+    summary = EXCLUDED.summary,
+    url = EXCLUDED.url,
+    inbox = EXCLUDED.inbox,
+    shared_inbox = EXCLUDED.shared_inbox,
+    public_key_id = EXCLUDED.public_key_id,
+    public_key_pem = EXCLUDED.public_key_pem,
+    followers = EXCLUDED.followers,
+    fetched_at = NOW(),
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteRemoteActor :exec
+DELETE FROM remote_actors
+WHERE id = $1;
+
+-- name: UpsertRemoteFollower :exec
+INSERT INTO remote_followers (user_id, actor_id, activity_uri, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, actor_id) DO UPDATE
+SET activity_uri = EXCLUDED.activity_uri;
+
+-- name: DeleteRemoteFollower :execrows
+DELETE FROM remote_followers
+WHERE user_id = $1 AND actor_id = $2;
+
+-- name: GetRemoteFollowerInboxes :many
+-- Returns the inboxes to deliver the activities of a user to, using shared
+-- inboxes so that each server gets one copy.
+SELECT DISTINCT COALESCE(NULLIF(a.shared_inbox, ''), a.inbox)::text AS inbox
+FROM remote_followers f
+JOIN remote_actors a ON a.id = f.actor_id
+WHERE f.user_id = $1;
+
+-- name: CountRemoteFollowers :one
+SELECT COUNT(*)
+FROM remote_followers
+WHERE user_id = $1;
+
+-- name: UpsertRemoteFollowing :exec
+-- Following again replaces the pending Follow.
+INSERT INTO remote_following (user_id, actor_id, activity_uri, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, actor_id) DO UPDATE
+SET activity_uri = EXCLUDED.activity_uri,
+    accepted_at = NULL;
+
+-- name: AcceptRemoteFollowing :execrows
+UPDATE remote_following
+SET accepted_at = NOW()
+WHERE activity_uri = $1 AND actor_id = $2 AND accepted_at IS NULL;
+
+-- name: DeleteRemoteFollowing :one
+-- Returns the URI of the Follow, which the Undo refers to.
+DELETE FROM remote_following
+WHERE user_id = $1 AND actor_id = $2
+RETURNING activity_uri;
+
+-- name: CountRemoteFollowing :one
+SELECT COUNT(*)
+FROM remote_following
+WHERE user_id = $1;
+
+-- name: IsRemoteActorFollowed :one
+-- Reports whether any local user follows the actor.
+SELECT EXISTS (
+    SELECT 1 FROM remote_following
+    WHERE actor_id = $1 AND accepted_at IS NOT NULL
+);
+
+-- name: UpsertRemoteNote :exec
+-- Updates only notes of the same actor, so that an actor cannot overwrite the
+-- notes of another.
+INSERT INTO remote_notes (
+    id, uri, actor_id, body, url, in_reply_to, audience, recipient_ids, published_at, updated_at, created_at
+)
+VALUES (
+    gen_random_uuid(), sqlc.arg('uri'), sqlc.arg('actor_id'), sqlc.arg('body'), sqlc.arg('url'),
+    sqlc.arg('in_reply_to'), sqlc.arg('audience'), sqlc.arg('recipient_ids')::uuid[],
+    sqlc.arg('published_at'), sqlc.arg('updated_at'), NOW()
+)
+ON CONFLICT (uri) DO UPDATE
+SET body = EXCLUDED.body,
+    url = EXCLUDED.url,
+    in_reply_to = EXCLUDED.in_reply_to,
+    audience = EXCLUDED.audience,
+    recipient_ids = EXCLUDED.recipient_ids,
+    updated_at = EXCLUDED.updated_at
+WHERE remote_notes.actor_id = EXCLUDED.actor_id;
+
+-- name: DeleteRemoteNote :execrows
+DELETE FROM remote_notes
+WHERE uri = $1 AND actor_id = $2;
+
+-- name: GetRemoteNotesForUser :many
+-- Returns the notes of the remote actors a user follows that the user may
+-- see, newest first.
+SELECT n.id, n.uri, n.body, n.url, n.in_reply_to, n.published_at, n.updated_at,
+    a.uri AS actor_uri, a.username, a.domain, a.display_name
+FROM remote_notes n
+JOIN remote_actors a ON a.id = n.actor_id
+JOIN remote_following f ON f.actor_id = n.actor_id
+WHERE f.user_id = sqlc.arg('user_id')
+  AND f.accepted_at IS NOT NULL
+  AND (n.audience IN ('public', 'followers') OR f.user_id = ANY(n.recipient_ids))
+  AND (
+    sqlc.narg('before_published_at')::timestamp IS NULL
+    OR (n.published_at, n.id) < (sqlc.narg('before_published_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY n.published_at DESC, n.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CreateRemoteReaction :exec
+INSERT INTO remote_reactions (activity_uri, kind, actor_id, chirp_id, created_at)
+VALUES ($1, $2, $3, $4, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteRemoteReaction :execrows
+DELETE FROM remote_reactions
+WHERE activity_uri = $1 AND actor_id = $2;
+
+-- name: GetOutboxChirps :many
+-- Returns the chirps of a user that are visible to anyone, newest first.
+SELECT id, user_id, body, created_at, updated_at, like_count, reference_id, reference_kind, rechirp_count, quote_count, search_document, hidden_at, visibility
+FROM chirps
+WHERE user_id = sqlc.arg('user_id')
+  AND visibility IN ('public', 'unlisted')
+  AND hidden_at IS NULL
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetRemoteActorByID :one
+SELECT * FROM remote_actors
+WHERE id = $1;
+
+-- name: GetRemoteFollowing :many
+-- Returns the remote actors a user follows, most recently followed first.
+SELECT a.id, a.uri, a.username, a.domain, a.display_name, a.url, f.accepted_at, f.created_at
+FROM remote_following f
+JOIN remote_actors a ON a.id = f.actor_id
+WHERE f.user_id = $1
+ORDER BY f.created_at DESC;
@@ -0,0 +1,26 @@
+-- name: GetUserFeedChirps :many
+-- Returns the newest public chirps of a user for their syndication feeds.
+-- Plain rechirps are left out; quotes are kept, as they have a body.
+SELECT c.id, c.body, c.created_at, c.updated_at, c.reference_id, c.reference_kind, u.username, u.display_name
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE c.user_id = sqlc.arg('user_id')
+  AND c.visibility = 'public'
+  AND c.hidden_at IS NULL
+  AND (c.reference_kind IS NULL OR c.reference_kind <> 'rechirp')
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetHashtagFeedChirps :many
+-- Returns the newest public chirps tagged with tag for its syndication feeds.
+SELECT c.id, c.body, c.created_at, c.updated_at, c.reference_id, c.reference_kind, u.username, u.display_name
+FROM chirps c
+JOIN users u ON u.id = c.user_id
+WHERE EXISTS (
+    SELECT 1 FROM chirp_hashtags h WHERE h.chirp_id = c.id AND h.tag = sqlc.arg('tag')
+  )
+  AND c.visibility = 'public'
+  AND c.hidden_at IS NULL
+  AND (c.reference_kind IS NULL OR c.reference_kind <> 'rechirp')
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
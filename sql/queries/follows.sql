@@ -0,0 +1,87 @@
+-- name: CreateFollow :execrows
+-- The follow and the followee's follower_count change in one statement, the
+-- same way likes keep like_count.
+WITH inserted AS (
+    INSERT INTO follows (follower_id, followee_id, created_at)
+    VALUES (
+        $1, $2, NOW()
+    )
+    ON CONFLICT (follower_id, followee_id) DO NOTHING
+    RETURNING followee_id
+)
+UPDATE users
+SET follower_count = follower_count + 1
+WHERE id IN (SELECT followee_id FROM inserted);
+
+-- name: DeleteFollow :execrows
+WITH deleted AS (
+    DELETE FROM follows
+    WHERE follower_id = $1 AND followee_id = $2
+    RETURNING followee_id
+)
+UPDATE users
+SET follower_count = follower_count - 1
+WHERE id IN (SELECT followee_id FROM deleted);
+
+-- name: GetFollowers :many
+SELECT u.id, u.username, f.created_at AS followed_at
+FROM follows f
+JOIN users u ON u.id = f.follower_id
+WHERE f.followee_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_followed_at')::timestamp IS NULL
+    OR (f.created_at, u.id) < (sqlc.narg('before_followed_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY f.created_at DESC, u.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetFollowing :many
+SELECT u.id, u.username, f.created_at AS followed_at
+FROM follows f
+JOIN users u ON u.id = f.followee_id
+WHERE f.follower_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_followed_at')::timestamp IS NULL
+    OR (f.created_at, u.id) < (sqlc.narg('before_followed_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY f.created_at DESC, u.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CountFollowers :one
+SELECT follower_count::bigint AS count
+FROM users
+WHERE id = $1;
+
+-- name: CountFollowing :one
+SELECT COUNT(*)
+FROM follows
+WHERE follower_id = $1;
+
+-- name: GetTimeline :many
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.search_document, c.hidden_at, c.visibility
+FROM chirps c
+JOIN follows f ON f.followee_id = c.user_id
+WHERE f.follower_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (c.created_at, c.id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetFollowerIDs :many
+SELECT follower_id
+FROM follows
+WHERE followee_id = $1;
+
+-- name: GetFollowedAmong :many
+-- Returns the users in user_ids that follower_id follows.
+SELECT followee_id
+FROM follows
+WHERE follower_id = sqlc.arg('follower_id')
+  AND followee_id = ANY(sqlc.arg('user_ids')::uuid[]);
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = $1;
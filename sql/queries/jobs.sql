@@ -0,0 +1,100 @@
+-- name: EnqueueJob :one
+-- Returns no row when a pending or running job has the same unique key.
+INSERT INTO jobs (id, kind, payload, max_attempts, run_at, unique_key, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
+ON CONFLICT (unique_key) WHERE status IN ('pending', 'running') DO NOTHING
+RETURNING id;
+
+-- name: ClaimJob :one
+-- Claims the earliest due job of the given kinds, or a running one whose
+-- lease expired, and leases it for lease_seconds.
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::double precision),
+    updated_at = NOW()
+WHERE id = (
+    SELECT id FROM jobs
+    WHERE kind = ANY(sqlc.arg('kinds')::text[])
+      AND (
+        (status = 'pending' AND run_at <= NOW())
+        OR (status = 'running' AND locked_until < NOW())
+      )
+    ORDER BY run_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :execrows
+-- The attempt guards against a worker that lost its lease overwriting the
+-- outcome of the attempt after it.
+UPDATE jobs
+SET status = 'succeeded',
+    locked_until = NULL,
+    last_error = NULL,
+    finished_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1 AND attempts = $2 AND status = 'running';
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending',
+    run_at = NOW() + make_interval(secs => sqlc.arg('retry_in_seconds')::double precision),
+    locked_until = NULL,
+    last_error = sqlc.arg('last_error'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND attempts = sqlc.arg('attempts') AND status = 'running';
+
+-- name: KillJob :execrows
+UPDATE jobs
+SET status = 'dead',
+    locked_until = NULL,
+    last_error = sqlc.arg('last_error'),
+    finished_at = NOW(),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND attempts = sqlc.arg('attempts') AND status = 'running';
+
+-- name: DeleteFinishedJobs :execrows
+-- Dead jobs are kept until they are retried or discarded.
+DELETE FROM jobs
+WHERE status = 'succeeded'
+  AND finished_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision);
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1;
+
+-- name: GetJobs :many
+SELECT * FROM jobs
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetJobCounts :many
+SELECT kind, status, COUNT(*) AS count
+FROM jobs
+GROUP BY kind, status
+ORDER BY kind, status;
+
+-- name: RequeueJob :one
+-- Runs a dead or pending job again right away with fresh attempts.
+UPDATE jobs
+SET status = 'pending',
+    attempts = 0,
+    run_at = NOW(),
+    last_error = NULL,
+    finished_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND status IN ('pending', 'dead')
+RETURNING *;
+
+-- name: DiscardJob :execrows
+-- Deletes a dead or pending job. Running jobs cannot be discarded.
+DELETE FROM jobs
+WHERE id = $1 AND status IN ('pending', 'dead');
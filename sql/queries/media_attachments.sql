@@ -0,0 +1,52 @@
+-- name: CreateMediaAttachment :one
+-- Inserts nothing when the upload would take the user's stored bytes past
+-- quota_bytes. Run it after LockMediaQuota in the same transaction, or
+-- concurrent uploads can all pass the check.
+INSERT INTO media_attachments (id, user_id, storage_key, thumbnail_key, content_type, width, height, size_bytes, alt_text, created_at)
+SELECT gen_random_uuid(), sqlc.arg('user_id')::uuid, sqlc.arg('storage_key')::text, sqlc.arg('thumbnail_key')::text,
+    sqlc.arg('content_type')::text, sqlc.arg('width')::int, sqlc.arg('height')::int, sqlc.arg('size_bytes')::bigint,
+    sqlc.arg('alt_text')::text, NOW()
+WHERE (
+    SELECT COALESCE(SUM(size_bytes), 0) FROM media_attachments WHERE user_id = sqlc.arg('user_id')::uuid
+  ) + sqlc.arg('size_bytes')::bigint <= sqlc.arg('quota_bytes')::bigint
+RETURNING *;
+
+-- name: GetMediaAttachmentByID :one
+SELECT * FROM media_attachments
+WHERE id = $1;
+
+-- name: GetMediaAttachmentsByChirpIDs :many
+SELECT * FROM media_attachments
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetMediaUsage :one
+SELECT COALESCE(SUM(size_bytes), 0)::bigint AS used_bytes
+FROM media_attachments
+WHERE user_id = $1;
+
+-- name: LockMediaQuota :exec
+-- Serializes the uploads of a user until the end of the transaction.
+SELECT pg_advisory_xact_lock(hashtext(sqlc.arg('user_id')::uuid::text));
+
+-- name: AttachMedia :execrows
+-- Attaches the caller's unattached uploads to a chirp, in the order given.
+-- Callers compare the affected row count to len(ids) to detect IDs that are
+-- unknown, someone else's or already in use.
+UPDATE media_attachments
+SET chirp_id = sqlc.arg('chirp_id'),
+    position = array_position(sqlc.arg('ids')::uuid[], id) - 1
+WHERE id = ANY(sqlc.arg('ids')::uuid[])
+  AND user_id = sqlc.arg('user_id')
+  AND chirp_id IS NULL;
+
+-- name: UpdateMediaAltText :one
+UPDATE media_attachments
+SET alt_text = sqlc.arg('alt_text')
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id')
+RETURNING *;
+
+-- name: DeleteUnattachedMedia :one
+DELETE FROM media_attachments
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id') AND chirp_id IS NULL
+RETURNING *;
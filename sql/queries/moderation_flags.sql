@@ -0,0 +1,3 @@
+-- name: InsertModerationFlags :exec
+INSERT INTO moderation_flags (id, chirp_id, rule, excerpt, created_at)
+SELECT gen_random_uuid(), sqlc.arg('chirp_id')::uuid, unnest(sqlc.arg('rules')::text[]), unnest(sqlc.arg('excerpts')::text[]), NOW();
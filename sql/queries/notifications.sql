@@ -0,0 +1,62 @@
+-- name: RecordNotification :execrows
+-- Adds actor_id to the unread notification of user_id about the same item,
+-- creating it if there is none. Nothing is recorded when there is a block
+-- between the two users or the recipient has muted the actor.
+WITH notification AS (
+    INSERT INTO notifications (id, user_id, kind, chirp_id, created_at, updated_at)
+    SELECT gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('kind'), sqlc.narg('chirp_id'), NOW(), NOW()
+    WHERE NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocker_id = sqlc.arg('user_id') AND blocked_id = sqlc.arg('actor_id'))
+           OR (blocker_id = sqlc.arg('actor_id') AND blocked_id = sqlc.arg('user_id'))
+      )
+      AND NOT EXISTS (
+        SELECT 1 FROM mutes
+        WHERE muter_id = sqlc.arg('user_id') AND muted_id = sqlc.arg('actor_id')
+      )
+    ON CONFLICT (user_id, kind, (COALESCE(chirp_id, user_id))) WHERE read_at IS NULL
+    DO UPDATE SET updated_at = NOW()
+    RETURNING id
+)
+INSERT INTO notification_actors (notification_id, actor_id, created_at)
+SELECT id, sqlc.arg('actor_id'), NOW() FROM notification
+ON CONFLICT (notification_id, actor_id) DO UPDATE SET created_at = NOW();
+
+-- name: GetNotifications :many
+SELECT n.id, n.kind, n.chirp_id, n.read_at, n.created_at, n.updated_at,
+    (SELECT COUNT(*) FROM notification_actors a WHERE a.notification_id = n.id) AS actor_count
+FROM notifications n
+WHERE n.user_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_updated_at')::timestamp IS NULL
+    OR (n.updated_at, n.id) < (sqlc.narg('before_updated_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY n.updated_at DESC, n.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetNotificationActors :many
+-- Returns the most recent actors of each notification, newest first.
+SELECT a.notification_id, u.id, u.username
+FROM (
+    SELECT notification_actors.*,
+        ROW_NUMBER() OVER (PARTITION BY notification_id ORDER BY created_at DESC) AS rank
+    FROM notification_actors
+    WHERE notification_id = ANY(sqlc.arg('notification_ids')::uuid[])
+) a
+JOIN users u ON u.id = a.actor_id
+WHERE a.rank <= sqlc.arg('per_notification')::int
+ORDER BY a.notification_id, a.created_at DESC;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
@@ -0,0 +1,77 @@
+-- name: CreateOutboxEvent :exec
+INSERT INTO outbox_events (event_id, event_type, payload, created_at)
+VALUES ($1, $2, $3, NOW());
+
+-- name: ClaimOutboxEvents :many
+-- Claims up to limit due events and leases them for lease_seconds, after
+-- which they are due again unless published.
+UPDATE outbox_events
+SET attempts = attempts + 1,
+    next_attempt_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::double precision)
+WHERE id IN (
+    SELECT id FROM outbox_events
+    WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+    ORDER BY id
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkOutboxEventPublished :exec
+UPDATE outbox_events
+SET published_at = NOW(),
+    last_error = NULL
+WHERE id = $1;
+
+-- name: ScheduleOutboxRetry :exec
+UPDATE outbox_events
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg('retry_in_seconds')::double precision),
+    last_error = sqlc.arg('last_error')
+WHERE id = sqlc.arg('id');
+
+-- name: MarkOutboxEventFailed :exec
+-- Gives up on an event whose attempts ran out.
+UPDATE outbox_events
+SET failed_at = NOW(),
+    last_error = sqlc.arg('last_error')
+WHERE id = sqlc.arg('id');
+
+-- name: GetFailedOutboxEvents :many
+SELECT * FROM outbox_events
+WHERE failed_at IS NOT NULL
+  AND (
+    sqlc.narg('before_failed_at')::timestamp IS NULL
+    OR (failed_at, event_id) < (sqlc.narg('before_failed_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY failed_at DESC, event_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: RetryOutboxEvent :one
+-- Dispatches a failed event again right away with fresh attempts. Consumers
+-- that handled it before are skipped.
+UPDATE outbox_events
+SET failed_at = NULL,
+    attempts = 0,
+    next_attempt_at = NOW(),
+    last_error = NULL
+WHERE event_id = $1 AND failed_at IS NOT NULL
+RETURNING *;
+
+-- name: DeletePublishedOutboxEvents :execrows
+DELETE FROM outbox_events
+WHERE published_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision);
+
+-- name: MarkEventProcessed :execrows
+-- Affects no row when consumer has processed the event already.
+INSERT INTO processed_events (consumer, event_id, processed_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (consumer, event_id) DO NOTHING;
+
+-- name: GetEventConsumers :many
+-- Lists the consumers that have handled the event.
+SELECT consumer FROM processed_events
+WHERE event_id = $1;
+
+-- name: DeleteProcessedEvents :execrows
+DELETE FROM processed_events
+WHERE processed_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision);
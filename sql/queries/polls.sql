@@ -0,0 +1,46 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at, created_at)
+VALUES ($1, $2, NOW());
+
+-- name: InsertPollOptions :exec
+-- Options are numbered from 0 in the order given.
+INSERT INTO poll_options (chirp_id, position, text)
+SELECT sqlc.arg('chirp_id')::uuid, o.ordinality - 1, o.text
+FROM unnest(sqlc.arg('texts')::text[]) WITH ORDINALITY AS o(text, ordinality);
+
+-- name: GetPollByChirpID :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsByChirpIDs :many
+SELECT * FROM polls
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: GetPollOptionsByChirpIDs :many
+SELECT * FROM poll_options
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetPollVotesByUser :many
+SELECT chirp_id, position FROM poll_votes
+WHERE user_id = sqlc.arg('user_id') AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: VoteInPoll :execrows
+-- Records the vote and bumps the option's tally in one statement. Nothing is
+-- written when the poll has closed, the option does not exist or the user has
+-- already voted.
+WITH vote AS (
+    INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+    SELECT o.chirp_id, sqlc.arg('user_id')::uuid, o.position, NOW()
+    FROM polls p
+    JOIN poll_options o ON o.chirp_id = p.chirp_id
+    WHERE p.chirp_id = sqlc.arg('chirp_id')::uuid
+      AND o.position = sqlc.arg('position')::int
+      AND p.closes_at > NOW()
+    ON CONFLICT (chirp_id, user_id) DO NOTHING
+    RETURNING chirp_id, position
+)
+UPDATE poll_options o
+SET vote_count = o.vote_count + 1
+FROM vote
+WHERE o.chirp_id = vote.chirp_id AND o.position = vote.position;
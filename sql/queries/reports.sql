@@ -0,0 +1,59 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, reporter_id, user_id, chirp_id, category, details, status, created_at)
+VALUES (
+    gen_random_uuid(), sqlc.narg('reporter_id'), sqlc.arg('user_id'), sqlc.narg('chirp_id'), sqlc.arg('category'), sqlc.arg('details'), 'open', NOW()
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReports :many
+-- The queue is worked oldest first.
+SELECT * FROM reports
+WHERE status = sqlc.arg('status')
+ORDER BY created_at, id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: ClaimReport :one
+-- Claims an open report. Claiming a report the moderator already holds is a
+-- no-op that still returns it.
+UPDATE reports
+SET status = 'claimed',
+    claimed_by = sqlc.arg('moderator_id'),
+    claimed_at = COALESCE(claimed_at, NOW())
+WHERE id = sqlc.arg('id')
+  AND (status = 'open' OR (status = 'claimed' AND claimed_by = sqlc.arg('moderator_id')))
+RETURNING *;
+
+-- name: ResolveReport :one
+-- Only the moderator holding the claim can resolve a report.
+UPDATE reports
+SET status = 'resolved',
+    resolution = sqlc.arg('resolution'),
+    resolved_at = NOW()
+WHERE id = sqlc.arg('id') AND status = 'claimed' AND claimed_by = sqlc.arg('moderator_id')
+RETURNING *;
+
+-- name: CountReportsByStatus :many
+SELECT status, COUNT(*) AS count
+FROM reports
+GROUP BY status;
+
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, moderator_id, report_id, action, target_user_id, target_chirp_id, note, created_at)
+VALUES (
+    gen_random_uuid(), sqlc.narg('moderator_id'), sqlc.narg('report_id'), sqlc.arg('action'), sqlc.narg('target_user_id'), sqlc.narg('target_chirp_id'), sqlc.arg('note'), NOW()
+);
+
+-- name: GetModerationActionsByReport :many
+SELECT * FROM moderation_actions
+WHERE report_id = $1
+ORDER BY created_at;
+
+-- name: GetModerationActionsByUser :many
+SELECT * FROM moderation_actions
+WHERE target_user_id = sqlc.arg('user_id')
+  AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action')::text)
+ORDER BY created_at DESC;
@@ -0,0 +1,25 @@
+-- name: SearchChirps :many
+-- The body is HTML-escaped before ts_headline, so the only markup in the
+-- snippet is the <mark> tags around matches.
+SELECT c.id, c.user_id, c.body, c.created_at, c.updated_at, c.like_count, c.reference_id, c.reference_kind, c.rechirp_count, c.quote_count, c.hidden_at, c.visibility,
+    ts_rank(c.search_document, q.query)::real AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(replace(replace(c.body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;'),
+        q.query,
+        'StartSel=<mark>, StopSel=</mark>, MaxFragments=2'
+    )::text AS snippet
+FROM chirps c
+CROSS JOIN to_tsquery('english', sqlc.arg('query')) AS q(query)
+WHERE c.search_document @@ q.query
+  AND c.visibility <> 'unlisted'
+  AND (sqlc.narg('author_id')::uuid IS NULL OR c.user_id = sqlc.narg('author_id')::uuid)
+  AND (sqlc.narg('since')::timestamp IS NULL OR c.created_at >= sqlc.narg('since')::timestamp)
+  AND (sqlc.narg('until')::timestamp IS NULL OR c.created_at < sqlc.narg('until')::timestamp)
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (ts_rank(c.search_document, q.query)::real, c.created_at, c.id)
+        < (sqlc.arg('before_rank')::real, sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY rank DESC, c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('limit');
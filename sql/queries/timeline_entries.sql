@@ -0,0 +1,35 @@
+-- name: InsertTimelineEntries :exec
+INSERT INTO timeline_entries (user_id, chirp_id, author_id, created_at)
+SELECT unnest(sqlc.arg('user_ids')::uuid[]), sqlc.arg('chirp_id')::uuid, sqlc.arg('author_id')::uuid, sqlc.arg('created_at')::timestamp
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: GetTimelineEntries :many
+SELECT user_id, chirp_id, author_id, created_at
+FROM timeline_entries
+WHERE user_id = sqlc.arg('user_id')
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (created_at, chirp_id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY created_at DESC, chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: DeleteTimelineEntriesByChirp :exec
+DELETE FROM timeline_entries
+WHERE chirp_id = $1;
+
+-- name: DeleteTimelineEntriesByAuthor :exec
+DELETE FROM timeline_entries
+WHERE user_id = $1 AND author_id = $2;
+
+-- name: GetTimelineHorizon :one
+SELECT horizon
+FROM timeline_horizons
+WHERE user_id = $1;
+
+-- name: RaiseTimelineHorizon :exec
+-- Moves the horizon of a timeline to horizon unless it is already later.
+INSERT INTO timeline_horizons (user_id, horizon)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET horizon = GREATEST(timeline_horizons.horizon, EXCLUDED.horizon);
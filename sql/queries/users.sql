@@ -12,6 +12,56 @@ DELETE FROM users;
 TRUNCATE TABLE users RESTART IDENTITY;
 
 -- name: GetUserByEmail :one
-SELECT id, username, email, hashed_password, created_at, updated_at
+SELECT id, username, email, hashed_password, created_at, updated_at, display_name, bio
 FROM users
 WHERE email = $1;
+
+-- name: GetUserByID :one
+SELECT id, username, email, created_at, updated_at
+FROM users
+WHERE id = $1;
+
+-- name: GetUsersByUsernames :many
+SELECT id, username
+FROM users
+WHERE lower(username) = ANY(sqlc.arg('usernames')::text[]);
+
+-- name: GetPublicProfileByID :one
+SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.created_at,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id) AS chirp_count
+FROM users u
+WHERE u.id = $1;
+
+-- name: GetPublicProfileByUsername :one
+SELECT u.id, u.username, u.display_name, u.bio, u.avatar_key, u.created_at,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id) AS chirp_count
+FROM users u
+WHERE lower(u.username) = lower(sqlc.arg('username'));
+
+-- name: SearchUsersByUsernamePrefix :many
+SELECT id, username, display_name
+FROM users
+WHERE lower(username) LIKE lower(sqlc.arg('prefix')::text) || '%'
+ORDER BY lower(username)
+LIMIT sqlc.arg('limit');
+
+-- name: UpdateUserProfile :exec
+UPDATE users
+SET display_name = $2, bio = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpdateUserAvatar :exec
+UPDATE users
+SET avatar_key = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetUserModeration :one
+SELECT is_moderator, suspended_until
+FROM users
+WHERE id = $1;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = sqlc.arg('suspended_until'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
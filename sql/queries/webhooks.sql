@@ -0,0 +1,126 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, url, secret, events, created_at, updated_at)
+VALUES (
+    gen_random_uuid(), sqlc.arg('user_id'), sqlc.arg('url'), sqlc.arg('secret'), sqlc.arg('events')::text[], NOW(), NOW()
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhooks :many
+SELECT * FROM webhooks
+WHERE user_id = $1
+ORDER BY created_at, id;
+
+-- name: CountWebhooks :one
+SELECT COUNT(*) FROM webhooks
+WHERE user_id = $1;
+
+-- name: UpdateWebhook :one
+-- Enabling a webhook clears its failures, so that it gets a fresh start.
+UPDATE webhooks
+SET url = sqlc.arg('url'),
+    events = sqlc.arg('events')::text[],
+    disabled_at = CASE WHEN sqlc.arg('enabled')::boolean THEN NULL ELSE COALESCE(disabled_at, NOW()) END,
+    consecutive_failures = CASE WHEN sqlc.arg('enabled')::boolean THEN 0 ELSE consecutive_failures END,
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND user_id = sqlc.arg('user_id')
+RETURNING *;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhooksForEvent :many
+-- Lists the enabled webhooks of user_ids that subscribe to event_type.
+SELECT * FROM webhooks
+WHERE user_id = ANY(sqlc.arg('user_ids')::uuid[])
+  AND sqlc.arg('event_type')::text = ANY(events)
+  AND disabled_at IS NULL;
+
+-- name: RecordWebhookSuccess :exec
+UPDATE webhooks
+SET consecutive_failures = 0
+WHERE id = $1 AND consecutive_failures > 0;
+
+-- name: RecordWebhookFailure :one
+-- Counts a failed attempt and disables the webhook once max_failures is
+-- reached. Returns whether this attempt disabled it.
+UPDATE webhooks
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE
+        WHEN disabled_at IS NULL AND consecutive_failures + 1 >= sqlc.arg('max_failures')::integer THEN NOW()
+        ELSE disabled_at
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING (disabled_at IS NOT NULL AND consecutive_failures = sqlc.arg('max_failures')::integer)::boolean AS disabled;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: ClaimWebhookDeliveries :many
+-- Claims up to limit due deliveries of enabled webhooks. Claimed deliveries
+-- are leased: should the attempt never be recorded, they become due again
+-- once the lease expires.
+UPDATE webhook_deliveries d
+SET attempts = d.attempts + 1,
+    next_attempt_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::double precision),
+    updated_at = NOW()
+FROM webhooks w
+WHERE w.id = d.webhook_id
+  AND d.id IN (
+    SELECT due.id
+    FROM webhook_deliveries due
+    JOIN webhooks hook ON hook.id = due.webhook_id
+    WHERE due.status = 'pending'
+      AND due.next_attempt_at <= NOW()
+      AND hook.disabled_at IS NULL
+    ORDER BY due.next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE OF due SKIP LOCKED
+  )
+RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.attempts, w.url, w.secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded',
+    last_status_code = $2,
+    last_error = NULL,
+    delivered_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: ScheduleWebhookRetry :exec
+UPDATE webhook_deliveries
+SET next_attempt_at = NOW() + make_interval(secs => sqlc.arg('retry_in_seconds')::double precision),
+    last_status_code = sqlc.narg('last_status_code'),
+    last_error = sqlc.arg('last_error'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: MarkWebhookDeliveryFailed :exec
+UPDATE webhook_deliveries
+SET status = 'failed',
+    last_status_code = sqlc.narg('last_status_code'),
+    last_error = sqlc.arg('last_error'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id');
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg('webhook_id')
+  AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('before_created_at')::timestamp, sqlc.narg('before_id')::uuid)
+  )
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1 AND webhook_id = $2;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS timeline_entries (
+    user_id UUID NOT NULL,
+    chirp_id UUID NOT NULL,
+    author_id UUID NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_timeline_entries_user_created ON timeline_entries(user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX idx_timeline_entries_chirp_id ON timeline_entries(chirp_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS timeline_entries;
+-- +goose StatementEnd
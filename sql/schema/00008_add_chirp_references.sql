@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS reference_id UUID NULL REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN IF NOT EXISTS reference_kind TEXT NULL CHECK (reference_kind IN ('rechirp', 'quote')),
+ADD COLUMN IF NOT EXISTS rechirp_count INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN IF NOT EXISTS quote_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_chirps_reference_id ON chirps(reference_id);
+CREATE UNIQUE INDEX idx_chirps_user_rechirp ON chirps(user_id, reference_id) WHERE reference_kind = 'rechirp';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_chirps_user_rechirp;
+DROP INDEX IF EXISTS idx_chirps_reference_id;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS quote_count,
+DROP COLUMN IF EXISTS rechirp_count,
+DROP COLUMN IF EXISTS reference_kind,
+DROP COLUMN IF EXISTS reference_id;
+-- +goose StatementEnd
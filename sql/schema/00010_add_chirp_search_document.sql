@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- search_document is generated from the body, so edits keep it current
+-- without a trigger.
+ALTER TABLE chirps
+ADD COLUMN search_document TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', body)) STORED;
+
+CREATE INDEX idx_chirps_search_document ON chirps USING GIN (search_document);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_chirps_search_document;
+ALTER TABLE chirps DROP COLUMN IF EXISTS search_document;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS display_name VARCHAR(100) NOT NULL DEFAULT '',
+ADD COLUMN IF NOT EXISTS bio VARCHAR(300) NOT NULL DEFAULT '';
+
+-- Usernames become unique ignoring case. The oldest account keeps each name
+-- and newer duplicates get a suffix taken from their ID, with the name cut
+-- to fit the column and a counter added should the result be taken too.
+DO $$
+DECLARE
+    dup RECORD;
+    suffix TEXT;
+    candidate TEXT;
+    n INTEGER;
+BEGIN
+    FOR dup IN
+        SELECT id, username
+        FROM (
+            SELECT id, username,
+                row_number() OVER (PARTITION BY lower(username) ORDER BY created_at NULLS FIRST, id) AS nth
+            FROM users
+        ) ranked
+        WHERE nth > 1
+    LOOP
+        suffix := '_' || substr(dup.id::text, 1, 8);
+        n := 1;
+        LOOP
+            candidate := left(dup.username, 50 - length(suffix)) || suffix;
+            EXIT WHEN NOT EXISTS (SELECT 1 FROM users WHERE lower(username) = lower(candidate));
+            n := n + 1;
+            suffix := '_' || substr(dup.id::text, 1, 8) || '_' || n;
+        END LOOP;
+
+        UPDATE users SET username = candidate WHERE id = dup.id;
+    END LOOP;
+END
+$$;
+
+CREATE UNIQUE INDEX idx_users_username_lower ON users (lower(username));
+CREATE INDEX idx_users_username_lower_pattern ON users (lower(username) text_pattern_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_username_lower_pattern;
+DROP INDEX IF EXISTS idx_users_username_lower;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS bio,
+DROP COLUMN IF EXISTS display_name;
+-- +goose StatementEnd
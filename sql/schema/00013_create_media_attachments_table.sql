@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS media_attachments (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- NULL until the upload is attached to a chirp.
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    position INTEGER,
+    storage_key TEXT NOT NULL,
+    thumbnail_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    alt_text VARCHAR(1000) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_media_attachments_user_id ON media_attachments(user_id);
+CREATE INDEX idx_media_attachments_chirp_id ON media_attachments(chirp_id, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS media_attachments;
+-- +goose StatementEnd
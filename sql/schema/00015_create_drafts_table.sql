@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS drafts (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL DEFAULT '',
+    quoted_chirp_id UUID,
+    media_ids UUID[] NOT NULL DEFAULT '{}',
+    -- When set, the scheduler publishes the draft once this time has passed.
+    publish_at TIMESTAMP,
+    -- Why the last scheduled publish failed; cleared when the draft is edited.
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_drafts_user_id ON drafts(user_id, updated_at DESC);
+CREATE INDEX idx_drafts_publish_at ON drafts(publish_at) WHERE publish_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS drafts;
+-- +goose StatementEnd
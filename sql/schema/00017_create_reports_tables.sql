@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Moderators are appointed directly in the database:
+--   UPDATE users SET is_moderator = TRUE WHERE username = '...';
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS is_moderator BOOLEAN NOT NULL DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS suspended_until TIMESTAMP;
+
+ALTER TABLE chirps
+ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS reports (
+    id UUID PRIMARY KEY,
+    -- NULL for reports raised by the moderation pipeline.
+    reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    category TEXT NOT NULL,
+    details VARCHAR(500) NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    claimed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolution TEXT,
+    resolved_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CHECK (status IN ('open', 'claimed', 'resolved'))
+);
+
+CREATE INDEX idx_reports_status ON reports(status, created_at);
+
+CREATE TABLE IF NOT EXISTS moderation_actions (
+    id UUID PRIMARY KEY,
+    moderator_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    report_id UUID REFERENCES reports(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    target_user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    target_chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_moderation_actions_report_id ON moderation_actions(report_id, created_at);
+CREATE INDEX idx_moderation_actions_target_user_id ON moderation_actions(target_user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS moderation_actions;
+DROP TABLE IF EXISTS reports;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS hidden_at;
+
+ALTER TABLE users
+DROP COLUMN IF EXISTS suspended_until,
+DROP COLUMN IF EXISTS is_moderator;
+-- +goose StatementEnd
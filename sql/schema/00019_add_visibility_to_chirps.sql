@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'unlisted'));
+
+ALTER TABLE drafts
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'unlisted'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE drafts
+DROP COLUMN IF EXISTS visibility;
+
+ALTER TABLE chirps
+DROP COLUMN IF EXISTS visibility;
+-- +goose StatementEnd
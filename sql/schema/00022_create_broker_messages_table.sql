@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- broker_messages is the log behind the Postgres broker. Rows are kept for a
+-- while so that instances can catch up on what they missed while their
+-- listener was disconnected.
+CREATE TABLE IF NOT EXISTS broker_messages (
+    id BIGSERIAL PRIMARY KEY,
+    channel TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_broker_messages_created_at ON broker_messages(created_at);
+
+-- Every instance LISTENs on broker_messages. NOTIFY payloads are limited to
+-- 8000 bytes, so larger messages are announced by ID alone and read from the
+-- table.
+CREATE OR REPLACE FUNCTION notify_broker_message() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('broker_messages', json_build_object(
+        'id', NEW.id,
+        'channel', NEW.channel,
+        'payload', CASE WHEN octet_length(NEW.payload) <= 3000 THEN NEW.payload END
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER broker_messages_notify
+AFTER INSERT ON broker_messages
+FOR EACH ROW EXECUTE FUNCTION notify_broker_message();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS broker_messages;
+DROP FUNCTION IF EXISTS notify_broker_message();
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+-- jobs is the background job queue. A job is pending until a worker claims
+-- it, running while the worker holds its lease, and then succeeded, pending
+-- again for a retry, or dead once its attempts are used up.
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY,
+    kind TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    -- locked_until is when the lease of a running job expires. A job whose
+    -- worker died is claimed again after that.
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    -- unique_key, when set, allows only one pending or running job with it.
+    unique_key TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_jobs_due ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_leased ON jobs(locked_until) WHERE status = 'running';
+CREATE INDEX idx_jobs_created_at ON jobs(created_at DESC, id DESC);
+CREATE INDEX idx_jobs_finished_at ON jobs(finished_at) WHERE status = 'succeeded';
+CREATE UNIQUE INDEX idx_jobs_unique_key ON jobs(unique_key) WHERE status IN ('pending', 'running');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
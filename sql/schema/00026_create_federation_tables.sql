@@ -0,0 +1,93 @@
+-- +goose Up
+-- +goose StatementBegin
+-- actor_keys holds the key pair each local user signs ActivityPub requests
+-- with. Keys are created the first time a user is federated.
+CREATE TABLE IF NOT EXISTS actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- remote_actors caches the actors of other servers that interacted with
+-- local users, with the public key their requests are verified with.
+CREATE TABLE IF NOT EXISTS remote_actors (
+    id UUID PRIMARY KEY,
+    uri TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    domain TEXT NOT NULL,
+    display_name TEXT NOT NULL DEFAULT '',
+    summary TEXT NOT NULL DEFAULT '',
+    url TEXT NOT NULL DEFAULT '',
+    inbox TEXT NOT NULL,
+    shared_inbox TEXT NOT NULL DEFAULT '',
+    public_key_id TEXT NOT NULL,
+    public_key_pem TEXT NOT NULL,
+    fetched_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- remote_followers are remote actors following local users; local chirps
+-- are delivered to their inboxes.
+CREATE TABLE IF NOT EXISTS remote_followers (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    activity_uri TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, actor_id)
+);
+
+CREATE INDEX idx_remote_followers_actor_id ON remote_followers(actor_id);
+
+-- remote_following are remote actors followed by local users. accepted_at is
+-- set once the remote server accepts the Follow.
+CREATE TABLE IF NOT EXISTS remote_following (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    activity_uri TEXT NOT NULL UNIQUE,
+    accepted_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, actor_id)
+);
+
+CREATE INDEX idx_remote_following_actor_id ON remote_following(actor_id);
+
+-- remote_notes are posts of remote actors, stored as plain text.
+CREATE TABLE IF NOT EXISTS remote_notes (
+    id UUID PRIMARY KEY,
+    uri TEXT NOT NULL UNIQUE,
+    actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    url TEXT NOT NULL DEFAULT '',
+    in_reply_to TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_remote_notes_actor_published ON remote_notes(actor_id, published_at DESC, id DESC);
+
+-- remote_reactions are likes and announces of local chirps by remote actors.
+-- They are undone by the URI of the activity that made them.
+CREATE TABLE IF NOT EXISTS remote_reactions (
+    activity_uri TEXT PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('like', 'announce')),
+    actor_id UUID NOT NULL REFERENCES remote_actors(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, actor_id, chirp_id)
+);
+
+CREATE INDEX idx_remote_reactions_chirp_id ON remote_reactions(chirp_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS remote_reactions;
+DROP TABLE IF EXISTS remote_notes;
+DROP TABLE IF EXISTS remote_following;
+DROP TABLE IF EXISTS remote_followers;
+DROP TABLE IF EXISTS remote_actors;
+DROP TABLE IF EXISTS actor_keys;
+-- +goose StatementEnd
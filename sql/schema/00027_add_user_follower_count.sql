@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- follower_count is kept in step with follows by CreateFollow and
+-- DeleteFollow, so hot paths like the celebrity check do not count rows.
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS follower_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE users u
+SET follower_count = (SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+DROP COLUMN IF EXISTS follower_count;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Scheduled drafts are published by drafts.publish jobs instead of a
+-- scheduler scanning drafts, so drafts already scheduled get their jobs here.
+-- The payload matches publishDraftPayload in handler/draft.go.
+INSERT INTO jobs (id, kind, payload, max_attempts, run_at, created_at, updated_at)
+SELECT gen_random_uuid(),
+    'drafts.publish',
+    json_build_object('draft_id', id, 'user_id', user_id, 'publish_at', publish_at AT TIME ZONE 'UTC')::text,
+    10,
+    publish_at,
+    NOW(),
+    NOW()
+FROM drafts
+WHERE publish_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_drafts_publish_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS idx_drafts_publish_at ON drafts(publish_at) WHERE publish_at IS NOT NULL;
+
+DELETE FROM jobs
+WHERE kind = 'drafts.publish' AND status IN ('pending', 'dead');
+-- +goose StatementEnd
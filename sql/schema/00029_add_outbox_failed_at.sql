@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Events whose attempts ran out are set aside with failed_at until a
+-- moderator retries them.
+ALTER TABLE outbox_events ADD COLUMN failed_at TIMESTAMP;
+
+DROP INDEX IF EXISTS idx_outbox_events_due;
+CREATE INDEX idx_outbox_events_due ON outbox_events(next_attempt_at) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX idx_outbox_events_failed_at ON outbox_events(failed_at) WHERE failed_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_events_failed_at;
+DROP INDEX IF EXISTS idx_outbox_events_due;
+CREATE INDEX idx_outbox_events_due ON outbox_events(next_attempt_at) WHERE published_at IS NULL;
+ALTER TABLE outbox_events DROP COLUMN IF EXISTS failed_at;
+-- +goose StatementEnd
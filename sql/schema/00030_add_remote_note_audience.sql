@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- followers is the URI of the followers collection of an actor, which notes
+-- for its followers only are addressed to.
+ALTER TABLE remote_actors ADD COLUMN followers TEXT NOT NULL DEFAULT '';
+
+-- audience is who may see a note: everyone, the followers of its actor, or
+-- only the local users in recipient_ids. The audience of notes stored before
+-- it was recorded is unknown, so they are treated as direct and not shown.
+ALTER TABLE remote_notes
+    ADD COLUMN audience TEXT NOT NULL DEFAULT 'direct' CHECK (audience IN ('public', 'followers', 'direct')),
+    ADD COLUMN recipient_ids UUID[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE remote_notes
+    DROP COLUMN IF EXISTS recipient_ids,
+    DROP COLUMN IF EXISTS audience;
+ALTER TABLE remote_actors DROP COLUMN IF EXISTS followers;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A timeline may be missing chirps older than its horizon: backfills copy
+-- only the newest chirps of an author into it, so reads that reach past the
+-- horizon are pulled from the follow graph.
+CREATE TABLE IF NOT EXISTS timeline_horizons (
+    user_id UUID PRIMARY KEY,
+    horizon TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS timeline_horizons;
+-- +goose StatementEnd